@@ -0,0 +1,47 @@
+package api
+
+import (
+	"crypto/subtle"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"time"
+)
+
+const adminTokenHeader = "X-Admin-Token"
+
+// requireAdmin lets a request through only if it carries the configured admin token.
+// Admin routes are disabled while no token is configured.
+func (handler *Handler) requireAdmin(c *gin.Context) {
+	token := c.GetHeader(adminTokenHeader)
+	if handler.AdminToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(handler.AdminToken)) != 1 {
+		log.Println("admin access denied")
+		c.AbortWithStatusJSON(http.StatusForbidden, ErrorMessage{"forbidden"})
+		return
+	}
+	c.Next()
+}
+
+// purgeBooks permanently removes soft-deleted books, optionally only those
+// deleted before the RFC 3339 time given in the before query parameter.
+func (handler *Handler) purgeBooks(c *gin.Context) {
+	var before time.Time
+	if value := c.Query("before"); value != "" {
+		var err error
+		before, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			log.Println(err.Error())
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
+			return
+		}
+	}
+
+	count, err := handler.DataBase.PurgeBooks(before, auditInfo(c))
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorMessage{"internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"purged": count})
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAPIPurgeBooks(t *testing.T) {
+	type mockBehavior func(s *MockDatabase)
+	gin.SetMode(gin.ReleaseMode)
+	tests := []struct {
+		name                 string
+		adminToken           string
+		inputToken           string
+		query                string
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:       "OK",
+			adminToken: "secret",
+			inputToken: "secret",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().PurgeBooks(time.Time{}, gomock.Any()).Return(3, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{"purged":3}`,
+		},
+		{
+			name:       "OK with time",
+			adminToken: "secret",
+			inputToken: "secret",
+			query:      "before=2021-11-20T10:00:00Z",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().PurgeBooks(time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC), gomock.Any()).Return(0, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{"purged":0}`,
+		},
+		{
+			name:                 "invalid time",
+			adminToken:           "secret",
+			inputToken:           "secret",
+			query:                "before=yesterday",
+			mockBehavior:         func(r *MockDatabase) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"invalid input"}`,
+		},
+		{
+			name:                 "wrong token",
+			adminToken:           "secret",
+			inputToken:           "guess",
+			mockBehavior:         func(r *MockDatabase) {},
+			expectedStatusCode:   http.StatusForbidden,
+			expectedResponseBody: `{"error":"forbidden"}`,
+		},
+		{
+			name:                 "admin disabled",
+			mockBehavior:         func(r *MockDatabase) {},
+			expectedStatusCode:   http.StatusForbidden,
+			expectedResponseBody: `{"error":"forbidden"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			db := NewMockDatabase(c)
+			test.mockBehavior(db)
+			rest_api := Handler{Router: gin.Default(), DataBase: db, AdminToken: test.adminToken}
+
+			r := gin.New()
+			r.DELETE("/admin/books", rest_api.requireAdmin, rest_api.purgeBooks)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("DELETE", "/admin/books?"+test.query, nil)
+			req.Header.Set(adminTokenHeader, test.inputToken)
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			assert.Equal(t, test.expectedResponseBody, w.Body.String())
+		})
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"database/sql"
 	"github.com/gin-gonic/gin"
 	"github.com/porky256/rest-api/db"
+	"github.com/porky256/rest-api/events"
 	"github.com/porky256/rest-api/models"
 	"log"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
 )
@@ -14,36 +16,106 @@ import (
 // book represents data about a record book.
 
 type Handler struct {
-	Router   *gin.Engine
-	DataBase db.Database
+	Router     *gin.Engine
+	DataBase   db.Database
+	AdminToken string
+	Events     *events.Bus
 }
 
 type ErrorMessage struct {
 	Message string `json:"error"`
 }
 
+// createdBook is the response to adding a book.
+type createdBook struct {
+	ID int `json:"id" xml:"id"`
+}
+
 func InitializeHandler(database db.Database) Handler {
 	handler := Handler{}
 	handler.Router = gin.Default()
 	handler.DataBase = database
-	handler.Router.GET("/books", handler.getBooks)
-	handler.Router.GET("/books/:id", handler.getBookByID)
-	handler.Router.POST("/books", handler.postBook)
-	handler.Router.DELETE("/books/:id", handler.deleteBook)
-	handler.Router.PUT("/books/:id", handler.updateBook)
+	handler.AdminToken = os.Getenv("ADMIN_TOKEN")
+	handler.Events = events.NewBus(events.DefaultCapacity)
+	handler.Router.Use(requestID())
+	handler.Router.GET("/docs/*filepath", getDocs)
+	handler.Router.POST("/graphql", handler.serveGraphQL())
+	handler.Router.NoRoute(redirectUnversioned)
+	handler.registerRoutes(handler.Router.Group("/v1", versioned(1), deprecated), 1)
+	handler.registerRoutes(handler.Router.Group("/v2", versioned(2)), 2)
 	return handler
 }
 
+// registerRoutes registers the routes of an API version on its group, the
+// versions share the handlers.
+func (handler *Handler) registerRoutes(group *gin.RouterGroup, version int) {
+	if router, err := newOpenAPIRouter(version); err != nil {
+		log.Println("invalid OpenAPI document, request bodies are not validated: ", err)
+	} else {
+		group.Use(validateRequest(router))
+	}
+	group.GET("/books", acceptable, handler.getBooks)
+	group.GET("/books/search", acceptable, handler.searchBooks)
+	group.GET("/books/:id", acceptable, handler.getBookByID)
+	group.POST("/books", handler.idempotency, acceptable, handler.postBook)
+	group.DELETE("/books/:id", handler.deleteBook)
+	group.PUT("/books/:id", acceptable, handler.updateBook)
+	group.GET("/books/:id/history", handler.getBookHistory)
+	group.POST("/books/:id/restore", acceptable, handler.restoreBook)
+	group.POST("/books/:id/stock", handler.idempotency, acceptable, handler.adjustStock)
+	group.GET("/books/:id/prices", acceptable, handler.getBookPrices)
+	group.GET("/audit", handler.getAuditLog)
+	group.POST("/orders", handler.idempotency, handler.postOrder)
+	group.GET("/orders/:id", handler.getOrderByID)
+	group.PUT("/orders/:id/status", handler.updateOrderStatus)
+	group.GET("/customers", handler.getCustomers)
+	group.GET("/customers/:id", handler.getCustomerByID)
+	group.POST("/customers", handler.idempotency, handler.postCustomer)
+	group.PUT("/customers/:id", handler.updateCustomer)
+	group.DELETE("/customers/:id", handler.deleteCustomer)
+	group.GET("/customers/:id/orders", handler.getCustomerOrders)
+	group.GET("/promotions", handler.getPromotions)
+	group.GET("/promotions/:id", handler.getPromotionByID)
+	group.POST("/promotions", handler.postPromotion)
+	group.PUT("/promotions/:id", handler.updatePromotion)
+	group.DELETE("/promotions/:id", handler.deletePromotion)
+	group.GET("/reports/price-changes", acceptable, handler.getPriceChangeReport)
+	group.GET("/inventory/low-stock", handler.getLowStock)
+	group.GET("/inventory/thresholds", handler.getStockThresholds)
+	group.POST("/inventory/thresholds", handler.postStockThreshold)
+	group.PUT("/inventory/thresholds/:id", handler.updateStockThreshold)
+	group.DELETE("/inventory/thresholds/:id", handler.deleteStockThreshold)
+	group.GET("/webhooks", handler.getWebhooks)
+	group.GET("/webhooks/dead-letters", handler.getDeadLetters)
+	group.POST("/webhooks/dead-letters/:id/retry", handler.retryDeadLetter)
+	group.GET("/webhooks/:id", handler.getWebhookByID)
+	group.POST("/webhooks", handler.postWebhook)
+	group.PUT("/webhooks/:id", handler.updateWebhook)
+	group.DELETE("/webhooks/:id", handler.deleteWebhook)
+	group.GET("/events", handler.streamEvents)
+	group.GET("/ws", handler.streamWebSocket)
+	group.GET("/openapi.json", getOpenAPI(version))
+
+	admin := group.Group("/admin", handler.requireAdmin)
+	admin.DELETE("/books", handler.purgeBooks)
+}
+
 // getBooks responds with the list of all books as JSON.
 func (handler *Handler) getBooks(c *gin.Context) {
 	filter := c.Request.URL.Query()
+	selection, err := parseSelection(filter, bookRepresentation(c))
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid fields"})
+		return
+	}
 	if len(filter) != 0 {
 		switch len(filter) {
 		case 1:
 			if filter.Has("genre") {
 				genre, err := strconv.Atoi(filter.Get("genre"))
 				if err != nil || genre < 1 || genre > 3 {
-					log.Println(err.Error())
+					log.Println("invalid genre: ", filter.Get("genre"))
 					c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid filter condition"})
 					return
 				}
@@ -60,7 +132,7 @@ func (handler *Handler) getBooks(c *gin.Context) {
 			}
 			genre, err := strconv.Atoi(filter.Get("genre"))
 			if err != nil || genre < 1 || genre > 3 {
-				log.Println(err.Error())
+				log.Println("invalid genre: ", filter.Get("genre"))
 				c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid filter condition"})
 				return
 			}
@@ -71,14 +143,20 @@ func (handler *Handler) getBooks(c *gin.Context) {
 			return
 		}
 	}
-	list, err := handler.DataBase.GetAllBooks(filter)
-
+	if handler.notModified(c) {
+		return
+	}
+	list, err := handler.getSelectedBooks(filter, selection)
 	if err != nil {
 		log.Println(err.Error())
 		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorMessage{"internal server error"})
 		return
 	}
-	c.JSON(http.StatusOK, list)
+	if !selection.all() {
+		handler.renderSelection(c, selection, list, false)
+		return
+	}
+	renderPricedBooks(c, list)
 }
 
 // postBook adds an book from JSON received in the request body.
@@ -88,14 +166,17 @@ func (handler *Handler) postBook(c *gin.Context) {
 	// Call BindJSON to bind the received JSON to
 	// newBook.
 
-	if err := c.BindJSON(&newBook); err != nil {
+	if err := bindBook(c, &newBook); err != nil {
 		log.Println(err.Error())
 		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
 		return
 	}
+	if newBook.Currency == "" {
+		newBook.Currency = models.DefaultCurrency
+	}
 
 	// Add the new book to the slice.
-	id, err := handler.DataBase.AddBook(newBook)
+	id, err := handler.DataBase.AddBook(newBook, auditInfo(c))
 	if err != nil {
 		if strings.Contains(err.Error(), "duplicate key value") { //unique_violation
 			log.Println(err.Error())
@@ -106,8 +187,9 @@ func (handler *Handler) postBook(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorMessage{"internal server error"})
 		return
 	}
-	c.JSON(http.StatusOK, map[string]interface{}{
-		"id": id})
+	newBook.ID = id
+	handler.publish(models.EventBookCreated, id, newBook)
+	respond(c, http.StatusOK, "book", createdBook{ID: id})
 }
 
 func (handler *Handler) deleteBook(c *gin.Context) {
@@ -119,7 +201,8 @@ func (handler *Handler) deleteBook(c *gin.Context) {
 		return
 	}
 
-	err = handler.DataBase.DelBook(id)
+	deleted := handler.Events.DeletedBook(handler.DataBase, id)
+	err = handler.DataBase.DelBook(id, auditInfo(c))
 	if err != nil {
 		switch err {
 		case sql.ErrNoRows:
@@ -131,9 +214,40 @@ func (handler *Handler) deleteBook(c *gin.Context) {
 		}
 		return
 	}
+	handler.publish(models.EventBookDeleted, id, deleted)
 	c.AbortWithStatus(http.StatusNoContent)
 }
 
+// restoreBook brings back a soft-deleted book and responds with it.
+func (handler *Handler) restoreBook(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
+		return
+	}
+
+	book, err := handler.DataBase.RestoreBook(id, auditInfo(c))
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key value") { //unique_violation
+			log.Println(err.Error())
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorMessage{"input book name is not unique"})
+			return
+		}
+		switch err {
+		case sql.ErrNoRows:
+			log.Println(err.Error())
+			c.AbortWithStatusJSON(http.StatusNotFound, ErrorMessage{"id not found"})
+		default:
+			log.Println(err.Error())
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorMessage{"internal server error"})
+		}
+		return
+	}
+	renderBook(c, book)
+}
+
 func (handler *Handler) updateBook(c *gin.Context) {
 	var newBook models.Book
 
@@ -145,13 +259,16 @@ func (handler *Handler) updateBook(c *gin.Context) {
 		return
 	}
 
-	if err := c.BindJSON(&newBook); err != nil {
+	if err := bindBook(c, &newBook); err != nil {
 		log.Println(err.Error())
 		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
 		return
 	}
+	if newBook.Currency == "" {
+		newBook.Currency = models.DefaultCurrency
+	}
 
-	err = handler.DataBase.UpdateBook(id, newBook)
+	err = handler.DataBase.UpdateBook(id, newBook, auditInfo(c))
 	newBook.ID = id
 	if err != nil {
 		if strings.Contains(err.Error(), "duplicate key value") { //unique_violation
@@ -169,7 +286,20 @@ func (handler *Handler) updateBook(c *gin.Context) {
 		}
 		return
 	}
-	c.JSON(http.StatusOK, newBook)
+	handler.publish(models.EventBookUpdated, id, newBook)
+	if apiVersion(c) < 2 {
+		renderBook(c, newBook)
+		return
+	}
+
+	// The stored book has the authors kept by the update and the timestamps.
+	book, err := handler.DataBase.GetBookById(id)
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorMessage{"internal server error"})
+		return
+	}
+	renderBook(c, book)
 }
 
 // getBookByID locates the book whose ID value matches the id
@@ -182,11 +312,26 @@ func (handler *Handler) getBookByID(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
 		return
 	}
+	selection, err := parseSelection(c.Request.URL.Query(), bookRepresentation(c))
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid fields"})
+		return
+	}
+
+	if handler.notModified(c) {
+		return
+	}
 
 	// Loop through the list of books, looking for
 	// an book whose ID value matches the parameter.
 
-	book, err := handler.DataBase.GetBookById(id)
+	var book models.Book
+	if selection.fields == nil {
+		book, err = handler.DataBase.GetBookById(id)
+	} else {
+		book, err = handler.DataBase.GetBookByIdFields(id, selection.columns())
+	}
 
 	if err != nil {
 		switch err {
@@ -199,5 +344,16 @@ func (handler *Handler) getBookByID(c *gin.Context) {
 		}
 		return
 	}
-	c.JSON(http.StatusOK, book)
+
+	list, err := handler.priceSelected([]models.Book{book}, selection)
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorMessage{"internal server error"})
+		return
+	}
+	if !selection.all() {
+		handler.renderSelection(c, selection, list, true)
+		return
+	}
+	renderPricedBook(c, list[0])
 }
@@ -13,6 +13,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 )
 
 func TestApiPostBook(t *testing.T) {
@@ -30,13 +31,14 @@ func TestApiPostBook(t *testing.T) {
 			name:      "Ok",
 			inputBody: `{"name": "Book", "price": 0, "genre": 1, "amount": 0}`,
 			inputBook: models.Book{
-				Name:   "Book",
-				Price:  0,
-				Genre:  1,
-				Amount: 0,
+				Name:     "Book",
+				Price:    models.MustParseDecimal("0"),
+				Currency: "USD",
+				Genre:    1,
+				Amount:   0,
 			},
 			mockBehavior: func(r *MockDatabase, book models.Book) {
-				r.EXPECT().AddBook(book).Return(1, nil)
+				r.EXPECT().AddBook(book, gomock.Any()).Return(1, nil)
 			},
 			expectedStatusCode:   http.StatusOK,
 			expectedResponseBody: `{"id":1}`,
@@ -91,17 +93,62 @@ func TestApiPostBook(t *testing.T) {
 			expectedStatusCode:   http.StatusBadRequest,
 			expectedResponseBody: `{"error":"invalid input"}`,
 		},
+		{
+			name:      "Ok with string price and currency",
+			inputBody: `{"name": "Book", "price": "10.5", "currency": "EUR", "genre": 1, "amount": 1}`,
+			inputBook: models.Book{
+				Name:     "Book",
+				Price:    models.MustParseDecimal("10.5"),
+				Currency: "EUR",
+				Genre:    1,
+				Amount:   1,
+			},
+			mockBehavior: func(r *MockDatabase, book models.Book) {
+				r.EXPECT().AddBook(book, gomock.Any()).Return(2, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{"id":2}`,
+		},
+		{
+			name:                 "Too many decimal places",
+			inputBody:            `{"name": "Book", "price": "10.505", "genre": 1, "amount": 1}`,
+			mockBehavior:         func(r *MockDatabase, book models.Book) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"invalid input"}`,
+		},
+		{
+			name:                 "Fraction of a yen",
+			inputBody:            `{"name": "Book", "price": "100.5", "currency": "JPY", "genre": 1, "amount": 1}`,
+			mockBehavior:         func(r *MockDatabase, book models.Book) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"invalid input"}`,
+		},
+		{
+			name:                 "Unknown currency",
+			inputBody:            `{"name": "Book", "price": "1", "currency": "XYZ", "genre": 1, "amount": 1}`,
+			mockBehavior:         func(r *MockDatabase, book models.Book) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"invalid input"}`,
+		},
+		{
+			name:                 "Negative string price",
+			inputBody:            `{"name": "Book", "price": "-1.00", "genre": 1, "amount": 1}`,
+			mockBehavior:         func(r *MockDatabase, book models.Book) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"invalid input"}`,
+		},
 		{
 			name:      "Ununique name",
 			inputBody: `{"name": "repeated_test", "price": 1, "genre": 1, "amount": 1}`,
 			inputBook: models.Book{
-				Name:   "repeated_test",
-				Price:  1,
-				Genre:  1,
-				Amount: 1,
+				Name:     "repeated_test",
+				Price:    models.MustParseDecimal("1"),
+				Currency: "USD",
+				Genre:    1,
+				Amount:   1,
 			},
 			mockBehavior: func(r *MockDatabase, book models.Book) {
-				r.EXPECT().AddBook(book).Return(0, errors.New("duplicate key value"))
+				r.EXPECT().AddBook(book, gomock.Any()).Return(0, errors.New("duplicate key value"))
 			},
 			expectedStatusCode:   http.StatusInternalServerError,
 			expectedResponseBody: `{"error":"input book name is not unique"}`,
@@ -148,10 +195,12 @@ func TestAPIGetByID(t *testing.T) {
 			name:    "OK",
 			inputID: 1,
 			mockBehavior: func(r *MockDatabase, id interface{}) {
-				r.EXPECT().GetBookById(id).Return(models.Book{ID: 1, Name: "OK", Price: 1, Genre: 1, Amount: 1}, nil)
+				r.EXPECT().GetCatalogModified().Return(catalogModified, nil)
+				r.EXPECT().GetBookById(id).Return(models.Book{ID: 1, Name: "OK", Price: models.MustParseDecimal("1"), Currency: "USD", Genre: 1, Amount: 1}, nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{}, nil)
 			},
 			expectedStatusCode:   http.StatusOK,
-			expectedResponseBody: `{"id":1,"name":"OK","price":1,"genre":1,"amount":1}`,
+			expectedResponseBody: `{"id":1,"name":"OK","price":"1","currency":"USD","genre":1,"amount":1,"effective_price":"1.00"}`,
 		},
 		{
 			name:                 "invalid id",
@@ -164,6 +213,7 @@ func TestAPIGetByID(t *testing.T) {
 			name:    "id not found",
 			inputID: 256,
 			mockBehavior: func(r *MockDatabase, id interface{}) {
+				r.EXPECT().GetCatalogModified().Return(catalogModified, nil)
 				r.EXPECT().GetBookById(id).Return(models.Book{}, sql.ErrNoRows)
 			},
 			expectedStatusCode:   http.StatusNotFound,
@@ -210,7 +260,7 @@ func TestAPIDelByID(t *testing.T) {
 			name:    "OK",
 			inputID: 1,
 			mockBehavior: func(r *MockDatabase, id interface{}) {
-				r.EXPECT().DelBook(id).Return(nil)
+				r.EXPECT().DelBook(id, gomock.Any()).Return(nil)
 			},
 			expectedStatusCode:   http.StatusNoContent,
 			expectedResponseBody: ``,
@@ -226,7 +276,7 @@ func TestAPIDelByID(t *testing.T) {
 			name:    "id not found",
 			inputID: 256,
 			mockBehavior: func(r *MockDatabase, id interface{}) {
-				r.EXPECT().DelBook(id).Return(sql.ErrNoRows)
+				r.EXPECT().DelBook(id, gomock.Any()).Return(sql.ErrNoRows)
 			},
 			expectedStatusCode:   http.StatusNotFound,
 			expectedResponseBody: `{"error":"id not found"}`,
@@ -273,13 +323,13 @@ func TestAPIUpdate(t *testing.T) {
 		{
 			name:      "OK",
 			inputID:   1,
-			inputBook: models.Book{Name: "Updated", Price: 1, Genre: 1, Amount: 1},
-			inputBody: `{"name":"Updated","price":1,"genre":1,"amount":1}`,
+			inputBook: models.Book{Name: "Updated", Price: models.MustParseDecimal("1"), Currency: "USD", Genre: 1, Amount: 1},
+			inputBody: `{"name":"Updated","price":"1","currency":"USD","genre":1,"amount":1}`,
 			mockBehavior: func(r *MockDatabase, id interface{}, book models.Book) {
-				r.EXPECT().UpdateBook(id, book).Return(nil)
+				r.EXPECT().UpdateBook(id, book, gomock.Any()).Return(nil)
 			},
 			expectedStatusCode:   http.StatusOK,
-			expectedResponseBody: `{"id":1,"name":"Updated","price":1,"genre":1,"amount":1}`,
+			expectedResponseBody: `{"id":1,"name":"Updated","price":"1","currency":"USD","genre":1,"amount":1}`,
 		},
 		{
 			name:                 "invalid id",
@@ -291,10 +341,10 @@ func TestAPIUpdate(t *testing.T) {
 		{
 			name:      "id not found",
 			inputID:   256,
-			inputBook: models.Book{Name: "Updated", Price: 1, Genre: 1, Amount: 1},
-			inputBody: `{"name":"Updated","price":1,"genre":1,"amount":1}`,
+			inputBook: models.Book{Name: "Updated", Price: models.MustParseDecimal("1"), Currency: "USD", Genre: 1, Amount: 1},
+			inputBody: `{"name":"Updated","price":"1","currency":"USD","genre":1,"amount":1}`,
 			mockBehavior: func(r *MockDatabase, id interface{}, book models.Book) {
-				r.EXPECT().UpdateBook(id, book).Return(sql.ErrNoRows)
+				r.EXPECT().UpdateBook(id, book, gomock.Any()).Return(sql.ErrNoRows)
 			},
 			expectedStatusCode:   http.StatusNotFound,
 			expectedResponseBody: `{"error":"id not found"}`,
@@ -341,38 +391,61 @@ func TestAPIGet(t *testing.T) {
 			name:            "OK",
 			filterCondition: map[string][]string{},
 			mockBehavior: func(r *MockDatabase, filterCondition map[string][]string) {
-				r.EXPECT().GetAllBooks(filterCondition).Return([]models.Book{{ID: 1, Name: "OK", Price: 1, Genre: 1, Amount: 1},
-					{ID: 1, Name: "OK2", Price: 1, Genre: 2, Amount: 1}}, nil)
+				r.EXPECT().GetCatalogModified().Return(catalogModified, nil)
+				r.EXPECT().GetAllBooks(filterCondition).Return([]models.Book{{ID: 1, Name: "OK", Price: models.MustParseDecimal("1"), Currency: "USD", Genre: 1, Amount: 1},
+					{ID: 1, Name: "OK2", Price: models.MustParseDecimal("1"), Currency: "USD", Genre: 2, Amount: 1}}, nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{}, nil)
 			},
-			expectedStatusCode:   http.StatusOK,
-			expectedResponseBody: `[{"id":1,"name":"OK","price":1,"genre":1,"amount":1},{"id":1,"name":"OK2","price":1,"genre":2,"amount":1}]`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponseBody: `[{"id":1,"name":"OK","price":"1","currency":"USD","genre":1,"amount":1,"effective_price":"1.00"},` +
+				`{"id":1,"name":"OK2","price":"1","currency":"USD","genre":2,"amount":1,"effective_price":"1.00"}]`,
 		},
 		{
 			name:            "OK with genre filter",
 			filterCondition: map[string][]string{"genre": {"1"}},
 			mockBehavior: func(r *MockDatabase, filterCondition map[string][]string) {
-				r.EXPECT().GetAllBooks(filterCondition).Return([]models.Book{{ID: 1, Name: "OK", Price: 1, Genre: 1, Amount: 1}}, nil)
+				r.EXPECT().GetCatalogModified().Return(catalogModified, nil)
+				r.EXPECT().GetAllBooks(filterCondition).Return([]models.Book{{ID: 1, Name: "OK", Price: models.MustParseDecimal("1"), Currency: "USD", Genre: 1, Amount: 1}}, nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{}, nil)
 			},
 			expectedStatusCode:   http.StatusOK,
-			expectedResponseBody: `[{"id":1,"name":"OK","price":1,"genre":1,"amount":1}]`,
+			expectedResponseBody: `[{"id":1,"name":"OK","price":"1","currency":"USD","genre":1,"amount":1,"effective_price":"1.00"}]`,
 		},
 		{
 			name:            "OK with name filter",
 			filterCondition: map[string][]string{"name": {"OK"}},
 			mockBehavior: func(r *MockDatabase, filterCondition map[string][]string) {
-				r.EXPECT().GetAllBooks(filterCondition).Return([]models.Book{{ID: 1, Name: "OK", Price: 1, Genre: 1, Amount: 1}}, nil)
+				r.EXPECT().GetCatalogModified().Return(catalogModified, nil)
+				r.EXPECT().GetAllBooks(filterCondition).Return([]models.Book{{ID: 1, Name: "OK", Price: models.MustParseDecimal("1"), Currency: "USD", Genre: 1, Amount: 1}}, nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{}, nil)
 			},
 			expectedStatusCode:   http.StatusOK,
-			expectedResponseBody: `[{"id":1,"name":"OK","price":1,"genre":1,"amount":1}]`,
+			expectedResponseBody: `[{"id":1,"name":"OK","price":"1","currency":"USD","genre":1,"amount":1,"effective_price":"1.00"}]`,
 		},
 		{
 			name:            "OK with both filter",
 			filterCondition: map[string][]string{"name": {"OK"}, "genre": {"1"}},
 			mockBehavior: func(r *MockDatabase, filterCondition map[string][]string) {
-				r.EXPECT().GetAllBooks(filterCondition).Return([]models.Book{{ID: 1, Name: "OK", Price: 1, Genre: 1, Amount: 1}}, nil)
+				r.EXPECT().GetCatalogModified().Return(catalogModified, nil)
+				r.EXPECT().GetAllBooks(filterCondition).Return([]models.Book{{ID: 1, Name: "OK", Price: models.MustParseDecimal("1"), Currency: "USD", Genre: 1, Amount: 1}}, nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{}, nil)
 			},
 			expectedStatusCode:   http.StatusOK,
-			expectedResponseBody: `[{"id":1,"name":"OK","price":1,"genre":1,"amount":1}]`,
+			expectedResponseBody: `[{"id":1,"name":"OK","price":"1","currency":"USD","genre":1,"amount":1,"effective_price":"1.00"}]`,
+		},
+		{
+			name:            "OK with genre promotion",
+			filterCondition: map[string][]string{},
+			mockBehavior: func(r *MockDatabase, filterCondition map[string][]string) {
+				r.EXPECT().GetCatalogModified().Return(catalogModified, nil)
+				r.EXPECT().GetAllBooks(filterCondition).Return([]models.Book{{ID: 1, Name: "OK", Price: models.MustParseDecimal("10"), Currency: "USD", Genre: 1, Amount: 1},
+					{ID: 2, Name: "OK2", Price: models.MustParseDecimal("10"), Currency: "USD", Genre: 2, Amount: 1}}, nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{{ID: 3, Name: "Sale", Kind: models.PromotionPercentage,
+					Value: models.MustParseDecimal("15"), Genre: 1, StartsAt: time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)}}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedResponseBody: `[{"id":1,"name":"OK","price":"10","currency":"USD","genre":1,"amount":1,"effective_price":"8.50","applied_promotions":[3]},` +
+				`{"id":2,"name":"OK2","price":"10","currency":"USD","genre":2,"amount":1,"effective_price":"10.00"}]`,
 		},
 		{
 			name:                 "invalid filter",
@@ -381,6 +454,13 @@ func TestAPIGet(t *testing.T) {
 			expectedStatusCode:   http.StatusBadRequest,
 			expectedResponseBody: `{"error":"invalid filter condition"}`,
 		},
+		{
+			name:                 "genre out of range",
+			filterCondition:      map[string][]string{"genre": {"4"}, "name": {"OK"}},
+			mockBehavior:         func(r *MockDatabase, filterCondition map[string][]string) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"invalid filter condition"}`,
+		},
 		{
 			name:                 "another invalid filter",
 			filterCondition:      map[string][]string{"some_filter_name": {"1"}},
@@ -416,3 +496,73 @@ func TestAPIGet(t *testing.T) {
 		})
 	}
 }
+
+func TestAPIRestore(t *testing.T) {
+	type mockBehavior func(s *MockDatabase, id interface{})
+	gin.SetMode(gin.ReleaseMode)
+	tests := []struct {
+		name                 string
+		inputID              interface{}
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:    "OK",
+			inputID: 1,
+			mockBehavior: func(r *MockDatabase, id interface{}) {
+				r.EXPECT().RestoreBook(id, gomock.Any()).Return(models.Book{ID: 1, Name: "OK", Price: models.MustParseDecimal("1"), Currency: "USD", Genre: 1, Amount: 1}, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{"id":1,"name":"OK","price":"1","currency":"USD","genre":1,"amount":1}`,
+		},
+		{
+			name:                 "invalid id",
+			inputID:              "invalid",
+			mockBehavior:         func(r *MockDatabase, id interface{}) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"invalid input"}`,
+		},
+		{
+			name:    "id not found",
+			inputID: 256,
+			mockBehavior: func(r *MockDatabase, id interface{}) {
+				r.EXPECT().RestoreBook(id, gomock.Any()).Return(models.Book{}, sql.ErrNoRows)
+			},
+			expectedStatusCode:   http.StatusNotFound,
+			expectedResponseBody: `{"error":"id not found"}`,
+		},
+		{
+			name:    "name taken",
+			inputID: 2,
+			mockBehavior: func(r *MockDatabase, id interface{}) {
+				r.EXPECT().RestoreBook(id, gomock.Any()).Return(models.Book{}, errors.New("duplicate key value"))
+			},
+			expectedStatusCode:   http.StatusInternalServerError,
+			expectedResponseBody: `{"error":"input book name is not unique"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			db := NewMockDatabase(c)
+			test.mockBehavior(db, test.inputID)
+			rest_api := Handler{Router: gin.Default(), DataBase: db}
+
+			r := gin.New()
+			r.POST("/books/:id/restore", rest_api.restoreBook)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", fmt.Sprintf("/books/%v/restore", test.inputID), nil)
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			assert.Equal(t, test.expectedResponseBody, w.Body.String())
+		})
+	}
+}
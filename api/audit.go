@@ -0,0 +1,131 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/porky256/rest-api/models"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	requestIDHeader = "X-Request-ID"
+	actorHeader     = "X-Actor"
+	requestIDKey    = "request_id"
+	anonymousActor  = "anonymous"
+)
+
+var errInvalidAction = errors.New("invalid audit action")
+
+// requestID makes sure every request carries an ID, reusing the one sent by the client.
+func requestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if id == "" {
+			id = newRequestID()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(requestIDHeader, id)
+		c.Next()
+	}
+}
+
+func newRequestID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		log.Println(err.Error())
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// auditInfo collects the actor and request ID to be stored with a mutation.
+func auditInfo(c *gin.Context) models.AuditInfo {
+	info := models.AuditInfo{
+		Actor:     c.GetHeader(actorHeader),
+		RequestID: c.GetString(requestIDKey),
+	}
+	if info.Actor == "" {
+		info.Actor = anonymousActor
+	}
+	if info.RequestID == "" {
+		info.RequestID = c.GetHeader(requestIDHeader)
+	}
+	return info
+}
+
+// getBookHistory responds with every recorded mutation of a single book.
+func (handler *Handler) getBookHistory(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
+		return
+	}
+
+	list, err := handler.DataBase.GetBookHistory(id)
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorMessage{"internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// getAuditLog responds with the audit log narrowed by the query filters
+// book_id, actor, action, from, to (RFC 3339) and limit.
+func (handler *Handler) getAuditLog(c *gin.Context) {
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid filter condition"})
+		return
+	}
+
+	list, err := handler.DataBase.GetAuditLog(filter)
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorMessage{"internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+func parseAuditFilter(c *gin.Context) (models.AuditFilter, error) {
+	var filter models.AuditFilter
+	var err error
+	if value := c.Query("book_id"); value != "" {
+		if filter.BookID, err = strconv.Atoi(value); err != nil {
+			return filter, err
+		}
+	}
+	if value := c.Query("limit"); value != "" {
+		if filter.Limit, err = strconv.Atoi(value); err != nil {
+			return filter, err
+		}
+	}
+	if value := c.Query("from"); value != "" {
+		if filter.From, err = time.Parse(time.RFC3339, value); err != nil {
+			return filter, err
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		if filter.To, err = time.Parse(time.RFC3339, value); err != nil {
+			return filter, err
+		}
+	}
+	filter.Actor = c.Query("actor")
+	filter.Action = c.Query("action")
+	switch filter.Action {
+	case "", models.AuditActionCreate, models.AuditActionUpdate, models.AuditActionDelete,
+		models.AuditActionRestore, models.AuditActionPurge:
+	default:
+		return filter, errInvalidAction
+	}
+	return filter, nil
+}
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAPIGetBookHistory(t *testing.T) {
+	type mockBehavior func(s *MockDatabase, id interface{})
+	gin.SetMode(gin.ReleaseMode)
+	created := time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name                 string
+		inputID              interface{}
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:    "OK",
+			inputID: 1,
+			mockBehavior: func(r *MockDatabase, id interface{}) {
+				r.EXPECT().GetBookHistory(id).Return([]models.AuditEntry{{ID: 2, BookID: 1, Action: models.AuditActionDelete,
+					Actor: "tester", RequestID: "abc", Before: []byte(`{"id":1}`),
+					Diff: map[string]models.AuditChange{"id": {Before: []byte(`1`)}}, CreatedAt: created}}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedResponseBody: `[{"id":2,"book_id":1,"action":"delete","actor":"tester","request_id":"abc",` +
+				`"before":{"id":1},"after":null,"diff":{"id":{"before":1,"after":null}},"created_at":"2021-11-20T10:00:00Z"}]`,
+		},
+		{
+			name:                 "invalid id",
+			inputID:              "invalid",
+			mockBehavior:         func(r *MockDatabase, id interface{}) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"invalid input"}`,
+		},
+		{
+			name:    "database error",
+			inputID: 1,
+			mockBehavior: func(r *MockDatabase, id interface{}) {
+				r.EXPECT().GetBookHistory(id).Return(nil, errors.New("database error"))
+			},
+			expectedStatusCode:   http.StatusInternalServerError,
+			expectedResponseBody: `{"error":"internal server error"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			db := NewMockDatabase(c)
+			test.mockBehavior(db, test.inputID)
+			rest_api := Handler{Router: gin.Default(), DataBase: db}
+
+			r := gin.New()
+			r.GET("/books/:id/history", rest_api.getBookHistory)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", fmt.Sprintf("/books/%v/history", test.inputID), nil)
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			assert.Equal(t, test.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestAPIGetAuditLog(t *testing.T) {
+	type mockBehavior func(s *MockDatabase, filter models.AuditFilter)
+	gin.SetMode(gin.ReleaseMode)
+	tests := []struct {
+		name                 string
+		query                string
+		filter               models.AuditFilter
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:   "OK with filters",
+			query:  "book_id=1&actor=tester&action=update&from=2021-11-20T10:00:00Z&limit=5",
+			filter: models.AuditFilter{BookID: 1, Actor: "tester", Action: models.AuditActionUpdate, From: time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC), Limit: 5},
+			mockBehavior: func(r *MockDatabase, filter models.AuditFilter) {
+				r.EXPECT().GetAuditLog(filter).Return([]models.AuditEntry{}, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `[]`,
+		},
+		{
+			name:                 "invalid action",
+			query:                "action=drop",
+			mockBehavior:         func(r *MockDatabase, filter models.AuditFilter) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"invalid filter condition"}`,
+		},
+		{
+			name:                 "invalid time",
+			query:                "from=yesterday",
+			mockBehavior:         func(r *MockDatabase, filter models.AuditFilter) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"invalid filter condition"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			db := NewMockDatabase(c)
+			test.mockBehavior(db, test.filter)
+			rest_api := Handler{Router: gin.Default(), DataBase: db}
+
+			r := gin.New()
+			r.GET("/audit", rest_api.getAuditLog)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/audit?"+test.query, nil)
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			assert.Equal(t, test.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestAPIAuditInfo(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	db := NewMockDatabase(c)
+	db.EXPECT().DelBook(1, models.AuditInfo{Actor: "tester", RequestID: "req-1"}).Return(nil)
+	rest_api := Handler{Router: gin.Default(), DataBase: db}
+
+	r := gin.New()
+	r.Use(requestID())
+	r.DELETE("/books/:id", rest_api.deleteBook)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("DELETE", "/books/1", nil)
+	req.Header.Set(actorHeader, "tester")
+	req.Header.Set(requestIDHeader, "req-1")
+
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "req-1", w.Header().Get(requestIDHeader))
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// notModified sets the caching headers for book reads and answers with
+// 304 Not Modified when the client's copy is still current. Clients have
+// to revalidate every time since effective prices change with promotions.
+// The ETag is strong, made of the catalog's modification time to the
+// nanosecond and the negotiated format. Last-Modified only has seconds, so
+// If-Modified-Since is only answered with 304 once the second it names has
+// passed the modification.
+func (handler *Handler) notModified(c *gin.Context) bool {
+	modified, err := handler.DataBase.GetCatalogModified()
+	if err != nil {
+		log.Println(err.Error())
+		return false
+	}
+	modified = modified.UTC()
+	etag := catalogETag(modified, negotiate(c.GetHeader("Accept")))
+	c.Header("Cache-Control", "no-cache")
+	c.Header("ETag", etag)
+	c.Header("Last-Modified", modified.Format(http.TimeFormat))
+
+	if match := c.GetHeader("If-None-Match"); match != "" {
+		if !etagMatches(match, etag) {
+			return false
+		}
+	} else {
+		since, err := http.ParseTime(c.GetHeader("If-Modified-Since"))
+		if err != nil || !modified.Before(since) {
+			return false
+		}
+	}
+	c.AbortWithStatus(http.StatusNotModified)
+	return true
+}
+
+func catalogETag(modified time.Time, format string) string {
+	return `"` + strconv.FormatInt(modified.UnixNano(), 36) + "-" + format + `"`
+}
+
+// etagMatches tells if an If-None-Match header lists the ETag, comparing
+// weakly as RFC 9110 asks for.
+func etagMatches(header string, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var catalogModified = time.Date(2021, 11, 20, 10, 0, 0, 500, time.UTC)
+
+func TestAPINotModified(t *testing.T) {
+	type mockBehavior func(s *MockDatabase)
+	gin.SetMode(gin.ReleaseMode)
+	etag := `"` + strconv.FormatInt(catalogModified.UnixNano(), 36) + `-application/json"`
+	tests := []struct {
+		name                 string
+		path                 string
+		ifModifiedSince      string
+		ifNoneMatch          string
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedLastModified string
+		expectedETag         string
+	}{
+		{
+			name:        "book not modified",
+			path:        "/books/1",
+			ifNoneMatch: etag,
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetCatalogModified().Return(catalogModified, nil)
+			},
+			expectedStatusCode:   http.StatusNotModified,
+			expectedLastModified: "Sat, 20 Nov 2021 10:00:00 GMT",
+			expectedETag:         etag,
+		},
+		{
+			name:        "one of several ETags",
+			path:        "/books",
+			ifNoneMatch: `"other", W/` + etag,
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetCatalogModified().Return(catalogModified, nil)
+			},
+			expectedStatusCode:   http.StatusNotModified,
+			expectedLastModified: "Sat, 20 Nov 2021 10:00:00 GMT",
+			expectedETag:         etag,
+		},
+		{
+			name:        "modified in the same second",
+			path:        "/books/1",
+			ifNoneMatch: `"` + strconv.FormatInt(catalogModified.Truncate(time.Second).UnixNano(), 36) + `-application/json"`,
+			// If-None-Match wins over If-Modified-Since.
+			ifModifiedSince: "Sat, 20 Nov 2021 11:00:00 GMT",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetCatalogModified().Return(catalogModified, nil)
+				r.EXPECT().GetBookById(1).Return(models.Book{ID: 1, Name: "OK", Price: models.MustParseDecimal("1"), Currency: "USD", Genre: 1, Amount: 1}, nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{}, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedLastModified: "Sat, 20 Nov 2021 10:00:00 GMT",
+			expectedETag:         etag,
+		},
+		{
+			name:            "list not modified",
+			path:            "/books",
+			ifModifiedSince: "Sat, 20 Nov 2021 11:00:00 GMT",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetCatalogModified().Return(catalogModified, nil)
+			},
+			expectedStatusCode:   http.StatusNotModified,
+			expectedLastModified: "Sat, 20 Nov 2021 10:00:00 GMT",
+			expectedETag:         etag,
+		},
+		{
+			name:            "modified within the second since",
+			path:            "/books/1",
+			ifModifiedSince: "Sat, 20 Nov 2021 10:00:00 GMT",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetCatalogModified().Return(catalogModified, nil)
+				r.EXPECT().GetBookById(1).Return(models.Book{ID: 1, Name: "OK", Price: models.MustParseDecimal("1"), Currency: "USD", Genre: 1, Amount: 1}, nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{}, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedLastModified: "Sat, 20 Nov 2021 10:00:00 GMT",
+			expectedETag:         etag,
+		},
+		{
+			name:            "modified",
+			path:            "/books/1",
+			ifModifiedSince: "Sat, 20 Nov 2021 09:59:59 GMT",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetCatalogModified().Return(catalogModified, nil)
+				r.EXPECT().GetBookById(1).Return(models.Book{ID: 1, Name: "OK", Price: models.MustParseDecimal("1"), Currency: "USD", Genre: 1, Amount: 1}, nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{}, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedLastModified: "Sat, 20 Nov 2021 10:00:00 GMT",
+			expectedETag:         etag,
+		},
+		{
+			name:            "invalid date",
+			path:            "/books",
+			ifModifiedSince: "yesterday",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetCatalogModified().Return(catalogModified, nil)
+				r.EXPECT().GetAllBooks(gomock.Any()).Return([]models.Book{}, nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{}, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedLastModified: "Sat, 20 Nov 2021 10:00:00 GMT",
+			expectedETag:         etag,
+		},
+		{
+			name:            "modification time unavailable",
+			path:            "/books/1",
+			ifModifiedSince: "Sat, 20 Nov 2021 10:00:00 GMT",
+			ifNoneMatch:     etag,
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetCatalogModified().Return(time.Time{}, errors.New("connection refused"))
+				r.EXPECT().GetBookById(1).Return(models.Book{ID: 1, Name: "OK", Price: models.MustParseDecimal("1"), Currency: "USD", Genre: 1, Amount: 1}, nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			db := NewMockDatabase(c)
+			test.mockBehavior(db)
+			rest_api := Handler{Router: gin.Default(), DataBase: db}
+
+			r := gin.New()
+			r.GET("/books", rest_api.getBooks)
+			r.GET("/books/:id", rest_api.getBookByID)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", test.path, nil)
+			req.Header.Set("If-Modified-Since", test.ifModifiedSince)
+			req.Header.Set("If-None-Match", test.ifNoneMatch)
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			assert.Equal(t, test.expectedLastModified, w.Header().Get("Last-Modified"))
+			assert.Equal(t, test.expectedETag, w.Header().Get("ETag"))
+			if test.expectedStatusCode == http.StatusNotModified {
+				assert.Empty(t, w.Body.String())
+			}
+		})
+	}
+}
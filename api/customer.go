@@ -0,0 +1,130 @@
+package api
+
+import (
+	"database/sql"
+	"github.com/gin-gonic/gin"
+	"github.com/porky256/rest-api/models"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func (handler *Handler) getCustomers(c *gin.Context) {
+	list, err := handler.DataBase.GetAllCustomers()
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorMessage{"internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+func (handler *Handler) getCustomerByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
+		return
+	}
+
+	customer, err := handler.DataBase.GetCustomerById(id)
+	if err != nil {
+		customerError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, customer)
+}
+
+// postCustomer adds a customer from JSON received in the request body.
+func (handler *Handler) postCustomer(c *gin.Context) {
+	var newCustomer models.Customer
+
+	if err := c.BindJSON(&newCustomer); err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
+		return
+	}
+
+	customer, err := handler.DataBase.AddCustomer(newCustomer)
+	if err != nil {
+		customerError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, customer)
+}
+
+func (handler *Handler) updateCustomer(c *gin.Context) {
+	var newCustomer models.Customer
+
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
+		return
+	}
+
+	if err := c.BindJSON(&newCustomer); err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
+		return
+	}
+
+	customer, err := handler.DataBase.UpdateCustomer(id, newCustomer)
+	if err != nil {
+		customerError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, customer)
+}
+
+func (handler *Handler) deleteCustomer(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
+		return
+	}
+
+	err = handler.DataBase.DelCustomer(id)
+	if err != nil {
+		customerError(c, err)
+		return
+	}
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+// getCustomerOrders responds with the order history of a customer.
+func (handler *Handler) getCustomerOrders(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
+		return
+	}
+
+	list, err := handler.DataBase.GetCustomerOrders(id)
+	if err != nil {
+		customerError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+func customerError(c *gin.Context, err error) {
+	log.Println(err.Error())
+	if strings.Contains(err.Error(), "duplicate key value") { //unique_violation
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorMessage{"input customer email is not unique"})
+		return
+	}
+	switch err {
+	case sql.ErrNoRows:
+		c.AbortWithStatusJSON(http.StatusNotFound, ErrorMessage{"id not found"})
+	default:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorMessage{"internal server error"})
+	}
+}
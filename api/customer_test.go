@@ -0,0 +1,156 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAPIPostCustomer(t *testing.T) {
+	type mockBehavior func(s *MockDatabase, customer models.Customer)
+	gin.SetMode(gin.ReleaseMode)
+	created := time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name                 string
+		inputBody            string
+		inputCustomer        models.Customer
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:          "OK",
+			inputBody:     `{"name":"Ann","email":"ann@example.com","address":{"city":"Oslo","country":"NO"}}`,
+			inputCustomer: models.Customer{Name: "Ann", Email: "ann@example.com", Address: models.Address{City: "Oslo", Country: "NO"}},
+			mockBehavior: func(r *MockDatabase, customer models.Customer) {
+				customer.ID = 1
+				customer.CreatedAt = created
+				r.EXPECT().AddCustomer(gomock.Any()).Return(customer, nil)
+			},
+			expectedStatusCode: http.StatusCreated,
+			expectedResponseBody: `{"id":1,"name":"Ann","email":"ann@example.com","phone":"",` +
+				`"address":{"street":"","city":"Oslo","postal_code":"","country":"NO"},"created_at":"2021-11-20T10:00:00Z"}`,
+		},
+		{
+			name:                 "Invalid email",
+			inputBody:            `{"name":"Ann","email":"ann"}`,
+			mockBehavior:         func(r *MockDatabase, customer models.Customer) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"invalid input"}`,
+		},
+		{
+			name:                 "Invalid country",
+			inputBody:            `{"name":"Ann","email":"ann@example.com","address":{"country":"Norway"}}`,
+			mockBehavior:         func(r *MockDatabase, customer models.Customer) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"invalid input"}`,
+		},
+		{
+			name:          "Ununique email",
+			inputBody:     `{"name":"Ann","email":"ann@example.com"}`,
+			inputCustomer: models.Customer{Name: "Ann", Email: "ann@example.com"},
+			mockBehavior: func(r *MockDatabase, customer models.Customer) {
+				r.EXPECT().AddCustomer(customer).Return(models.Customer{}, errors.New("duplicate key value"))
+			},
+			expectedStatusCode:   http.StatusInternalServerError,
+			expectedResponseBody: `{"error":"input customer email is not unique"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			db := NewMockDatabase(c)
+			test.mockBehavior(db, test.inputCustomer)
+			rest_api := Handler{Router: gin.Default(), DataBase: db}
+
+			r := gin.New()
+			r.POST("/customers", rest_api.postCustomer)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/customers",
+				bytes.NewBufferString(test.inputBody))
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			assert.Equal(t, test.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestAPIGetCustomerOrders(t *testing.T) {
+	type mockBehavior func(s *MockDatabase, id interface{})
+	gin.SetMode(gin.ReleaseMode)
+	created := time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name                 string
+		inputID              interface{}
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:    "OK",
+			inputID: 3,
+			mockBehavior: func(r *MockDatabase, id interface{}) {
+				r.EXPECT().GetCustomerOrders(id).Return([]models.Order{{ID: 5, CustomerID: 3, Status: models.OrderStatusPaid,
+					Items: []models.OrderItem{}, Currency: "USD", CreatedAt: created, UpdatedAt: created}}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedResponseBody: `[{"id":5,"customer_id":3,"status":"paid","items":[],"total":"0","currency":"USD",` +
+				`"created_at":"2021-11-20T10:00:00Z","updated_at":"2021-11-20T10:00:00Z"}]`,
+		},
+		{
+			name:                 "invalid id",
+			inputID:              "invalid",
+			mockBehavior:         func(r *MockDatabase, id interface{}) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"invalid input"}`,
+		},
+		{
+			name:    "id not found",
+			inputID: 256,
+			mockBehavior: func(r *MockDatabase, id interface{}) {
+				r.EXPECT().GetCustomerOrders(id).Return(nil, sql.ErrNoRows)
+			},
+			expectedStatusCode:   http.StatusNotFound,
+			expectedResponseBody: `{"error":"id not found"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			db := NewMockDatabase(c)
+			test.mockBehavior(db, test.inputID)
+			rest_api := Handler{Router: gin.Default(), DataBase: db}
+
+			r := gin.New()
+			r.GET("/customers/:id/orders", rest_api.getCustomerOrders)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", fmt.Sprintf("/customers/%v/orders", test.inputID), nil)
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			assert.Equal(t, test.expectedResponseBody, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/porky256/rest-api/models"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	lastEventIDHeader = "Last-Event-ID"
+	heartbeatInterval = 15 * time.Second
+)
+
+// publish records a book event for the stream and WebSocket subscribers,
+// unless the database change feed takes care of that.
+func (handler *Handler) publish(eventType string, bookID int, data interface{}) {
+	if handler.publishing() {
+		handler.Events.Publish(eventType, bookID, data)
+	}
+}
+
+func (handler *Handler) publishing() bool {
+	return handler.Events != nil && !handler.Events.ChangeFeed
+}
+
+// publishStockChanges reloads the books of the order items and publishes
+// their new stock.
+func (handler *Handler) publishStockChanges(items []models.OrderItem) {
+	if !handler.publishing() {
+		return
+	}
+	seen := map[int]bool{}
+	for _, item := range items {
+		if item.BookID == 0 || seen[item.BookID] {
+			continue
+		}
+		seen[item.BookID] = true
+		book, err := handler.DataBase.GetBookById(item.BookID)
+		if err != nil {
+			log.Println(err.Error())
+			continue
+		}
+		handler.publish(models.EventStockChanged, book.ID, book)
+	}
+}
+
+// streamEvents sends book events as Server-Sent Events until the client
+// goes away. Clients resuming with Last-Event-ID first get the events they
+// missed, or a reset event when those are no longer in the log.
+func (handler *Handler) streamEvents(c *gin.Context) {
+	missed, ok, events, cancel := handler.Events.Subscribe(c.GetHeader(lastEventIDHeader))
+	defer cancel()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	if !ok {
+		c.Render(-1, sse.Event{Event: models.EventReset, Data: map[string]string{}})
+	}
+	for _, event := range missed {
+		handler.renderEvent(c, event)
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, open := <-events:
+			if !open {
+				return
+			}
+			handler.renderEvent(c, event)
+		case <-heartbeat.C:
+			_, _ = c.Writer.WriteString(": heartbeat\n\n")
+		}
+		c.Writer.Flush()
+	}
+}
+
+func (handler *Handler) renderEvent(c *gin.Context, event models.Event) {
+	c.Render(-1, sse.Event{Id: handler.Events.EventID(event), Event: event.Type, Data: event})
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/porky256/rest-api/events"
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readEvents reads n events from an SSE stream and returns their raw lines.
+func readEvents(t *testing.T, reader *bufio.Reader, n int) []string {
+	var lines []string
+	for n > 0 {
+		line, err := reader.ReadString('\n')
+		if !assert.NoError(t, err) {
+			return lines
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			n--
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestAPIStreamEvents(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	log := events.NewBus(10)
+	first := log.Publish(models.EventBookCreated, 1, models.Book{ID: 1, Name: "Dune"})
+	second := log.Publish(models.EventBookDeleted, 2, map[string]int{"id": 2})
+
+	rest_api := Handler{Router: gin.Default(), Events: log}
+	r := gin.New()
+	r.GET("/events", rest_api.streamEvents)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		lastID   string
+		expected []string
+	}{
+		{
+			name:   "resume",
+			lastID: log.EventID(first),
+			expected: []string{
+				"id:" + log.EventID(second),
+				"event:book.deleted",
+			},
+		},
+		{
+			name:     "unknown id",
+			lastID:   "unknown-1",
+			expected: []string{"event:reset", "data:{}"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			req, err := http.NewRequestWithContext(ctx, "GET", server.URL+"/events", nil)
+			assert.NoError(t, err)
+			req.Header.Set(lastEventIDHeader, test.lastID)
+
+			resp, err := http.DefaultClient.Do(req)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+			lines := readEvents(t, bufio.NewReader(resp.Body), 1)
+			assert.Equal(t, test.expected, lines[:len(test.expected)])
+		})
+	}
+}
+
+func TestAPIStreamEventsLive(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	db := NewMockDatabase(c)
+	db.EXPECT().AddBook(gomock.Any(), gomock.Any()).Return(5, nil)
+	rest_api := Handler{Router: gin.Default(), DataBase: db, Events: events.NewBus(10)}
+	r := gin.New()
+	r.GET("/events", rest_api.streamEvents)
+	r.POST("/books", rest_api.postBook)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", server.URL+"/events", nil)
+	assert.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/books",
+		bytes.NewBufferString(`{"name":"Dune","price":"9.99","genre":3,"amount":2}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	lines := readEvents(t, bufio.NewReader(resp.Body), 1)
+	if assert.Len(t, lines, 3) {
+		assert.True(t, strings.HasSuffix(lines[0], "-1"))
+		assert.Equal(t, "event:book.created", lines[1])
+		assert.Contains(t, lines[2], `"type":"book.created","book_id":5,"data":{"id":5,"name":"Dune","price":"9.99"`)
+	}
+}
+
+func TestAPILeavesPublishingToChangeFeed(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	db := NewMockDatabase(c)
+	db.EXPECT().AddBook(gomock.Any(), gomock.Any()).Return(5, nil)
+	rest_api := InitializeHandler(db)
+	rest_api.Events.ChangeFeed = true
+	_, _, events, cancel := rest_api.Events.Subscribe("")
+	defer cancel()
+
+	w := httptest.NewRecorder()
+	rest_api.Router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/books",
+		bytes.NewBufferString(`{"name":"Dune","price":"9.99","genre":3,"amount":2}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	select {
+	case event := <-events:
+		t.Errorf("unexpected event %v", event)
+	default:
+	}
+}
@@ -0,0 +1,268 @@
+package api
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/porky256/rest-api/models"
+	"log"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// pricedColumns are the book columns the effective price is worked out
+// from, promotions apply to a book by its ID or genre.
+var pricedColumns = []string{"id", "price", "genre"}
+
+// fieldColumns are the book columns a field of the book representations is
+// read from.
+var fieldColumns = map[string][]string{
+	"id":                 {"id"},
+	"name":               {"name"},
+	"authors":            {"authors"},
+	"price":              {"price"},
+	"currency":           {"currency"},
+	"genre":              {"genre"},
+	"amount":             {"amount"},
+	"created_at":         {"created_at"},
+	"updated_at":         {"updated_at"},
+	"effective_price":    pricedColumns,
+	"applied_promotions": pricedColumns,
+}
+
+var genreType = reflect.TypeOf(models.Genre{})
+
+// fieldSelection is the sparse fieldset and the embedded objects a client
+// asked for with the fields and embed query parameters.
+type fieldSelection struct {
+	// fields are the JSON names of the top-level fields to render, all of
+	// them when nil.
+	fields     map[string]bool
+	embedGenre bool
+}
+
+// parseSelection reads the fields and embed query parameters and removes
+// them from the query. Fields are top-level fields of the representation,
+// the genre is the only object that can be embedded.
+func parseSelection(query url.Values, representation reflect.Type) (fieldSelection, error) {
+	var s fieldSelection
+	if query.Has("fields") {
+		s.fields = map[string]bool{}
+		known := map[string]bool{}
+		for _, field := range jsonFields(representation) {
+			known[field.name] = true
+		}
+		for _, name := range strings.Split(query.Get("fields"), ",") {
+			name = strings.TrimSpace(name)
+			if !known[name] {
+				return fieldSelection{}, fmt.Errorf("unknown field %q", name)
+			}
+			s.fields[name] = true
+		}
+	}
+	if query.Has("embed") {
+		for _, name := range strings.Split(query.Get("embed"), ",") {
+			if strings.TrimSpace(name) != "genre" {
+				return fieldSelection{}, fmt.Errorf("can't embed %q", name)
+			}
+			s.embedGenre = true
+		}
+	}
+	query.Del("fields")
+	query.Del("embed")
+	return s, nil
+}
+
+// all reports whether the whole representation is rendered as it is.
+func (s fieldSelection) all() bool {
+	return s.fields == nil && !s.embedGenre
+}
+
+func (s fieldSelection) has(name string) bool {
+	return s.fields == nil || s.fields[name]
+}
+
+// columns returns the book columns the selected fields are read from, nil
+// for all of them.
+func (s fieldSelection) columns() []string {
+	if s.fields == nil {
+		return nil
+	}
+	selected := map[string]bool{}
+	for name := range s.fields {
+		for _, column := range fieldColumns[name] {
+			selected[column] = true
+		}
+	}
+	columns := make([]string, 0, len(selected))
+	for column := range selected {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// priced reports whether a field after promotions is selected.
+func (s fieldSelection) priced() bool {
+	return s.has("effective_price") || s.has("applied_promotions")
+}
+
+// bookRepresentation is the type books are rendered as in the API version.
+func bookRepresentation(c *gin.Context) reflect.Type {
+	if apiVersion(c) < 2 {
+		return reflect.TypeOf(models.PricedBook{})
+	}
+	return reflect.TypeOf(models.BookV2{})
+}
+
+// getSelectedBooks reads the books matching the filter with the columns of
+// the selection, priced when a price after promotions is selected.
+func (handler *Handler) getSelectedBooks(filter map[string][]string, s fieldSelection) ([]models.PricedBook, error) {
+	var books []models.Book
+	var err error
+	if s.fields == nil {
+		books, err = handler.DataBase.GetAllBooks(filter)
+	} else {
+		books, err = handler.DataBase.GetAllBooksFields(filter, s.columns())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return handler.priceSelected(books, s)
+}
+
+func (handler *Handler) priceSelected(books []models.Book, s fieldSelection) ([]models.PricedBook, error) {
+	if s.priced() {
+		return handler.priceBooks(books)
+	}
+	list := make([]models.PricedBook, 0, len(books))
+	for _, book := range books {
+		list = append(list, models.PricedBook{Book: book})
+	}
+	return list, nil
+}
+
+// renderSelection responds with the selected fields of the books in the
+// representation of the API version, a single book when one is set.
+func (handler *Handler) renderSelection(c *gin.Context, s fieldSelection, books []models.PricedBook, one bool) {
+	var genres map[int]models.Genre
+	if s.embedGenre && s.has("genre") {
+		var err error
+		genres, err = handler.bookGenres(books)
+		if err != nil {
+			log.Println(err.Error())
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorMessage{"internal server error"})
+			return
+		}
+	}
+
+	var list interface{} = books
+	if apiVersion(c) >= 2 {
+		v2 := make([]models.BookV2, 0, len(books))
+		for _, book := range books {
+			v2 = append(v2, book.V2())
+		}
+		list = v2
+	}
+	projected := s.project(list, genres)
+	if one {
+		respond(c, http.StatusOK, "book", projected.Index(0).Interface())
+		return
+	}
+	respond(c, http.StatusOK, "book", projected.Interface())
+}
+
+// bookGenres looks up the genres of the books in one query.
+func (handler *Handler) bookGenres(books []models.PricedBook) (map[int]models.Genre, error) {
+	var ids []int
+	seen := map[int]bool{}
+	for _, book := range books {
+		if !seen[book.Genre] {
+			seen[book.Genre] = true
+			ids = append(ids, book.Genre)
+		}
+	}
+	genres := map[int]models.Genre{}
+	if len(ids) == 0 {
+		return genres, nil
+	}
+	list, err := handler.DataBase.GetGenres(ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, genre := range list {
+		genres[genre.ID] = genre
+	}
+	return genres, nil
+}
+
+// project copies the selected fields of a slice of structs into structs of a
+// type made of only these fields, so every format renders just them. The
+// embedded genre takes the place of the genre ID.
+func (s fieldSelection) project(list interface{}, genres map[int]models.Genre) reflect.Value {
+	items := reflect.ValueOf(list)
+	var selected []jsonField
+	var fields []reflect.StructField
+	for _, field := range jsonFields(items.Type().Elem()) {
+		if !s.has(field.name) {
+			continue
+		}
+		fieldType := field.Type
+		if field.name == "genre" && genres != nil {
+			fieldType = genreType
+		}
+		selected = append(selected, field)
+		fields = append(fields, reflect.StructField{Name: field.Name, Type: fieldType, Tag: field.Tag})
+	}
+
+	itemType := reflect.StructOf(fields)
+	projected := reflect.MakeSlice(reflect.SliceOf(itemType), 0, items.Len())
+	for i := 0; i < items.Len(); i++ {
+		item := reflect.New(itemType).Elem()
+		for j, field := range selected {
+			value := items.Index(i).FieldByIndex(field.index)
+			if field.name == "genre" && genres != nil {
+				id := int(value.Int())
+				genre, ok := genres[id]
+				if !ok {
+					genre = models.Genre{ID: id}
+				}
+				value = reflect.ValueOf(genre)
+			}
+			item.Field(j).Set(value)
+		}
+		projected = reflect.Append(projected, item)
+	}
+	return projected
+}
+
+// jsonField is a top-level field of a struct as JSON renders it.
+type jsonField struct {
+	reflect.StructField
+	name  string
+	index []int
+}
+
+// jsonFields returns the exported fields of a struct by their JSON names,
+// with the fields of embedded structs promoted.
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if field.PkgPath != "" || name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			for _, promoted := range jsonFields(field.Type) {
+				promoted.index = append([]int{i}, promoted.index...)
+				fields = append(fields, promoted)
+			}
+			continue
+		}
+		fields = append(fields, jsonField{StructField: field, name: jsonName(field, name), index: []int{i}})
+	}
+	return fields
+}
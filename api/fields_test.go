@@ -0,0 +1,130 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAPIFields(t *testing.T) {
+	type mockBehavior func(r *MockDatabase)
+	gin.SetMode(gin.ReleaseMode)
+	now := time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)
+	book := models.Book{ID: 1, Name: "Dune", Price: models.MustParseDecimal("9.99"), Currency: "USD", Genre: 3, Amount: 4,
+		Authors: []string{"Frank Herbert"}, CreatedAt: now, UpdatedAt: now}
+	fantasy := models.Genre{ID: 3, Name: "Fantasy"}
+	tests := []struct {
+		name               string
+		path               string
+		accept             string
+		mockBehavior       mockBehavior
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name: "sparse list",
+			path: "/v2/books?fields=id,name,price",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetAllBooksFields(map[string][]string{}, []string{"id", "name", "price"}).
+					Return([]models.Book{{ID: 1, Name: "Dune", Price: book.Price, Currency: "USD"}}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `[{"id":1,"name":"Dune","price":{"amount":"9.99","currency":"USD"}}]`,
+		},
+		{
+			name: "sparse list with filter",
+			path: "/v2/books?name=Dune&fields=name",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetAllBooksFields(map[string][]string{"name": {"Dune"}}, []string{"name"}).
+					Return([]models.Book{{Name: "Dune"}}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `[{"name":"Dune"}]`,
+		},
+		{
+			name: "effective price",
+			path: "/v2/books/1?fields=name,effective_price",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetBookByIdFields(1, []string{"genre", "id", "name", "price"}).
+					Return(models.Book{ID: 1, Name: "Dune", Price: book.Price, Currency: "USD", Genre: 3}, nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `{"name":"Dune","effective_price":{"amount":"9.99","currency":"USD"}}`,
+		},
+		{
+			name: "v1 sparse book",
+			path: "/v1/books/1?fields=id,price",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetBookByIdFields(1, []string{"id", "price"}).
+					Return(models.Book{ID: 1, Price: book.Price, Currency: "USD"}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `{"id":1,"price":"9.99"}`,
+		},
+		{
+			name: "embedded genre",
+			path: "/v2/books?genre=3&embed=genre",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetAllBooks(map[string][]string{"genre": {"3"}}).Return([]models.Book{book}, nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{}, nil)
+				r.EXPECT().GetGenres([]int{3}).Return([]models.Genre{fantasy}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedBody: `[{"id":1,"name":"Dune","authors":["Frank Herbert"],"price":{"amount":"9.99","currency":"USD"},` +
+				`"effective_price":{"amount":"9.99","currency":"USD"},"genre":{"id":3,"name":"Fantasy"},"amount":4,` +
+				`"created_at":"2021-11-20T10:00:00Z","updated_at":"2021-11-20T10:00:00Z"}]`,
+		},
+		{
+			name:   "embedded genre as CSV",
+			path:   "/v2/books/1?fields=id,genre&embed=genre",
+			accept: "text/csv",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetBookByIdFields(1, []string{"genre", "id"}).Return(models.Book{ID: 1, Genre: 3}, nil)
+				r.EXPECT().GetGenres([]int{3}).Return([]models.Genre{fantasy}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "id,genre_id,genre_name\n1,3,Fantasy\n",
+		},
+		{
+			name:               "unknown field",
+			path:               "/v2/books?fields=id,currency",
+			mockBehavior:       func(r *MockDatabase) {},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error":"invalid fields"}`,
+		},
+		{
+			name:               "unknown embed",
+			path:               "/v2/books/1?embed=authors",
+			mockBehavior:       func(r *MockDatabase) {},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error":"invalid fields"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			db := NewMockDatabase(c)
+			db.EXPECT().GetCatalogModified().Return(now, nil).AnyTimes()
+			test.mockBehavior(db)
+			rest_api := InitializeHandler(db)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", test.path, nil)
+			req.Header.Set("Accept", test.accept)
+
+			rest_api.Router.ServeHTTP(w, req)
+
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			assert.Equal(t, test.expectedBody, w.Body.String())
+		})
+	}
+}
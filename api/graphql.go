@@ -0,0 +1,486 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"encoding/base64"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/graph-gophers/graphql-go"
+	"github.com/porky256/rest-api/db"
+	"github.com/porky256/rest-api/models"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	maxPageSize = 1000
+	// maxQueryDepth and maxParallelism keep a single query from making the
+	// server resolve genres and books nested without end or all at once.
+	maxQueryDepth  = 10
+	maxParallelism = 10
+)
+
+// graphQLSchema describes the books and genres served at /graphql.
+//
+//go:embed schema.graphql
+var graphQLSchema string
+
+var (
+	errInvalidInput   = errors.New("invalid input")
+	errInvalidFilter  = errors.New("invalid filter condition")
+	errNotUnique      = errors.New("input book name is not unique")
+	errNotFound       = errors.New("id not found")
+	errInternalServer = errors.New("internal server error")
+)
+
+type graphQLRequest struct {
+	Query         string                 `json:"query" binding:"required"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+type graphQLContextKey struct{}
+
+// graphQLContext is the state of a single GraphQL request.
+type graphQLContext struct {
+	info   models.AuditInfo
+	genres *genreLoader
+}
+
+func graphQLFrom(ctx context.Context) *graphQLContext {
+	return ctx.Value(graphQLContextKey{}).(*graphQLContext)
+}
+
+// serveGraphQL executes GraphQL queries and mutations posted as JSON. Errors
+// of the query are part of the response, which is always sent with 200.
+func (handler *Handler) serveGraphQL() gin.HandlerFunc {
+	schema := graphql.MustParseSchema(graphQLSchema, &graphQLResolver{handler: handler},
+		graphql.MaxDepth(maxQueryDepth), graphql.MaxParallelism(maxParallelism))
+	return func(c *gin.Context) {
+		var req graphQLRequest
+		if err := c.BindJSON(&req); err != nil {
+			log.Println(err.Error())
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
+			return
+		}
+		ctx := context.WithValue(c.Request.Context(), graphQLContextKey{}, &graphQLContext{
+			info:   auditInfo(c),
+			genres: newGenreLoader(handler.DataBase),
+		})
+		c.JSON(http.StatusOK, schema.Exec(ctx, req.Query, req.OperationName, req.Variables))
+	}
+}
+
+// graphQLError hides database errors behind the messages of the REST API.
+func graphQLError(err error) error {
+	log.Println(err.Error())
+	if strings.Contains(err.Error(), "duplicate key value") { //unique_violation
+		return errNotUnique
+	}
+	if err == sql.ErrNoRows {
+		return errNotFound
+	}
+	return errInternalServer
+}
+
+// genreLoader batches the genre lookups of a request: IDs primed or asked
+// for while no query runs are loaded together in the next query, and every
+// genre is loaded at most once.
+type genreLoader struct {
+	database db.Database
+	mu       sync.Mutex
+	// genres holds nil for IDs without a genre.
+	genres  map[int]*models.Genre
+	pending map[int]bool
+}
+
+func newGenreLoader(database db.Database) *genreLoader {
+	return &genreLoader{database: database, genres: map[int]*models.Genre{}, pending: map[int]bool{}}
+}
+
+// prime adds genres to the next batch without loading it.
+func (l *genreLoader) prime(ids ...int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, id := range ids {
+		if _, ok := l.genres[id]; !ok {
+			l.pending[id] = true
+		}
+	}
+}
+
+func (l *genreLoader) load(id int) (*models.Genre, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if genre, ok := l.genres[id]; ok {
+		return genre, nil
+	}
+	l.pending[id] = true
+	ids := make([]int, 0, len(l.pending))
+	for pending := range l.pending {
+		ids = append(ids, pending)
+	}
+	sort.Ints(ids)
+	list, err := l.database.GetGenres(ids)
+	if err != nil {
+		return nil, err
+	}
+	l.store(ids, list)
+	return l.genres[id], nil
+}
+
+func (l *genreLoader) all() ([]models.Genre, error) {
+	list, err := l.database.GetGenres(nil)
+	if err != nil {
+		return nil, err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.store(nil, list)
+	return list, nil
+}
+
+func (l *genreLoader) store(ids []int, list []models.Genre) {
+	for _, id := range ids {
+		l.genres[id] = nil
+		delete(l.pending, id)
+	}
+	for i := range list {
+		l.genres[list[i].ID] = &list[i]
+		delete(l.pending, list[i].ID)
+	}
+}
+
+type graphQLResolver struct {
+	handler *Handler
+}
+
+type bookFilterInput struct {
+	Name  *string
+	Genre *int32
+}
+
+type bookInput struct {
+	Name     string
+	Authors  *[]string
+	Price    string
+	Currency *string
+	Genre    int32
+	Amount   int32
+}
+
+// book converts and validates the input with the rules of the REST API.
+func (input bookInput) book() (models.Book, error) {
+	price, err := models.ParseDecimal(input.Price)
+	if err != nil {
+		return models.Book{}, errInvalidInput
+	}
+	book := models.Book{Name: input.Name, Price: price, Currency: models.DefaultCurrency, Genre: int(input.Genre), Amount: int(input.Amount)}
+	if input.Currency != nil {
+		book.Currency = *input.Currency
+	}
+	if input.Authors != nil {
+		book.Authors = append([]string{}, *input.Authors...)
+	}
+	// Unlike the v1 rules, the v2 ones cover the authors too.
+	if err := binding.Validator.ValidateStruct(book.V2()); err != nil {
+		log.Println(err.Error())
+		return models.Book{}, errInvalidInput
+	}
+	return book, nil
+}
+
+func (r *graphQLResolver) Books(ctx context.Context, args struct {
+	Filter *bookFilterInput
+	First  int32
+	After  *string
+}) (*bookConnectionResolver, error) {
+	filter := map[string][]string{}
+	if args.Filter != nil {
+		if args.Filter.Name != nil {
+			filter["name"] = []string{*args.Filter.Name}
+		}
+		if args.Filter.Genre != nil {
+			if *args.Filter.Genre < 1 || *args.Filter.Genre > 3 {
+				return nil, errInvalidFilter
+			}
+			filter["genre"] = []string{strconv.Itoa(int(*args.Filter.Genre))}
+		}
+	}
+	return r.books(ctx, filter, args.First, args.After)
+}
+
+// books returns a page of books, a cursor holds the ID of the last book of
+// the previous page.
+func (r *graphQLResolver) books(ctx context.Context, filter map[string][]string, first int32, after *string) (*bookConnectionResolver, error) {
+	if first < 0 {
+		return nil, errInvalidFilter
+	}
+	if first > maxPageSize {
+		first = maxPageSize
+	}
+	var last int
+	if after != nil {
+		raw, err := base64.RawURLEncoding.DecodeString(*after)
+		if err != nil {
+			return nil, errInvalidFilter
+		}
+		if last, err = strconv.Atoi(string(raw)); err != nil {
+			return nil, errInvalidFilter
+		}
+	}
+
+	// One book more than asked for tells if there is a next page.
+	books, err := r.handler.DataBase.GetBooksPage(filter, last, int(first)+1)
+	if err != nil {
+		return nil, graphQLError(err)
+	}
+	more := len(books) > int(first)
+	if more {
+		books = books[:first]
+	}
+	page, err := r.handler.priceBooks(books)
+	if err != nil {
+		return nil, graphQLError(err)
+	}
+
+	connection := &bookConnectionResolver{hasNextPage: more}
+	genres := graphQLFrom(ctx).genres
+	for _, book := range page {
+		// The genres of the page are loaded with the first one asked for.
+		genres.prime(book.Genre)
+		connection.nodes = append(connection.nodes, &bookResolver{book: book, root: r})
+	}
+	if len(page) != 0 {
+		cursor := base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(page[len(page)-1].ID)))
+		connection.endCursor = &cursor
+	}
+	return connection, nil
+}
+
+func (r *graphQLResolver) Book(ctx context.Context, args struct{ ID graphql.ID }) (*bookResolver, error) {
+	id, err := strconv.Atoi(string(args.ID))
+	if err != nil {
+		return nil, errInvalidInput
+	}
+	book, err := r.pricedBook(ctx, id)
+	if err == errNotFound {
+		return nil, nil
+	}
+	return book, err
+}
+
+func (r *graphQLResolver) pricedBook(ctx context.Context, id int) (*bookResolver, error) {
+	book, err := r.handler.DataBase.GetBookById(id)
+	if err != nil {
+		return nil, graphQLError(err)
+	}
+	list, err := r.handler.priceBooks([]models.Book{book})
+	if err != nil {
+		return nil, graphQLError(err)
+	}
+	return &bookResolver{book: list[0], root: r}, nil
+}
+
+func (r *graphQLResolver) Genres(ctx context.Context) ([]*genreResolver, error) {
+	list, err := graphQLFrom(ctx).genres.all()
+	if err != nil {
+		return nil, graphQLError(err)
+	}
+	genres := make([]*genreResolver, 0, len(list))
+	for _, genre := range list {
+		genres = append(genres, &genreResolver{genre: genre, root: r})
+	}
+	return genres, nil
+}
+
+func (r *graphQLResolver) Genre(ctx context.Context, args struct{ ID graphql.ID }) (*genreResolver, error) {
+	id, err := strconv.Atoi(string(args.ID))
+	if err != nil {
+		return nil, errInvalidInput
+	}
+	genre, err := graphQLFrom(ctx).genres.load(id)
+	if err != nil {
+		return nil, graphQLError(err)
+	}
+	if genre == nil {
+		return nil, nil
+	}
+	return &genreResolver{genre: *genre, root: r}, nil
+}
+
+func (r *graphQLResolver) AddBook(ctx context.Context, args struct{ Input bookInput }) (*bookResolver, error) {
+	book, err := args.Input.book()
+	if err != nil {
+		return nil, err
+	}
+	id, err := r.handler.DataBase.AddBook(book, graphQLFrom(ctx).info)
+	if err != nil {
+		return nil, graphQLError(err)
+	}
+	book.ID = id
+	r.handler.publish(models.EventBookCreated, id, book)
+	return r.pricedBook(ctx, id)
+}
+
+func (r *graphQLResolver) UpdateBook(ctx context.Context, args struct {
+	ID    graphql.ID
+	Input bookInput
+}) (*bookResolver, error) {
+	id, err := strconv.Atoi(string(args.ID))
+	if err != nil {
+		return nil, errInvalidInput
+	}
+	book, err := args.Input.book()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.handler.DataBase.UpdateBook(id, book, graphQLFrom(ctx).info); err != nil {
+		return nil, graphQLError(err)
+	}
+	book.ID = id
+	r.handler.publish(models.EventBookUpdated, id, book)
+	return r.pricedBook(ctx, id)
+}
+
+func (r *graphQLResolver) DeleteBook(ctx context.Context, args struct{ ID graphql.ID }) (graphql.ID, error) {
+	id, err := strconv.Atoi(string(args.ID))
+	if err != nil {
+		return "", errInvalidInput
+	}
+	deleted := r.handler.Events.DeletedBook(r.handler.DataBase, id)
+	if err := r.handler.DataBase.DelBook(id, graphQLFrom(ctx).info); err != nil {
+		return "", graphQLError(err)
+	}
+	r.handler.publish(models.EventBookDeleted, id, deleted)
+	return args.ID, nil
+}
+
+type bookResolver struct {
+	book models.PricedBook
+	root *graphQLResolver
+}
+
+func (r *bookResolver) ID() graphql.ID {
+	return graphql.ID(strconv.Itoa(r.book.ID))
+}
+
+func (r *bookResolver) Name() string {
+	return r.book.Name
+}
+
+func (r *bookResolver) Authors() []string {
+	if r.book.Authors == nil {
+		return []string{}
+	}
+	return r.book.Authors
+}
+
+func (r *bookResolver) Price() *moneyResolver {
+	return &moneyResolver{amount: r.book.Price, currency: r.book.Currency}
+}
+
+func (r *bookResolver) EffectivePrice() *moneyResolver {
+	return &moneyResolver{amount: r.book.EffectivePrice, currency: r.book.Currency}
+}
+
+func (r *bookResolver) AppliedPromotions() []int32 {
+	list := make([]int32, 0, len(r.book.AppliedPromotions))
+	for _, id := range r.book.AppliedPromotions {
+		list = append(list, int32(id))
+	}
+	return list
+}
+
+func (r *bookResolver) Genre(ctx context.Context) (*genreResolver, error) {
+	genre, err := graphQLFrom(ctx).genres.load(r.book.Genre)
+	if err != nil {
+		return nil, graphQLError(err)
+	}
+	if genre == nil {
+		return nil, errInternalServer
+	}
+	return &genreResolver{genre: *genre, root: r.root}, nil
+}
+
+func (r *bookResolver) Amount() int32 {
+	return int32(r.book.Amount)
+}
+
+func (r *bookResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: r.book.CreatedAt}
+}
+
+func (r *bookResolver) UpdatedAt() graphql.Time {
+	return graphql.Time{Time: r.book.UpdatedAt}
+}
+
+type moneyResolver struct {
+	amount   models.Decimal
+	currency string
+}
+
+func (r *moneyResolver) Amount() string {
+	return r.amount.String()
+}
+
+func (r *moneyResolver) Currency() string {
+	return r.currency
+}
+
+type genreResolver struct {
+	genre models.Genre
+	root  *graphQLResolver
+}
+
+func (r *genreResolver) ID() graphql.ID {
+	return graphql.ID(strconv.Itoa(r.genre.ID))
+}
+
+func (r *genreResolver) Name() string {
+	return r.genre.Name
+}
+
+func (r *genreResolver) Books(ctx context.Context, args struct {
+	First int32
+	After *string
+}) (*bookConnectionResolver, error) {
+	return r.root.books(ctx, map[string][]string{"genre": {strconv.Itoa(r.genre.ID)}}, args.First, args.After)
+}
+
+type bookConnectionResolver struct {
+	nodes       []*bookResolver
+	endCursor   *string
+	hasNextPage bool
+}
+
+func (r *bookConnectionResolver) Nodes() []*bookResolver {
+	if r.nodes == nil {
+		return []*bookResolver{}
+	}
+	return r.nodes
+}
+
+func (r *bookConnectionResolver) PageInfo() *pageInfoResolver {
+	return &pageInfoResolver{endCursor: r.endCursor, hasNextPage: r.hasNextPage}
+}
+
+type pageInfoResolver struct {
+	endCursor   *string
+	hasNextPage bool
+}
+
+func (r *pageInfoResolver) EndCursor() *string {
+	return r.endCursor
+}
+
+func (r *pageInfoResolver) HasNextPage() bool {
+	return r.hasNextPage
+}
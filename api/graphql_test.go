@@ -0,0 +1,149 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIGraphQL(t *testing.T) {
+	type mockBehavior func(r *MockDatabase)
+	gin.SetMode(gin.ReleaseMode)
+	now := time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)
+	book := func(id, genre int) models.Book {
+		return models.Book{ID: id, Name: "Book " + string(rune('0'+id)), Price: models.MustParseDecimal("9.99"), Currency: "USD",
+			Genre: genre, Amount: 4, Authors: []string{"Ann"}, CreatedAt: now, UpdatedAt: now}
+	}
+	tests := []struct {
+		name               string
+		body               string
+		mockBehavior       mockBehavior
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name: "books with genres in one lookup",
+			body: `{"query":"{ books(filter: {name: \"Book\"}) { nodes { id name genre { name } } pageInfo { hasNextPage } } }"}`,
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetBooksPage(map[string][]string{"name": {"Book"}}, 0, 51).Return([]models.Book{book(3, 3), book(2, 1), book(1, 3)}, nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{}, nil)
+				r.EXPECT().GetGenres([]int{1, 3}).Return([]models.Genre{{ID: 1, Name: "Adventure"}, {ID: 3, Name: "Fantasy"}}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedBody: `{"data":{"books":{"nodes":[{"id":"3","name":"Book 3","genre":{"name":"Fantasy"}},` +
+				`{"id":"2","name":"Book 2","genre":{"name":"Adventure"}},{"id":"1","name":"Book 1","genre":{"name":"Fantasy"}}],` +
+				`"pageInfo":{"hasNextPage":false}}}}`,
+		},
+		{
+			name: "books page",
+			body: `{"query":"query($after: String) { books(first: 1, after: $after) { nodes { id price { amount currency } authors createdAt } pageInfo { endCursor hasNextPage } } }","variables":{"after":"Mw"}}`,
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetBooksPage(map[string][]string{}, 3, 2).Return([]models.Book{book(2, 1), book(1, 3)}, nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedBody: `{"data":{"books":{"nodes":[{"id":"2","price":{"amount":"9.99","currency":"USD"},"authors":["Ann"],` +
+				`"createdAt":"2021-11-20T10:00:00Z"}],"pageInfo":{"endCursor":"Mg","hasNextPage":true}}}}`,
+		},
+		{
+			name:               "too deep",
+			body:               `{"query":"{ genres { books { nodes { genre { books { nodes { genre { books { nodes { genre { name } } } } } } } } } } }"}`,
+			mockBehavior:       func(r *MockDatabase) {},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `{"errors":[{"message":"Field \"name\" has depth 11 that exceeds max depth 10","locations":[{"line":1,"column":84}]}]}`,
+		},
+		{
+			name: "unknown book",
+			body: `{"query":"{ book(id: \"7\") { name } }"}`,
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetBookById(7).Return(models.Book{}, sql.ErrNoRows)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `{"data":{"book":null}}`,
+		},
+		{
+			name: "genres with books",
+			body: `{"query":"{ genres { name books(first: 1) { nodes { name genre { id } } } } }"}`,
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetGenres(nil).Return([]models.Genre{{ID: 1, Name: "Adventure"}}, nil)
+				r.EXPECT().GetBooksPage(map[string][]string{"genre": {"1"}}, 0, 2).Return([]models.Book{book(2, 1)}, nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `{"data":{"genres":[{"name":"Adventure","books":{"nodes":[{"name":"Book 2","genre":{"id":"1"}}]}}]}}`,
+		},
+		{
+			name: "add book",
+			body: `{"query":"mutation { addBook(input: {name: \"Book 1\", authors: [\"Ann\"], price: \"9.99\", genre: 3, amount: 4}) { id effectivePrice { amount } } }"}`,
+			mockBehavior: func(r *MockDatabase) {
+				added := book(1, 3)
+				added.ID, added.CreatedAt, added.UpdatedAt = 0, time.Time{}, time.Time{}
+				r.EXPECT().AddBook(added, gomock.Any()).Return(1, nil)
+				r.EXPECT().GetBookById(1).Return(book(1, 3), nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `{"data":{"addBook":{"id":"1","effectivePrice":{"amount":"9.99"}}}}`,
+		},
+		{
+			name:               "add invalid book",
+			body:               `{"query":"mutation { addBook(input: {name: \"Book 1\", price: \"9.999\", genre: 3, amount: 4}) { id } }"}`,
+			mockBehavior:       func(r *MockDatabase) {},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `{"errors":[{"message":"invalid input","path":["addBook"]}],"data":null}`,
+		},
+		{
+			name: "update book with duplicate name",
+			body: `{"query":"mutation { updateBook(id: \"1\", input: {name: \"Book 2\", price: \"9.99\", genre: 3, amount: 4}) { id } }"}`,
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().UpdateBook(1, gomock.Any(), gomock.Any()).Return(errors.New("pq: duplicate key value violates unique constraint"))
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `{"errors":[{"message":"input book name is not unique","path":["updateBook"]}],"data":null}`,
+		},
+		{
+			name: "delete book",
+			body: `{"query":"mutation { deleteBook(id: \"1\") }"}`,
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetBookById(1).Return(book(1, 3), nil)
+				r.EXPECT().DelBook(1, gomock.Any()).Return(nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `{"data":{"deleteBook":"1"}}`,
+		},
+		{
+			name:               "no query",
+			body:               `{"variables":{}}`,
+			mockBehavior:       func(r *MockDatabase) {},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error":"invalid input"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			db := NewMockDatabase(c)
+			test.mockBehavior(db)
+			rest_api := InitializeHandler(db)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/graphql", strings.NewReader(test.body))
+
+			rest_api.Router.ServeHTTP(w, req)
+
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			assert.Equal(t, test.expectedBody, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	"github.com/porky256/rest-api/models"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	replayedHeader       = "Idempotent-Replayed"
+	idempotencyTTL       = 24 * time.Hour
+	maxIdempotencyKeyLen = 255
+)
+
+// idempotency lets clients retry POST requests safely. The response to the
+// first request carrying an Idempotency-Key header is stored and replayed to
+// retries with the same key for idempotencyTTL, reusing a key for a different
+// request is rejected. Server errors are not stored, so they can be retried.
+// Routes opt in, it must stay off those whose responses hold secrets, like
+// the signing secret of a new webhook, as responses are stored in plain text.
+func (handler *Handler) idempotency(c *gin.Context) {
+	key := c.GetHeader(idempotencyKeyHeader)
+	if key == "" {
+		c.Next()
+		return
+	}
+	if len(key) > maxIdempotencyKeyLen {
+		log.Println("idempotency key too long")
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid idempotency key"})
+		return
+	}
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
+		return
+	}
+	c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	hash := requestHash(c.Request.Method, c.Request.URL.RequestURI(), body)
+
+	stored, claimed, err := handler.DataBase.ClaimIdempotencyKey(key, hash, idempotencyTTL)
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorMessage{"internal server error"})
+		return
+	}
+	if !claimed {
+		switch {
+		case stored.RequestHash != hash:
+			log.Println("idempotency key reused for a different request")
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, ErrorMessage{"idempotency key reused for a different request"})
+		case stored.StatusCode == 0:
+			log.Println("idempotency key in use")
+			c.AbortWithStatusJSON(http.StatusConflict, ErrorMessage{"request with the same idempotency key in progress"})
+		default:
+			c.Header(replayedHeader, "true")
+			c.Data(stored.StatusCode, stored.ContentType, stored.Body)
+			c.Abort()
+		}
+		return
+	}
+
+	recorder := &bodyRecorder{ResponseWriter: c.Writer}
+	c.Writer = recorder
+	saved := false
+	// Handlers that panic must not leave the key claimed until it expires.
+	defer func() {
+		if saved {
+			return
+		}
+		if err := handler.DataBase.ReleaseIdempotencyKey(key); err != nil {
+			log.Println(err.Error())
+		}
+	}()
+	c.Next()
+
+	if recorder.Status() >= http.StatusInternalServerError {
+		return
+	}
+	response := models.IdempotentResponse{
+		RequestHash: hash,
+		StatusCode:  recorder.Status(),
+		ContentType: recorder.Header().Get("Content-Type"),
+		Body:        recorder.body.Bytes(),
+	}
+	if err := handler.DataBase.SaveIdempotentResponse(key, response); err != nil {
+		log.Println(err.Error())
+		return
+	}
+	saved = true
+}
+
+func requestHash(method, uri string, body []byte) string {
+	hash := sha256.New()
+	io.WriteString(hash, method+" "+uri+"\n")
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// bodyRecorder keeps a copy of the response body.
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *bodyRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
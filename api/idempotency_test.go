@@ -0,0 +1,152 @@
+package api
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPIIdempotency(t *testing.T) {
+	type mockBehavior func(r *MockDatabase)
+	gin.SetMode(gin.ReleaseMode)
+	body := `{"name":"Dune","price":"9.99","genre":3,"amount":4}`
+	hash := requestHash("POST", "/v1/books", []byte(body))
+	book := models.Book{Name: "Dune", Price: models.MustParseDecimal("9.99"), Currency: "USD", Genre: 3, Amount: 4}
+	tests := []struct {
+		name               string
+		key                string
+		body               string
+		mockBehavior       mockBehavior
+		expectedStatusCode int
+		expectedBody       string
+		expectedReplayed   string
+	}{
+		{
+			name: "first request",
+			key:  "key",
+			body: body,
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().ClaimIdempotencyKey("key", hash, idempotencyTTL).Return(models.IdempotentResponse{RequestHash: hash}, true, nil)
+				r.EXPECT().AddBook(book, gomock.Any()).Return(1, nil)
+				r.EXPECT().SaveIdempotentResponse("key", models.IdempotentResponse{RequestHash: hash, StatusCode: http.StatusOK,
+					ContentType: "application/json; charset=utf-8", Body: []byte(`{"id":1}`)}).Return(nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `{"id":1}`,
+		},
+		{
+			name: "retry",
+			key:  "key",
+			body: body,
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().ClaimIdempotencyKey("key", hash, idempotencyTTL).Return(models.IdempotentResponse{RequestHash: hash,
+					StatusCode: http.StatusOK, ContentType: "application/json; charset=utf-8", Body: []byte(`{"id":1}`)}, false, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `{"id":1}`,
+			expectedReplayed:   "true",
+		},
+		{
+			name: "retry while in progress",
+			key:  "key",
+			body: body,
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().ClaimIdempotencyKey("key", hash, idempotencyTTL).Return(models.IdempotentResponse{RequestHash: hash}, false, nil)
+			},
+			expectedStatusCode: http.StatusConflict,
+			expectedBody:       `{"error":"request with the same idempotency key in progress"}`,
+		},
+		{
+			name: "key reused",
+			key:  "key",
+			body: `{"name":"Emma","price":"9.99","genre":1,"amount":4}`,
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().ClaimIdempotencyKey("key", gomock.Not(hash), idempotencyTTL).Return(models.IdempotentResponse{RequestHash: hash,
+					StatusCode: http.StatusOK, ContentType: "application/json; charset=utf-8", Body: []byte(`{"id":1}`)}, false, nil)
+			},
+			expectedStatusCode: http.StatusUnprocessableEntity,
+			expectedBody:       `{"error":"idempotency key reused for a different request"}`,
+		},
+		{
+			name: "server error",
+			key:  "key",
+			body: body,
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().ClaimIdempotencyKey("key", hash, idempotencyTTL).Return(models.IdempotentResponse{RequestHash: hash}, true, nil)
+				r.EXPECT().AddBook(book, gomock.Any()).Return(0, errors.New("connection refused"))
+				r.EXPECT().ReleaseIdempotencyKey("key").Return(nil)
+			},
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedBody:       `{"error":"internal server error"}`,
+		},
+		{
+			name:               "key too long",
+			key:                strings.Repeat("k", 256),
+			body:               body,
+			mockBehavior:       func(r *MockDatabase) {},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error":"invalid idempotency key"}`,
+		},
+		{
+			name: "no key",
+			body: body,
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().AddBook(book, gomock.Any()).Return(1, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `{"id":1}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			db := NewMockDatabase(c)
+			test.mockBehavior(db)
+			rest_api := InitializeHandler(db)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/v1/books", strings.NewReader(test.body))
+			if test.key != "" {
+				req.Header.Set(idempotencyKeyHeader, test.key)
+			}
+
+			rest_api.Router.ServeHTTP(w, req)
+
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			assert.Equal(t, test.expectedBody, w.Body.String())
+			assert.Equal(t, test.expectedReplayed, w.Header().Get(replayedHeader))
+		})
+	}
+}
+
+func TestAPIIdempotencyKeepsWebhookSecrets(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	db := NewMockDatabase(c)
+	db.EXPECT().AddWebhook(gomock.Any()).Return(1, nil)
+	db.EXPECT().ClaimIdempotencyKey(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+	db.EXPECT().SaveIdempotentResponse(gomock.Any(), gomock.Any()).Times(0)
+	rest_api := InitializeHandler(db)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/v2/webhooks",
+		strings.NewReader(`{"url":"https://shop.example.com/hook","events":["book.updated"]}`))
+	req.Header.Set(idempotencyKeyHeader, "key")
+
+	rest_api.Router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"secret"`)
+	assert.Empty(t, w.Header().Get(replayedHeader))
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"database/sql"
+	"github.com/gin-gonic/gin"
+	"github.com/porky256/rest-api/db"
+	"github.com/porky256/rest-api/models"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// getLowStock responds with the books whose amount is below their threshold,
+// the scarcest first.
+func (handler *Handler) getLowStock(c *gin.Context) {
+	list, err := handler.DataBase.GetLowStockBooks()
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorMessage{"internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// adjustStock changes the amount of a book in stock by the delta received in
+// the request body, in one step so concurrent changes add up.
+func (handler *Handler) adjustStock(c *gin.Context) {
+	var change models.StockChange
+
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
+		return
+	}
+
+	if err := c.BindJSON(&change); err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
+		return
+	}
+
+	book, err := handler.DataBase.AdjustStock(id, change.Delta, auditInfo(c))
+	if err != nil {
+		log.Println(err.Error())
+		switch err {
+		case db.ErrUnknownBook:
+			c.AbortWithStatusJSON(http.StatusNotFound, ErrorMessage{"id not found"})
+		case db.ErrInsufficientStock:
+			c.AbortWithStatusJSON(http.StatusConflict, ErrorMessage{"insufficient stock"})
+		default:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorMessage{"internal server error"})
+		}
+		return
+	}
+	handler.publish(models.EventStockChanged, book.ID, book)
+	renderBook(c, book)
+}
+
+func (handler *Handler) getStockThresholds(c *gin.Context) {
+	list, err := handler.DataBase.GetStockThresholds()
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorMessage{"internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// postStockThreshold adds a threshold for a book or a genre from JSON received in the request body.
+func (handler *Handler) postStockThreshold(c *gin.Context) {
+	var newThreshold models.StockThreshold
+
+	if err := c.BindJSON(&newThreshold); err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
+		return
+	}
+
+	id, err := handler.DataBase.AddStockThreshold(newThreshold)
+	if err != nil {
+		thresholdError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"id": id})
+}
+
+func (handler *Handler) updateStockThreshold(c *gin.Context) {
+	var newThreshold models.StockThreshold
+
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
+		return
+	}
+
+	if err := c.BindJSON(&newThreshold); err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
+		return
+	}
+
+	err = handler.DataBase.UpdateStockThreshold(id, newThreshold)
+	newThreshold.ID = id
+	if err != nil {
+		thresholdError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, newThreshold)
+}
+
+func (handler *Handler) deleteStockThreshold(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
+		return
+	}
+
+	err = handler.DataBase.DelStockThreshold(id)
+	if err != nil {
+		thresholdError(c, err)
+		return
+	}
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+func thresholdError(c *gin.Context, err error) {
+	log.Println(err.Error())
+	if strings.Contains(err.Error(), "duplicate key value") { //unique_violation
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorMessage{"threshold for the book or genre already exists"})
+		return
+	}
+	if strings.Contains(err.Error(), "violates foreign key constraint") { //foreign_key_violation
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"unknown book"})
+		return
+	}
+	switch err {
+	case sql.ErrNoRows:
+		c.AbortWithStatusJSON(http.StatusNotFound, ErrorMessage{"id not found"})
+	default:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorMessage{"internal server error"})
+	}
+}
@@ -0,0 +1,248 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/porky256/rest-api/db"
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIPostStockThreshold(t *testing.T) {
+	type mockBehavior func(s *MockDatabase, threshold models.StockThreshold)
+	gin.SetMode(gin.ReleaseMode)
+	tests := []struct {
+		name                 string
+		inputBody            string
+		inputThreshold       models.StockThreshold
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:           "OK book",
+			inputBody:      `{"book_id":1,"threshold":5}`,
+			inputThreshold: models.StockThreshold{BookID: 1, Threshold: 5},
+			mockBehavior: func(r *MockDatabase, threshold models.StockThreshold) {
+				r.EXPECT().AddStockThreshold(threshold).Return(1, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{"id":1}`,
+		},
+		{
+			name:           "OK genre",
+			inputBody:      `{"genre":2,"threshold":10}`,
+			inputThreshold: models.StockThreshold{Genre: 2, Threshold: 10},
+			mockBehavior: func(r *MockDatabase, threshold models.StockThreshold) {
+				r.EXPECT().AddStockThreshold(threshold).Return(2, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{"id":2}`,
+		},
+		{
+			name:                 "Book and genre",
+			inputBody:            `{"book_id":1,"genre":2,"threshold":10}`,
+			mockBehavior:         func(r *MockDatabase, threshold models.StockThreshold) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"invalid input"}`,
+		},
+		{
+			name:                 "No target",
+			inputBody:            `{"threshold":10}`,
+			mockBehavior:         func(r *MockDatabase, threshold models.StockThreshold) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"invalid input"}`,
+		},
+		{
+			name:                 "Negative threshold",
+			inputBody:            `{"genre":1,"threshold":-1}`,
+			mockBehavior:         func(r *MockDatabase, threshold models.StockThreshold) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"invalid input"}`,
+		},
+		{
+			name:           "Duplicate",
+			inputBody:      `{"genre":2,"threshold":10}`,
+			inputThreshold: models.StockThreshold{Genre: 2, Threshold: 10},
+			mockBehavior: func(r *MockDatabase, threshold models.StockThreshold) {
+				r.EXPECT().AddStockThreshold(threshold).Return(0, errors.New("duplicate key value"))
+			},
+			expectedStatusCode:   http.StatusInternalServerError,
+			expectedResponseBody: `{"error":"threshold for the book or genre already exists"}`,
+		},
+		{
+			name:           "Unknown book",
+			inputBody:      `{"book_id":99,"threshold":10}`,
+			inputThreshold: models.StockThreshold{BookID: 99, Threshold: 10},
+			mockBehavior: func(r *MockDatabase, threshold models.StockThreshold) {
+				r.EXPECT().AddStockThreshold(threshold).Return(0, errors.New("violates foreign key constraint"))
+			},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"unknown book"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			db := NewMockDatabase(c)
+			test.mockBehavior(db, test.inputThreshold)
+			rest_api := Handler{Router: gin.Default(), DataBase: db}
+
+			r := gin.New()
+			r.POST("/inventory/thresholds", rest_api.postStockThreshold)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/inventory/thresholds",
+				bytes.NewBufferString(test.inputBody))
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			assert.Equal(t, test.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestAPIAdjustStock(t *testing.T) {
+	type mockBehavior func(s *MockDatabase)
+	gin.SetMode(gin.ReleaseMode)
+	tests := []struct {
+		name                 string
+		path                 string
+		inputBody            string
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:      "OK",
+			path:      "/books/1/stock",
+			inputBody: `{"delta":-2}`,
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().AdjustStock(1, -2, gomock.Any()).Return(models.Book{ID: 1, Name: "Dune",
+					Price: models.MustParseDecimal("10.00"), Currency: "USD", Genre: 3, Amount: 1}, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{"id":1,"name":"Dune","price":"10.00","currency":"USD","genre":3,"amount":1}`,
+		},
+		{
+			name:                 "Invalid delta",
+			path:                 "/books/1/stock",
+			inputBody:            `{"delta":"two"}`,
+			mockBehavior:         func(r *MockDatabase) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"invalid input"}`,
+		},
+		{
+			name:                 "Invalid id",
+			path:                 "/books/a/stock",
+			inputBody:            `{"delta":1}`,
+			mockBehavior:         func(r *MockDatabase) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"invalid input"}`,
+		},
+		{
+			name:      "Unknown book",
+			path:      "/books/9/stock",
+			inputBody: `{"delta":1}`,
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().AdjustStock(9, 1, gomock.Any()).Return(models.Book{}, db.ErrUnknownBook)
+			},
+			expectedStatusCode:   http.StatusNotFound,
+			expectedResponseBody: `{"error":"id not found"}`,
+		},
+		{
+			name:      "Insufficient stock",
+			path:      "/books/1/stock",
+			inputBody: `{"delta":-5}`,
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().AdjustStock(1, -5, gomock.Any()).Return(models.Book{}, db.ErrInsufficientStock)
+			},
+			expectedStatusCode:   http.StatusConflict,
+			expectedResponseBody: `{"error":"insufficient stock"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			db := NewMockDatabase(c)
+			test.mockBehavior(db)
+			rest_api := Handler{Router: gin.Default(), DataBase: db}
+
+			r := gin.New()
+			r.POST("/books/:id/stock", rest_api.adjustStock)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", test.path, bytes.NewBufferString(test.inputBody))
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			assert.Equal(t, test.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestAPIGetLowStock(t *testing.T) {
+	type mockBehavior func(s *MockDatabase)
+	gin.SetMode(gin.ReleaseMode)
+	tests := []struct {
+		name                 string
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name: "OK",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetLowStockBooks().Return([]models.LowStockBook{{Book: models.Book{ID: 1, Name: "Dune",
+					Price: models.MustParseDecimal("10.00"), Currency: "USD", Genre: 3, Amount: 1}, Threshold: 5}}, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `[{"id":1,"name":"Dune","price":"10.00","currency":"USD","genre":3,"amount":1,"threshold":5}]`,
+		},
+		{
+			name: "Database error",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetLowStockBooks().Return(nil, errors.New("database error"))
+			},
+			expectedStatusCode:   http.StatusInternalServerError,
+			expectedResponseBody: `{"error":"internal server error"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			db := NewMockDatabase(c)
+			test.mockBehavior(db)
+			rest_api := Handler{Router: gin.Default(), DataBase: db}
+
+			r := gin.New()
+			r.GET("/inventory/low-stock", rest_api.getLowStock)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/inventory/low-stock", nil)
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			assert.Equal(t, test.expectedResponseBody, w.Body.String())
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 	gomock "github.com/golang/mock/gomock"
 	models "github.com/porky256/rest-api/models"
 	reflect "reflect"
+	time "time"
 )
 
 // MockDatabase is a mock of Database interface.
@@ -34,32 +35,239 @@ func (m *MockDatabase) EXPECT() *MockDatabaseMockRecorder {
 }
 
 // AddBook mocks base method.
-func (m *MockDatabase) AddBook(book models.Book) (int, error) {
+func (m *MockDatabase) AddBook(book models.Book, info models.AuditInfo) (int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddBook", book)
+	ret := m.ctrl.Call(m, "AddBook", book, info)
 	ret0, _ := ret[0].(int)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // AddBook indicates an expected call of AddBook.
-func (mr *MockDatabaseMockRecorder) AddBook(book interface{}) *gomock.Call {
+func (mr *MockDatabaseMockRecorder) AddBook(book, info interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddBook", reflect.TypeOf((*MockDatabase)(nil).AddBook), book)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddBook", reflect.TypeOf((*MockDatabase)(nil).AddBook), book, info)
+}
+
+// AddCustomer mocks base method.
+func (m *MockDatabase) AddCustomer(customer models.Customer) (models.Customer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddCustomer", customer)
+	ret0, _ := ret[0].(models.Customer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddCustomer indicates an expected call of AddCustomer.
+func (mr *MockDatabaseMockRecorder) AddCustomer(customer interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddCustomer", reflect.TypeOf((*MockDatabase)(nil).AddCustomer), customer)
+}
+
+// AddPromotion mocks base method.
+func (m *MockDatabase) AddPromotion(promotion models.Promotion) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddPromotion", promotion)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddPromotion indicates an expected call of AddPromotion.
+func (mr *MockDatabaseMockRecorder) AddPromotion(promotion interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddPromotion", reflect.TypeOf((*MockDatabase)(nil).AddPromotion), promotion)
+}
+
+// AddStockThreshold mocks base method.
+func (m *MockDatabase) AddStockThreshold(threshold models.StockThreshold) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddStockThreshold", threshold)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddStockThreshold indicates an expected call of AddStockThreshold.
+func (mr *MockDatabaseMockRecorder) AddStockThreshold(threshold interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddStockThreshold", reflect.TypeOf((*MockDatabase)(nil).AddStockThreshold), threshold)
+}
+
+// AddWebhook mocks base method.
+func (m *MockDatabase) AddWebhook(webhook models.Webhook) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddWebhook", webhook)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddWebhook indicates an expected call of AddWebhook.
+func (mr *MockDatabaseMockRecorder) AddWebhook(webhook interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddWebhook", reflect.TypeOf((*MockDatabase)(nil).AddWebhook), webhook)
+}
+
+// AdjustStock mocks base method.
+func (m *MockDatabase) AdjustStock(id, delta int, info models.AuditInfo) (models.Book, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdjustStock", id, delta, info)
+	ret0, _ := ret[0].(models.Book)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdjustStock indicates an expected call of AdjustStock.
+func (mr *MockDatabaseMockRecorder) AdjustStock(id, delta, info interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdjustStock", reflect.TypeOf((*MockDatabase)(nil).AdjustStock), id, delta, info)
+}
+
+// ClaimDeliveries mocks base method.
+func (m *MockDatabase) ClaimDeliveries(limit int, lease time.Duration) ([]models.Delivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimDeliveries", limit, lease)
+	ret0, _ := ret[0].([]models.Delivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimDeliveries indicates an expected call of ClaimDeliveries.
+func (mr *MockDatabaseMockRecorder) ClaimDeliveries(limit, lease interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimDeliveries", reflect.TypeOf((*MockDatabase)(nil).ClaimDeliveries), limit, lease)
+}
+
+// ClaimIdempotencyKey mocks base method.
+func (m *MockDatabase) ClaimIdempotencyKey(key, requestHash string, ttl time.Duration) (models.IdempotentResponse, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimIdempotencyKey", key, requestHash, ttl)
+	ret0, _ := ret[0].(models.IdempotentResponse)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ClaimIdempotencyKey indicates an expected call of ClaimIdempotencyKey.
+func (mr *MockDatabaseMockRecorder) ClaimIdempotencyKey(key, requestHash, ttl interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimIdempotencyKey", reflect.TypeOf((*MockDatabase)(nil).ClaimIdempotencyKey), key, requestHash, ttl)
+}
+
+// ClaimLowStockAlerts mocks base method.
+func (m *MockDatabase) ClaimLowStockAlerts() ([]models.LowStockBook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimLowStockAlerts")
+	ret0, _ := ret[0].([]models.LowStockBook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimLowStockAlerts indicates an expected call of ClaimLowStockAlerts.
+func (mr *MockDatabaseMockRecorder) ClaimLowStockAlerts() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimLowStockAlerts", reflect.TypeOf((*MockDatabase)(nil).ClaimLowStockAlerts))
+}
+
+// CreateOrder mocks base method.
+func (m *MockDatabase) CreateOrder(order models.Order, info models.AuditInfo) (models.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateOrder", order, info)
+	ret0, _ := ret[0].(models.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateOrder indicates an expected call of CreateOrder.
+func (mr *MockDatabaseMockRecorder) CreateOrder(order, info interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOrder", reflect.TypeOf((*MockDatabase)(nil).CreateOrder), order, info)
 }
 
 // DelBook mocks base method.
-func (m *MockDatabase) DelBook(id int) error {
+func (m *MockDatabase) DelBook(id int, info models.AuditInfo) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DelBook", id)
+	ret := m.ctrl.Call(m, "DelBook", id, info)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DelBook indicates an expected call of DelBook.
-func (mr *MockDatabaseMockRecorder) DelBook(id interface{}) *gomock.Call {
+func (mr *MockDatabaseMockRecorder) DelBook(id, info interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DelBook", reflect.TypeOf((*MockDatabase)(nil).DelBook), id, info)
+}
+
+// DelCustomer mocks base method.
+func (m *MockDatabase) DelCustomer(id int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DelCustomer", id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DelCustomer indicates an expected call of DelCustomer.
+func (mr *MockDatabaseMockRecorder) DelCustomer(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DelCustomer", reflect.TypeOf((*MockDatabase)(nil).DelCustomer), id)
+}
+
+// DelPromotion mocks base method.
+func (m *MockDatabase) DelPromotion(id int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DelPromotion", id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DelPromotion indicates an expected call of DelPromotion.
+func (mr *MockDatabaseMockRecorder) DelPromotion(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DelPromotion", reflect.TypeOf((*MockDatabase)(nil).DelPromotion), id)
+}
+
+// DelStockThreshold mocks base method.
+func (m *MockDatabase) DelStockThreshold(id int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DelStockThreshold", id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DelStockThreshold indicates an expected call of DelStockThreshold.
+func (mr *MockDatabaseMockRecorder) DelStockThreshold(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DelStockThreshold", reflect.TypeOf((*MockDatabase)(nil).DelStockThreshold), id)
+}
+
+// DelWebhook mocks base method.
+func (m *MockDatabase) DelWebhook(id int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DelWebhook", id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DelWebhook indicates an expected call of DelWebhook.
+func (mr *MockDatabaseMockRecorder) DelWebhook(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DelWebhook", reflect.TypeOf((*MockDatabase)(nil).DelWebhook), id)
+}
+
+// GetActivePromotions mocks base method.
+func (m *MockDatabase) GetActivePromotions(at time.Time) ([]models.Promotion, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetActivePromotions", at)
+	ret0, _ := ret[0].([]models.Promotion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetActivePromotions indicates an expected call of GetActivePromotions.
+func (mr *MockDatabaseMockRecorder) GetActivePromotions(at interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DelBook", reflect.TypeOf((*MockDatabase)(nil).DelBook), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetActivePromotions", reflect.TypeOf((*MockDatabase)(nil).GetActivePromotions), at)
 }
 
 // GetAllBooks mocks base method.
@@ -77,6 +285,66 @@ func (mr *MockDatabaseMockRecorder) GetAllBooks(filter interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllBooks", reflect.TypeOf((*MockDatabase)(nil).GetAllBooks), filter)
 }
 
+// GetAllBooksFields mocks base method.
+func (m *MockDatabase) GetAllBooksFields(filter map[string][]string, columns []string) ([]models.Book, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAllBooksFields", filter, columns)
+	ret0, _ := ret[0].([]models.Book)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAllBooksFields indicates an expected call of GetAllBooksFields.
+func (mr *MockDatabaseMockRecorder) GetAllBooksFields(filter, columns interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllBooksFields", reflect.TypeOf((*MockDatabase)(nil).GetAllBooksFields), filter, columns)
+}
+
+// GetAllCustomers mocks base method.
+func (m *MockDatabase) GetAllCustomers() ([]models.Customer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAllCustomers")
+	ret0, _ := ret[0].([]models.Customer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAllCustomers indicates an expected call of GetAllCustomers.
+func (mr *MockDatabaseMockRecorder) GetAllCustomers() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllCustomers", reflect.TypeOf((*MockDatabase)(nil).GetAllCustomers))
+}
+
+// GetAllPromotions mocks base method.
+func (m *MockDatabase) GetAllPromotions() ([]models.Promotion, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAllPromotions")
+	ret0, _ := ret[0].([]models.Promotion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAllPromotions indicates an expected call of GetAllPromotions.
+func (mr *MockDatabaseMockRecorder) GetAllPromotions() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllPromotions", reflect.TypeOf((*MockDatabase)(nil).GetAllPromotions))
+}
+
+// GetAuditLog mocks base method.
+func (m *MockDatabase) GetAuditLog(filter models.AuditFilter) ([]models.AuditEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAuditLog", filter)
+	ret0, _ := ret[0].([]models.AuditEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAuditLog indicates an expected call of GetAuditLog.
+func (mr *MockDatabaseMockRecorder) GetAuditLog(filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAuditLog", reflect.TypeOf((*MockDatabase)(nil).GetAuditLog), filter)
+}
+
 // GetBookById mocks base method.
 func (m *MockDatabase) GetBookById(id int) (models.Book, error) {
 	m.ctrl.T.Helper()
@@ -92,16 +360,443 @@ func (mr *MockDatabaseMockRecorder) GetBookById(id interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBookById", reflect.TypeOf((*MockDatabase)(nil).GetBookById), id)
 }
 
+// GetBookByIdFields mocks base method.
+func (m *MockDatabase) GetBookByIdFields(id int, columns []string) (models.Book, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBookByIdFields", id, columns)
+	ret0, _ := ret[0].(models.Book)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBookByIdFields indicates an expected call of GetBookByIdFields.
+func (mr *MockDatabaseMockRecorder) GetBookByIdFields(id, columns interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBookByIdFields", reflect.TypeOf((*MockDatabase)(nil).GetBookByIdFields), id, columns)
+}
+
+// GetBookHistory mocks base method.
+func (m *MockDatabase) GetBookHistory(id int) ([]models.AuditEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBookHistory", id)
+	ret0, _ := ret[0].([]models.AuditEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBookHistory indicates an expected call of GetBookHistory.
+func (mr *MockDatabaseMockRecorder) GetBookHistory(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBookHistory", reflect.TypeOf((*MockDatabase)(nil).GetBookHistory), id)
+}
+
+// GetBooksPage mocks base method.
+func (m *MockDatabase) GetBooksPage(filter map[string][]string, after, limit int) ([]models.Book, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBooksPage", filter, after, limit)
+	ret0, _ := ret[0].([]models.Book)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBooksPage indicates an expected call of GetBooksPage.
+func (mr *MockDatabaseMockRecorder) GetBooksPage(filter, after, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBooksPage", reflect.TypeOf((*MockDatabase)(nil).GetBooksPage), filter, after, limit)
+}
+
+// GetCatalogModified mocks base method.
+func (m *MockDatabase) GetCatalogModified() (time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCatalogModified")
+	ret0, _ := ret[0].(time.Time)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCatalogModified indicates an expected call of GetCatalogModified.
+func (mr *MockDatabaseMockRecorder) GetCatalogModified() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCatalogModified", reflect.TypeOf((*MockDatabase)(nil).GetCatalogModified))
+}
+
+// GetCustomerById mocks base method.
+func (m *MockDatabase) GetCustomerById(id int) (models.Customer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCustomerById", id)
+	ret0, _ := ret[0].(models.Customer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCustomerById indicates an expected call of GetCustomerById.
+func (mr *MockDatabaseMockRecorder) GetCustomerById(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCustomerById", reflect.TypeOf((*MockDatabase)(nil).GetCustomerById), id)
+}
+
+// GetCustomerOrders mocks base method.
+func (m *MockDatabase) GetCustomerOrders(id int) ([]models.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCustomerOrders", id)
+	ret0, _ := ret[0].([]models.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCustomerOrders indicates an expected call of GetCustomerOrders.
+func (mr *MockDatabaseMockRecorder) GetCustomerOrders(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCustomerOrders", reflect.TypeOf((*MockDatabase)(nil).GetCustomerOrders), id)
+}
+
+// GetDeadDeliveries mocks base method.
+func (m *MockDatabase) GetDeadDeliveries(limit int) ([]models.Delivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeadDeliveries", limit)
+	ret0, _ := ret[0].([]models.Delivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeadDeliveries indicates an expected call of GetDeadDeliveries.
+func (mr *MockDatabaseMockRecorder) GetDeadDeliveries(limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeadDeliveries", reflect.TypeOf((*MockDatabase)(nil).GetDeadDeliveries), limit)
+}
+
+// GetGenres mocks base method.
+func (m *MockDatabase) GetGenres(ids []int) ([]models.Genre, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetGenres", ids)
+	ret0, _ := ret[0].([]models.Genre)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetGenres indicates an expected call of GetGenres.
+func (mr *MockDatabaseMockRecorder) GetGenres(ids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGenres", reflect.TypeOf((*MockDatabase)(nil).GetGenres), ids)
+}
+
+// GetLowStockBooks mocks base method.
+func (m *MockDatabase) GetLowStockBooks() ([]models.LowStockBook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLowStockBooks")
+	ret0, _ := ret[0].([]models.LowStockBook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLowStockBooks indicates an expected call of GetLowStockBooks.
+func (mr *MockDatabaseMockRecorder) GetLowStockBooks() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLowStockBooks", reflect.TypeOf((*MockDatabase)(nil).GetLowStockBooks))
+}
+
+// GetOrder mocks base method.
+func (m *MockDatabase) GetOrder(id int) (models.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrder", id)
+	ret0, _ := ret[0].(models.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrder indicates an expected call of GetOrder.
+func (mr *MockDatabaseMockRecorder) GetOrder(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrder", reflect.TypeOf((*MockDatabase)(nil).GetOrder), id)
+}
+
+// GetPriceChangeReport mocks base method.
+func (m *MockDatabase) GetPriceChangeReport(filter models.PriceFilter) ([]models.PriceChangeReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPriceChangeReport", filter)
+	ret0, _ := ret[0].([]models.PriceChangeReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPriceChangeReport indicates an expected call of GetPriceChangeReport.
+func (mr *MockDatabaseMockRecorder) GetPriceChangeReport(filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPriceChangeReport", reflect.TypeOf((*MockDatabase)(nil).GetPriceChangeReport), filter)
+}
+
+// GetPriceHistory mocks base method.
+func (m *MockDatabase) GetPriceHistory(id int, filter models.PriceFilter) ([]models.PriceChange, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPriceHistory", id, filter)
+	ret0, _ := ret[0].([]models.PriceChange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPriceHistory indicates an expected call of GetPriceHistory.
+func (mr *MockDatabaseMockRecorder) GetPriceHistory(id, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPriceHistory", reflect.TypeOf((*MockDatabase)(nil).GetPriceHistory), id, filter)
+}
+
+// GetPromotionById mocks base method.
+func (m *MockDatabase) GetPromotionById(id int) (models.Promotion, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPromotionById", id)
+	ret0, _ := ret[0].(models.Promotion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPromotionById indicates an expected call of GetPromotionById.
+func (mr *MockDatabaseMockRecorder) GetPromotionById(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPromotionById", reflect.TypeOf((*MockDatabase)(nil).GetPromotionById), id)
+}
+
+// GetStockThresholds mocks base method.
+func (m *MockDatabase) GetStockThresholds() ([]models.StockThreshold, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStockThresholds")
+	ret0, _ := ret[0].([]models.StockThreshold)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStockThresholds indicates an expected call of GetStockThresholds.
+func (mr *MockDatabaseMockRecorder) GetStockThresholds() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStockThresholds", reflect.TypeOf((*MockDatabase)(nil).GetStockThresholds))
+}
+
+// GetWebhookById mocks base method.
+func (m *MockDatabase) GetWebhookById(id int) (models.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookById", id)
+	ret0, _ := ret[0].(models.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookById indicates an expected call of GetWebhookById.
+func (mr *MockDatabaseMockRecorder) GetWebhookById(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookById", reflect.TypeOf((*MockDatabase)(nil).GetWebhookById), id)
+}
+
+// GetWebhooks mocks base method.
+func (m *MockDatabase) GetWebhooks() ([]models.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhooks")
+	ret0, _ := ret[0].([]models.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhooks indicates an expected call of GetWebhooks.
+func (mr *MockDatabaseMockRecorder) GetWebhooks() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhooks", reflect.TypeOf((*MockDatabase)(nil).GetWebhooks))
+}
+
+// PurgeBooks mocks base method.
+func (m *MockDatabase) PurgeBooks(before time.Time, info models.AuditInfo) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeBooks", before, info)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeBooks indicates an expected call of PurgeBooks.
+func (mr *MockDatabaseMockRecorder) PurgeBooks(before, info interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeBooks", reflect.TypeOf((*MockDatabase)(nil).PurgeBooks), before, info)
+}
+
+// RecordDeliveryAttempt mocks base method.
+func (m *MockDatabase) RecordDeliveryAttempt(id int64, attempt models.DeliveryAttempt) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordDeliveryAttempt", id, attempt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordDeliveryAttempt indicates an expected call of RecordDeliveryAttempt.
+func (mr *MockDatabaseMockRecorder) RecordDeliveryAttempt(id, attempt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordDeliveryAttempt", reflect.TypeOf((*MockDatabase)(nil).RecordDeliveryAttempt), id, attempt)
+}
+
+// ReleaseIdempotencyKey mocks base method.
+func (m *MockDatabase) ReleaseIdempotencyKey(key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleaseIdempotencyKey", key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReleaseIdempotencyKey indicates an expected call of ReleaseIdempotencyKey.
+func (mr *MockDatabaseMockRecorder) ReleaseIdempotencyKey(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseIdempotencyKey", reflect.TypeOf((*MockDatabase)(nil).ReleaseIdempotencyKey), key)
+}
+
+// ReleaseLowStockAlert mocks base method.
+func (m *MockDatabase) ReleaseLowStockAlert(bookID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleaseLowStockAlert", bookID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReleaseLowStockAlert indicates an expected call of ReleaseLowStockAlert.
+func (mr *MockDatabaseMockRecorder) ReleaseLowStockAlert(bookID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseLowStockAlert", reflect.TypeOf((*MockDatabase)(nil).ReleaseLowStockAlert), bookID)
+}
+
+// RestoreBook mocks base method.
+func (m *MockDatabase) RestoreBook(id int, info models.AuditInfo) (models.Book, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreBook", id, info)
+	ret0, _ := ret[0].(models.Book)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RestoreBook indicates an expected call of RestoreBook.
+func (mr *MockDatabaseMockRecorder) RestoreBook(id, info interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreBook", reflect.TypeOf((*MockDatabase)(nil).RestoreBook), id, info)
+}
+
+// RetryDelivery mocks base method.
+func (m *MockDatabase) RetryDelivery(id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RetryDelivery", id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RetryDelivery indicates an expected call of RetryDelivery.
+func (mr *MockDatabaseMockRecorder) RetryDelivery(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RetryDelivery", reflect.TypeOf((*MockDatabase)(nil).RetryDelivery), id)
+}
+
+// SaveIdempotentResponse mocks base method.
+func (m *MockDatabase) SaveIdempotentResponse(key string, response models.IdempotentResponse) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveIdempotentResponse", key, response)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveIdempotentResponse indicates an expected call of SaveIdempotentResponse.
+func (mr *MockDatabaseMockRecorder) SaveIdempotentResponse(key, response interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveIdempotentResponse", reflect.TypeOf((*MockDatabase)(nil).SaveIdempotentResponse), key, response)
+}
+
+// SearchBooks mocks base method.
+func (m *MockDatabase) SearchBooks(query string, limit int) ([]models.SearchResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchBooks", query, limit)
+	ret0, _ := ret[0].([]models.SearchResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchBooks indicates an expected call of SearchBooks.
+func (mr *MockDatabaseMockRecorder) SearchBooks(query, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchBooks", reflect.TypeOf((*MockDatabase)(nil).SearchBooks), query, limit)
+}
+
 // UpdateBook mocks base method.
-func (m *MockDatabase) UpdateBook(id int, book models.Book) error {
+func (m *MockDatabase) UpdateBook(id int, book models.Book, info models.AuditInfo) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateBook", id, book)
+	ret := m.ctrl.Call(m, "UpdateBook", id, book, info)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpdateBook indicates an expected call of UpdateBook.
-func (mr *MockDatabaseMockRecorder) UpdateBook(id, book interface{}) *gomock.Call {
+func (mr *MockDatabaseMockRecorder) UpdateBook(id, book, info interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateBook", reflect.TypeOf((*MockDatabase)(nil).UpdateBook), id, book, info)
+}
+
+// UpdateCustomer mocks base method.
+func (m *MockDatabase) UpdateCustomer(id int, customer models.Customer) (models.Customer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateCustomer", id, customer)
+	ret0, _ := ret[0].(models.Customer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateCustomer indicates an expected call of UpdateCustomer.
+func (mr *MockDatabaseMockRecorder) UpdateCustomer(id, customer interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateCustomer", reflect.TypeOf((*MockDatabase)(nil).UpdateCustomer), id, customer)
+}
+
+// UpdateOrderStatus mocks base method.
+func (m *MockDatabase) UpdateOrderStatus(id int, status string, info models.AuditInfo) (models.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateOrderStatus", id, status, info)
+	ret0, _ := ret[0].(models.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateOrderStatus indicates an expected call of UpdateOrderStatus.
+func (mr *MockDatabaseMockRecorder) UpdateOrderStatus(id, status, info interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOrderStatus", reflect.TypeOf((*MockDatabase)(nil).UpdateOrderStatus), id, status, info)
+}
+
+// UpdatePromotion mocks base method.
+func (m *MockDatabase) UpdatePromotion(id int, promotion models.Promotion) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePromotion", id, promotion)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdatePromotion indicates an expected call of UpdatePromotion.
+func (mr *MockDatabaseMockRecorder) UpdatePromotion(id, promotion interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePromotion", reflect.TypeOf((*MockDatabase)(nil).UpdatePromotion), id, promotion)
+}
+
+// UpdateStockThreshold mocks base method.
+func (m *MockDatabase) UpdateStockThreshold(id int, threshold models.StockThreshold) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateStockThreshold", id, threshold)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateStockThreshold indicates an expected call of UpdateStockThreshold.
+func (mr *MockDatabaseMockRecorder) UpdateStockThreshold(id, threshold interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateStockThreshold", reflect.TypeOf((*MockDatabase)(nil).UpdateStockThreshold), id, threshold)
+}
+
+// UpdateWebhook mocks base method.
+func (m *MockDatabase) UpdateWebhook(id int, webhook models.Webhook) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateWebhook", id, webhook)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateWebhook indicates an expected call of UpdateWebhook.
+func (mr *MockDatabaseMockRecorder) UpdateWebhook(id, webhook interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateBook", reflect.TypeOf((*MockDatabase)(nil).UpdateBook), id, book)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateWebhook", reflect.TypeOf((*MockDatabase)(nil).UpdateWebhook), id, webhook)
 }
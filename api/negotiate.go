@@ -0,0 +1,379 @@
+package api
+
+import (
+	"encoding"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/gin-gonic/gin/render"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const mimeCSV = "text/csv"
+
+// formats are the representations books and reports are offered in, the
+// first one is the default.
+var formats = []string{binding.MIMEJSON, binding.MIMEXML, binding.MIMEXML2, mimeCSV, binding.MIMEMSGPACK, binding.MIMEMSGPACK2}
+
+// bodyBindings decode request bodies of the formats, bodies without a
+// content type are JSON.
+var bodyBindings = map[string]binding.Binding{
+	binding.MIMEJSON:     binding.JSON,
+	binding.MIMEXML:      binding.XML,
+	binding.MIMEXML2:     binding.XML,
+	mimeCSV:              csvBinding{},
+	binding.MIMEMSGPACK:  binding.MsgPack,
+	binding.MIMEMSGPACK2: binding.MsgPack,
+}
+
+var errCSVRows = errors.New("CSV body needs a header and a single row")
+
+// negotiate picks the format the Accept header prefers most, the default one
+// when there is no Accept header and none when it refuses every format. Of
+// formats with the same quality the one listed first in the header wins.
+func negotiate(accept string) string {
+	if strings.TrimSpace(accept) == "" {
+		return formats[0]
+	}
+	best, bestQuality, bestPosition := "", 0.0, 0
+	for _, format := range formats {
+		quality, position := acceptQuality(accept, format)
+		if quality > bestQuality || (quality > 0 && quality == bestQuality && position < bestPosition) {
+			best, bestQuality, bestPosition = format, quality, position
+		}
+	}
+	return best
+}
+
+// acceptQuality returns the quality the Accept header gives a format, taken
+// from the most specific media range matching it, and the position of that
+// range in the header. A format no range matches has quality 0.
+func acceptQuality(accept string, format string) (float64, int) {
+	quality, position, specificity := 0.0, 0, 0
+	for i, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+		var matched int
+		switch {
+		case mediaType == format:
+			matched = 3
+		case mediaType == "*/*":
+			matched = 1
+		case strings.HasSuffix(mediaType, "/*") && strings.HasPrefix(format, mediaType[:len(mediaType)-1]):
+			matched = 2
+		}
+		if matched <= specificity {
+			continue
+		}
+		quality, position, specificity = 1.0, i, matched
+		for _, param := range params[1:] {
+			if value := strings.TrimSpace(param); strings.HasPrefix(value, "q=") {
+				if q, err := strconv.ParseFloat(value[2:], 64); err == nil {
+					quality = q
+				}
+			}
+		}
+	}
+	return quality, position
+}
+
+// acceptable rejects requests to routes responding with respond before their
+// handler runs when the Accept header refuses every format, so nothing is
+// changed for a response the client won't take.
+func acceptable(c *gin.Context) {
+	if negotiate(c.GetHeader("Accept")) == "" {
+		notAcceptable(c)
+		return
+	}
+	c.Next()
+}
+
+func notAcceptable(c *gin.Context) {
+	c.Header("Vary", "Accept")
+	c.AbortWithStatusJSON(http.StatusNotAcceptable, ErrorMessage{"not acceptable, the formats are " + strings.Join(formats, ", ")})
+}
+
+// respond renders value in the format negotiated from the Accept header. In
+// XML a value is an element called name and a list an element called name
+// with an s holding one for each item. CSV has a column for every field,
+// nested fields are prefixed with the name of their parent.
+func respond(c *gin.Context, status int, name string, value interface{}) {
+	c.Header("Vary", "Accept")
+	switch format := negotiate(c.GetHeader("Accept")); format {
+	case "":
+		notAcceptable(c)
+	case binding.MIMEXML, binding.MIMEXML2:
+		c.Render(status, xmlValue{name: name, value: value, contentType: format})
+	case mimeCSV:
+		c.Render(status, csvValue{value: value})
+	case binding.MIMEMSGPACK, binding.MIMEMSGPACK2:
+		c.Header("Content-Type", format)
+		c.Render(status, render.MsgPack{Data: value})
+	default:
+		c.JSON(status, value)
+	}
+}
+
+// bindBody binds the request body in the format of its content type.
+func bindBody(c *gin.Context, obj interface{}) error {
+	if b, ok := bodyBindings[c.ContentType()]; ok {
+		return c.MustBindWith(obj, b)
+	}
+	return c.BindJSON(obj)
+}
+
+type xmlValue struct {
+	name        string
+	value       interface{}
+	contentType string
+}
+
+func (x xmlValue) Render(w http.ResponseWriter) error {
+	x.WriteContentType(w)
+	encoder := xml.NewEncoder(w)
+	items := reflect.ValueOf(x.value)
+	item := xml.StartElement{Name: xml.Name{Local: x.name}}
+	if items.Kind() != reflect.Slice {
+		return encoder.EncodeElement(x.value, item)
+	}
+	list := xml.StartElement{Name: xml.Name{Local: x.name + "s"}}
+	if err := encoder.EncodeToken(list); err != nil {
+		return err
+	}
+	for i := 0; i < items.Len(); i++ {
+		if err := encoder.EncodeElement(items.Index(i).Interface(), item); err != nil {
+			return err
+		}
+	}
+	if err := encoder.EncodeToken(list.End()); err != nil {
+		return err
+	}
+	return encoder.Flush()
+}
+
+func (x xmlValue) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", x.contentType+"; charset=utf-8")
+}
+
+type csvValue struct {
+	value interface{}
+}
+
+func (v csvValue) Render(w http.ResponseWriter) error {
+	v.WriteContentType(w)
+	rows := reflect.ValueOf(v.value)
+	if rows.Kind() != reflect.Slice {
+		rows = reflect.Append(reflect.MakeSlice(reflect.SliceOf(rows.Type()), 0, 1), rows)
+	}
+	columns := csvColumns(rows.Type().Elem(), "", nil)
+	writer := csv.NewWriter(w)
+	header := make([]string, 0, len(columns))
+	for _, column := range columns {
+		header = append(header, column.name)
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for i := 0; i < rows.Len(); i++ {
+		record := make([]string, 0, len(columns))
+		for _, column := range columns {
+			text, err := formatCSV(column.field(rows.Index(i), false))
+			if err != nil {
+				return err
+			}
+			record = append(record, text)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func (v csvValue) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", mimeCSV+"; charset=utf-8")
+}
+
+// csvBinding binds a CSV body with a header naming the columns as they are
+// rendered and a single row.
+type csvBinding struct{}
+
+func (csvBinding) Name() string {
+	return "csv"
+}
+
+func (csvBinding) Bind(req *http.Request, obj interface{}) error {
+	records, err := csv.NewReader(req.Body).ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(records) != 2 {
+		return errCSVRows
+	}
+	value := reflect.ValueOf(obj).Elem()
+	columns := map[string]csvColumn{}
+	for _, column := range csvColumns(value.Type(), "", nil) {
+		columns[column.name] = column
+	}
+	for i, name := range records[0] {
+		column, ok := columns[strings.TrimSpace(name)]
+		if !ok {
+			return fmt.Errorf("unknown CSV column %q", name)
+		}
+		if err := parseCSV(column.field(value, true), strings.TrimSpace(records[1][i])); err != nil {
+			return fmt.Errorf("CSV column %s: %w", column.name, err)
+		}
+	}
+	return binding.Validator.ValidateStruct(obj)
+}
+
+// csvColumn is a field of a flattened struct.
+type csvColumn struct {
+	name  string
+	index []int
+}
+
+// field returns the field of the struct v, allocating nil parents when
+// set, or an invalid value when a parent is nil.
+func (column csvColumn) field(v reflect.Value, set bool) reflect.Value {
+	for _, i := range column.index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !set {
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v
+}
+
+var textMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// csvColumns flattens the exported fields of a struct named by their JSON
+// names. Values marshaling to text aren't flattened, neither are slices.
+func csvColumns(t reflect.Type, prefix string, index []int) []csvColumn {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	var columns []csvColumn
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if field.PkgPath != "" || name == "-" {
+			continue
+		}
+		path := append(append([]int{}, index...), i)
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType.Kind() == reflect.Struct && !reflect.PtrTo(fieldType).Implements(textMarshaler) {
+			if field.Anonymous && name == "" {
+				columns = append(columns, csvColumns(fieldType, prefix, path)...)
+			} else {
+				columns = append(columns, csvColumns(fieldType, prefix+jsonName(field, name)+"_", path)...)
+			}
+			continue
+		}
+		columns = append(columns, csvColumn{name: prefix + jsonName(field, name), index: path})
+	}
+	return columns
+}
+
+func jsonName(field reflect.StructField, name string) string {
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// csvFormulaPrefixes start text that spreadsheets would run as a formula.
+const csvFormulaPrefixes = "=+-@"
+
+// formatCSV formats a field, slices are separated by semicolons. Text that
+// would be taken for a formula is prefixed with a quote, numbers are left
+// alone.
+func formatCSV(v reflect.Value) (string, error) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return "", nil
+	}
+	if marshaler, ok := v.Interface().(encoding.TextMarshaler); ok {
+		text, err := marshaler.MarshalText()
+		return string(text), err
+	}
+	if v.Kind() == reflect.Slice {
+		parts := make([]string, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			part, err := formatCSV(v.Index(i))
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, part)
+		}
+		return strings.Join(parts, ";"), nil
+	}
+	if v.Kind() == reflect.String {
+		text := v.String()
+		if text != "" && strings.ContainsRune(csvFormulaPrefixes, rune(text[0])) {
+			text = "'" + text
+		}
+		return text, nil
+	}
+	return fmt.Sprint(v.Interface()), nil
+}
+
+// parseCSV sets a field from its text, empty text leaves it zero. The quote
+// formatCSV puts before text starting like a formula is removed.
+func parseCSV(v reflect.Value, text string) error {
+	if text == "" {
+		return nil
+	}
+	if v.Kind() == reflect.Ptr {
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+	if unmarshaler, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(text))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		if len(text) > 1 && text[0] == '\'' && strings.ContainsRune(csvFormulaPrefixes, rune(text[1])) {
+			text = text[1:]
+		}
+		v.SetString(text)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Slice:
+		for _, part := range strings.Split(text, ";") {
+			item := reflect.New(v.Type().Elem()).Elem()
+			if err := parseCSV(item, strings.TrimSpace(part)); err != nil {
+				return err
+			}
+			v.Set(reflect.Append(v, item))
+		}
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
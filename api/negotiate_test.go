@@ -0,0 +1,294 @@
+package api
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/ugorji/go/codec"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept   string
+		expected string
+	}{
+		{accept: "", expected: "application/json"},
+		{accept: "*/*", expected: "application/json"},
+		{accept: "application/xml", expected: "application/xml"},
+		{accept: "text/csv;q=0.5, application/msgpack", expected: "application/msgpack"},
+		{accept: "text/html, text/*;q=0.8", expected: "text/xml"},
+		{accept: "text/html", expected: ""},
+		{accept: "application/jsonp", expected: ""},
+		{accept: "application/json;q=0", expected: ""},
+		{accept: "*/*, application/json;q=0", expected: "application/xml"},
+		{accept: "text/*;q=0.5, text/xml;q=0", expected: "text/csv"},
+		{accept: "application/msgpack, application/xml", expected: "application/msgpack"},
+	}
+	for _, test := range tests {
+		t.Run(test.accept, func(t *testing.T) {
+			assert.Equal(t, test.expected, negotiate(test.accept))
+		})
+	}
+}
+
+func TestAPIContentNegotiation(t *testing.T) {
+	type mockBehavior func(r *MockDatabase)
+	gin.SetMode(gin.ReleaseMode)
+	now := time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)
+	book := models.Book{ID: 1, Name: "Dune", Price: models.MustParseDecimal("9.99"), Currency: "USD", Genre: 3, Amount: 4,
+		Authors: []string{"Frank Herbert", "Brian Herbert"}, CreatedAt: now, UpdatedAt: now}
+	added := book
+	added.ID, added.CreatedAt, added.UpdatedAt = 0, time.Time{}, time.Time{}
+	formula := book
+	formula.Name, formula.Authors = "=HYPERLINK(\"http://example.com\")", []string{"@SUM(A1)", "Frank Herbert"}
+	formulaAdded := added
+	formulaAdded.Name = formula.Name
+	tests := []struct {
+		name                string
+		method              string
+		path                string
+		accept              string
+		contentType         string
+		body                string
+		mockBehavior        mockBehavior
+		expectedStatusCode  int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:   "XML list",
+			method: "GET",
+			path:   "/v2/books",
+			accept: "application/xml",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetCatalogModified().Return(now, nil)
+				r.EXPECT().GetAllBooks(map[string][]string{}).Return([]models.Book{book}, nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{}, nil)
+			},
+			expectedStatusCode:  http.StatusOK,
+			expectedContentType: "application/xml; charset=utf-8",
+			expectedBody: `<books><book><id>1</id><name>Dune</name><authors><author>Frank Herbert</author><author>Brian Herbert</author></authors>` +
+				`<price><amount>9.99</amount><currency>USD</currency></price><effective_price><amount>9.99</amount><currency>USD</currency></effective_price>` +
+				`<genre>3</genre><amount>4</amount><created_at>2021-11-20T10:00:00Z</created_at><updated_at>2021-11-20T10:00:00Z</updated_at></book></books>`,
+		},
+		{
+			name:   "CSV list",
+			method: "GET",
+			path:   "/v2/books",
+			accept: "text/csv",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetCatalogModified().Return(now, nil)
+				r.EXPECT().GetAllBooks(map[string][]string{}).Return([]models.Book{book}, nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{}, nil)
+			},
+			expectedStatusCode:  http.StatusOK,
+			expectedContentType: "text/csv; charset=utf-8",
+			expectedBody: "id,name,authors,price_amount,price_currency,effective_price_amount,effective_price_currency," +
+				"applied_promotions,genre,amount,created_at,updated_at\n" +
+				"1,Dune,Frank Herbert;Brian Herbert,9.99,USD,9.99,USD,,3,4,2021-11-20T10:00:00Z,2021-11-20T10:00:00Z\n",
+		},
+		{
+			name:   "XML search results",
+			method: "GET",
+			path:   "/v1/books/search?q=dune",
+			accept: "application/xml",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().SearchBooks("dune", 0).Return([]models.SearchResult{{Book: book, Rank: 0.5, Snippet: "<b>Dune</b>"}}, nil)
+			},
+			expectedStatusCode:  http.StatusOK,
+			expectedContentType: "application/xml; charset=utf-8",
+			expectedBody: `<results><result><id>1</id><name>Dune</name><price>9.99</price><currency>USD</currency><genre>3</genre><amount>4</amount>` +
+				`<rank>0.5</rank><snippet>&lt;b&gt;Dune&lt;/b&gt;</snippet></result></results>`,
+		},
+		{
+			name:   "v1 CSV book",
+			method: "GET",
+			path:   "/v1/books/1",
+			accept: "text/csv",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetCatalogModified().Return(now, nil)
+				r.EXPECT().GetBookById(1).Return(book, nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{}, nil)
+			},
+			expectedStatusCode:  http.StatusOK,
+			expectedContentType: "text/csv; charset=utf-8",
+			expectedBody:        "id,name,price,currency,genre,amount,effective_price,applied_promotions\n1,Dune,9.99,USD,3,4,9.99,\n",
+		},
+		{
+			name:   "CSV report",
+			method: "GET",
+			path:   "/v2/reports/price-changes",
+			accept: "text/csv",
+			mockBehavior: func(r *MockDatabase) {
+				old := models.MustParseDecimal("10")
+				r.EXPECT().GetPriceChangeReport(models.PriceFilter{}).Return([]models.PriceChangeReport{{
+					PriceChange: models.PriceChange{ID: 2, BookID: 1, OldPrice: &old, OldCurrency: "USD", Price: models.MustParseDecimal("9.99"),
+						Currency: "USD", Actor: "tester", RequestID: "1", ChangedAt: now},
+					Name: "Dune", Change: models.MustParseDecimal("-0.01"), ChangePercent: models.MustParseDecimal("-0.10")}}, nil)
+			},
+			expectedStatusCode:  http.StatusOK,
+			expectedContentType: "text/csv; charset=utf-8",
+			expectedBody: "id,book_id,old_price,old_currency,price,currency,actor,request_id,changed_at,name,change,change_percent\n" +
+				"2,1,10,USD,9.99,USD,tester,1,2021-11-20T10:00:00Z,Dune,-0.01,-0.10\n",
+		},
+		{
+			name:   "CSV formulas",
+			method: "GET",
+			path:   "/v2/books/1",
+			accept: "text/csv",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetCatalogModified().Return(now, nil)
+				r.EXPECT().GetBookById(1).Return(formula, nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{}, nil)
+			},
+			expectedStatusCode:  http.StatusOK,
+			expectedContentType: "text/csv; charset=utf-8",
+			expectedBody: "id,name,authors,price_amount,price_currency,effective_price_amount,effective_price_currency," +
+				"applied_promotions,genre,amount,created_at,updated_at\n" +
+				"1,\"'=HYPERLINK(\"\"http://example.com\"\")\",'@SUM(A1);Frank Herbert,9.99,USD,9.99,USD,,3,4,2021-11-20T10:00:00Z,2021-11-20T10:00:00Z\n",
+		},
+		{
+			name:                "refused formats",
+			method:              "GET",
+			path:                "/v2/books",
+			accept:              "application/json;q=0",
+			mockBehavior:        func(r *MockDatabase) {},
+			expectedStatusCode:  http.StatusNotAcceptable,
+			expectedContentType: "application/json; charset=utf-8",
+			expectedBody: `{"error":"not acceptable, the formats are application/json, application/xml, text/xml, text/csv, ` +
+				`application/x-msgpack, application/msgpack"}`,
+		},
+		{
+			name:                "refused formats of a new book",
+			method:              "POST",
+			path:                "/v2/books",
+			accept:              "text/html",
+			body:                `{"name":"Dune","price":{"amount":"9.99","currency":"USD"},"genre":3,"amount":4}`,
+			mockBehavior:        func(r *MockDatabase) {},
+			expectedStatusCode:  http.StatusNotAcceptable,
+			expectedContentType: "application/json; charset=utf-8",
+			expectedBody: `{"error":"not acceptable, the formats are application/json, application/xml, text/xml, text/csv, ` +
+				`application/x-msgpack, application/msgpack"}`,
+		},
+		{
+			name:        "XML body",
+			method:      "POST",
+			path:        "/v2/books",
+			accept:      "application/xml",
+			contentType: "application/xml",
+			body: `<book><name>Dune</name><authors><author>Frank Herbert</author><author>Brian Herbert</author></authors>` +
+				`<price><amount>9.99</amount><currency>USD</currency></price><genre>3</genre><amount>4</amount></book>`,
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().AddBook(added, gomock.Any()).Return(1, nil)
+			},
+			expectedStatusCode:  http.StatusOK,
+			expectedContentType: "application/xml; charset=utf-8",
+			expectedBody:        `<book><id>1</id></book>`,
+		},
+		{
+			name:        "CSV body",
+			method:      "PUT",
+			path:        "/v2/books/1",
+			contentType: "text/csv",
+			body:        "name,authors,price_amount,price_currency,genre,amount\nDune,Frank Herbert;Brian Herbert,9.99,USD,3,4\n",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().UpdateBook(1, added, gomock.Any()).Return(nil)
+				r.EXPECT().GetBookById(1).Return(book, nil)
+			},
+			expectedStatusCode:  http.StatusOK,
+			expectedContentType: "application/json; charset=utf-8",
+			expectedBody: `{"id":1,"name":"Dune","authors":["Frank Herbert","Brian Herbert"],"price":{"amount":"9.99","currency":"USD"},` +
+				`"genre":3,"amount":4,"created_at":"2021-11-20T10:00:00Z","updated_at":"2021-11-20T10:00:00Z"}`,
+		},
+		{
+			name:        "CSV body with a quoted formula",
+			method:      "POST",
+			path:        "/v2/books",
+			contentType: "text/csv",
+			body:        "name,authors,price_amount,price_currency,genre,amount\n\"'=HYPERLINK(\"\"http://example.com\"\")\",Frank Herbert;Brian Herbert,9.99,USD,3,4\n",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().AddBook(formulaAdded, gomock.Any()).Return(1, nil)
+			},
+			expectedStatusCode:  http.StatusOK,
+			expectedContentType: "application/json; charset=utf-8",
+			expectedBody:        `{"id":1}`,
+		},
+		{
+			name:                "invalid CSV body",
+			method:              "POST",
+			path:                "/v1/books",
+			contentType:         "text/csv",
+			body:                "name,price,genre,amount\nDune,9.999,3,4\n",
+			mockBehavior:        func(r *MockDatabase) {},
+			expectedStatusCode:  http.StatusBadRequest,
+			expectedContentType: "application/json; charset=utf-8",
+			expectedBody:        `{"error":"invalid input"}`,
+		},
+		{
+			name:                "CSV body with two rows",
+			method:              "POST",
+			path:                "/v1/books",
+			contentType:         "text/csv",
+			body:                "name,price,genre,amount\nDune,9.99,3,4\nEmma,5,1,1\n",
+			mockBehavior:        func(r *MockDatabase) {},
+			expectedStatusCode:  http.StatusBadRequest,
+			expectedContentType: "application/json; charset=utf-8",
+			expectedBody:        `{"error":"invalid input"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			db := NewMockDatabase(c)
+			test.mockBehavior(db)
+			rest_api := InitializeHandler(db)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+			req.Header.Set("Accept", test.accept)
+			req.Header.Set("Content-Type", test.contentType)
+
+			rest_api.Router.ServeHTTP(w, req)
+
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			assert.Equal(t, test.expectedContentType, w.Header().Get("Content-Type"))
+			assert.Equal(t, test.expectedBody, w.Body.String())
+		})
+	}
+}
+
+func TestAPIMessagePack(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	c := gomock.NewController(t)
+	defer c.Finish()
+	book := models.Book{Name: "Dune", Price: models.MustParseDecimal("9.99"), Currency: "USD", Genre: 3, Amount: 4}
+	db := NewMockDatabase(c)
+	db.EXPECT().UpdateBook(1, book, gomock.Any()).Return(nil)
+	rest_api := InitializeHandler(db)
+
+	var body bytes.Buffer
+	assert.NoError(t, codec.NewEncoder(&body, new(codec.MsgpackHandle)).Encode(book))
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("PUT", "/v1/books/1", &body)
+	req.Header.Set("Accept", "application/msgpack")
+	req.Header.Set("Content-Type", "application/msgpack")
+
+	rest_api.Router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/msgpack", w.Header().Get("Content-Type"))
+	var updated models.Book
+	assert.NoError(t, codec.NewDecoder(w.Body, new(codec.MsgpackHandle)).Decode(&updated))
+	book.ID = 1
+	assert.Equal(t, book, updated)
+}
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/legacy"
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files"
+	"log"
+	"net/http"
+)
+
+// openAPIDocuments describe every route registered in InitializeHandler.
+// openapi.json holds what the API versions share, v1.json and v2.json are
+// JSON merge patches (RFC 7386) with what differs, like the book schemas. The
+// contract test keeps them in sync with the routes.
+//
+//go:embed openapi/*.json
+var openAPIDocuments embed.FS
+
+// docsPage is the Swagger UI page for the OpenAPI documents, the UI assets
+// come bundled with swaggo/files.
+//
+//go:embed docs.html
+var docsPage []byte
+
+// openAPIDocument returns the OpenAPI document of an API version, the shared
+// document with the version's patch applied. Every operation of the
+// deprecated v1 is marked as such.
+func openAPIDocument(version int) ([]byte, error) {
+	shared, err := readOpenAPIFile("openapi/openapi.json")
+	if err != nil {
+		return nil, err
+	}
+	patch, err := readOpenAPIFile(fmt.Sprintf("openapi/v%d.json", version))
+	if err != nil {
+		return nil, err
+	}
+	doc, ok := mergePatch(shared, patch).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("OpenAPI document of v%d is not an object", version)
+	}
+	if version == 1 {
+		deprecateOperations(doc)
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func readOpenAPIFile(name string) (interface{}, error) {
+	data, err := openAPIDocuments.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return value, nil
+}
+
+// mergePatch applies a JSON merge patch to a document: objects are merged
+// recursively, null removes a member and anything else replaces it.
+func mergePatch(doc, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	docObject, ok := doc.(map[string]interface{})
+	if !ok {
+		docObject = map[string]interface{}{}
+	}
+	merged := make(map[string]interface{}, len(docObject)+len(patchObject))
+	for name, value := range docObject {
+		merged[name] = value
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(merged, name)
+			continue
+		}
+		merged[name] = mergePatch(merged[name], value)
+	}
+	return merged
+}
+
+// deprecateOperations marks every operation of a document as deprecated.
+func deprecateOperations(doc map[string]interface{}) {
+	paths, _ := doc["paths"].(map[string]interface{})
+	for _, item := range paths {
+		item, _ := item.(map[string]interface{})
+		for method, operation := range item {
+			operation, ok := operation.(map[string]interface{})
+			if ok && method != "parameters" {
+				operation["deprecated"] = true
+			}
+		}
+	}
+}
+
+// loadOpenAPI parses and validates the OpenAPI document of an API version.
+func loadOpenAPI(version int) (*openapi3.T, error) {
+	data, err := openAPIDocument(version)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := openapi3.NewLoader().LoadFromData(data)
+	if err != nil {
+		return nil, err
+	}
+	return doc, doc.Validate(context.Background())
+}
+
+// newOpenAPIRouter matches requests to the operations of an API version,
+// paths include the version prefix.
+func newOpenAPIRouter(version int) (routers.Router, error) {
+	doc, err := loadOpenAPI(version)
+	if err != nil {
+		return nil, err
+	}
+	return legacy.NewRouter(doc)
+}
+
+func getOpenAPI(version int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data, err := openAPIDocument(version)
+		if err != nil {
+			log.Println(err.Error())
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorMessage{"internal server error"})
+			return
+		}
+		c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+	}
+}
+
+func getDocs(c *gin.Context) {
+	switch c.Param("filepath") {
+	case "/", "/index.html":
+		c.Data(http.StatusOK, "text/html; charset=utf-8", docsPage)
+	default:
+		http.StripPrefix("/docs", http.FileServer(swaggerFiles.HTTP)).ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+// validateRequest checks JSON request bodies against an OpenAPI document
+// before they reach the handlers. Bodies without a content type are taken as
+// JSON.
+func validateRequest(router routers.Router) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route, params, err := router.FindRoute(c.Request)
+		if err != nil || route.Operation.RequestBody == nil {
+			c.Next()
+			return
+		}
+		if c.GetHeader("Content-Type") == "" {
+			c.Request.Header.Set("Content-Type", gin.MIMEJSON)
+		}
+		// Bodies in the other formats are validated when they are bound.
+		if _, ok := bodyBindings[c.ContentType()]; ok && c.ContentType() != gin.MIMEJSON {
+			c.Next()
+			return
+		}
+		input := &openapi3filter.RequestValidationInput{Request: c.Request, PathParams: params, Route: route}
+		if err := openapi3filter.ValidateRequestBody(c.Request.Context(), input, route.Operation.RequestBody.Value); err != nil {
+			log.Println(err.Error())
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,569 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// undocumentedRoutes serve the documentation itself, or describe themselves
+// like GraphQL.
+var undocumentedRoutes = map[string]bool{"GET /docs/*filepath": true, "POST /graphql": true}
+
+var ginParam = regexp.MustCompile(`:([^/]+)`)
+
+func TestOpenAPIDescribesRoutes(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	docs := map[string]*openapi3.T{}
+	for version := 1; version <= 2; version++ {
+		doc, err := loadOpenAPI(version)
+		if err != nil {
+			t.Fatalf("Invalid OpenAPI document: %s", err)
+		}
+		docs[fmt.Sprintf("/v%d", version)] = doc
+	}
+
+	registered := map[string]bool{}
+	for _, route := range InitializeHandler(nil).Router.Routes() {
+		key := route.Method + " " + route.Path
+		if undocumentedRoutes[key] {
+			continue
+		}
+		prefix := route.Path[:3]
+		doc := docs[prefix]
+		if !assert.NotNil(t, doc, "%s is outside the versions", key) {
+			continue
+		}
+		path := ginParam.ReplaceAllString(strings.TrimPrefix(route.Path, prefix), "{$1}")
+		registered[route.Method+" "+prefix+path] = true
+		item := doc.Paths.Find(path)
+		if !assert.NotNil(t, item, "%s is not described", key) {
+			continue
+		}
+		assert.NotNil(t, item.GetOperation(route.Method), "%s is not described", key)
+	}
+
+	for prefix, doc := range docs {
+		for path, item := range doc.Paths {
+			for method := range item.Operations() {
+				assert.True(t, registered[method+" "+prefix+path], "%s %s%s is described but not registered", method, prefix, path)
+			}
+		}
+	}
+}
+
+func TestOpenAPIVersions(t *testing.T) {
+	tests := []struct {
+		version    int
+		server     string
+		deprecated bool
+		bookFields []string
+	}{
+		{version: 1, server: "/v1", deprecated: true, bookFields: []string{"id", "name", "price", "currency", "genre", "amount"}},
+		{version: 2, server: "/v2", deprecated: false, bookFields: []string{"id", "name", "authors", "price", "genre", "amount", "created_at", "updated_at"}},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("v%d", test.version), func(t *testing.T) {
+			doc, err := loadOpenAPI(test.version)
+			if err != nil {
+				t.Fatalf("Invalid OpenAPI document: %s", err)
+			}
+			assert.Equal(t, fmt.Sprintf("%d.0.0", test.version), doc.Info.Version)
+			if assert.Len(t, doc.Servers, 1) {
+				assert.Equal(t, test.server, doc.Servers[0].URL)
+			}
+			for path, item := range doc.Paths {
+				for method, operation := range item.Operations() {
+					assert.Equal(t, test.deprecated, operation.Deprecated, "%s %s", method, path)
+				}
+			}
+			var fields []string
+			for field := range doc.Components.Schemas["Book"].Value.Properties {
+				fields = append(fields, field)
+			}
+			assert.ElementsMatch(t, test.bookFields, fields)
+		})
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		doc    string
+		patch  string
+		merged string
+	}{
+		{name: "Add member", doc: `{"a":1}`, patch: `{"b":2}`, merged: `{"a":1,"b":2}`},
+		{name: "Replace member", doc: `{"a":1,"b":2}`, patch: `{"a":3}`, merged: `{"a":3,"b":2}`},
+		{name: "Remove member", doc: `{"a":1,"b":2}`, patch: `{"a":null}`, merged: `{"b":2}`},
+		{name: "Merge nested", doc: `{"a":{"b":1,"c":2}}`, patch: `{"a":{"c":3,"d":4}}`, merged: `{"a":{"b":1,"c":3,"d":4}}`},
+		{name: "Replace array", doc: `{"a":[1,2]}`, patch: `{"a":[3]}`, merged: `{"a":[3]}`},
+		{name: "Replace value by object", doc: `{"a":1}`, patch: `{"a":{"b":null,"c":2}}`, merged: `{"a":{"c":2}}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var doc, patch interface{}
+			assert.NoError(t, json.Unmarshal([]byte(test.doc), &doc))
+			assert.NoError(t, json.Unmarshal([]byte(test.patch), &patch))
+			merged, err := json.Marshal(mergePatch(doc, patch))
+			assert.NoError(t, err)
+			assert.JSONEq(t, test.merged, string(merged))
+		})
+	}
+}
+
+func TestOpenAPIResponses(t *testing.T) {
+	type mockBehavior func(r *MockDatabase)
+	gin.SetMode(gin.ReleaseMode)
+	now := time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)
+	book := models.Book{ID: 1, Name: "Dune", Price: models.MustParseDecimal("9.99"), Currency: "USD", Genre: 3, Amount: 4,
+		Authors: []string{"Frank Herbert"}, CreatedAt: now, UpdatedAt: now}
+	order := models.Order{ID: 1, Status: models.OrderStatusPending, Currency: "USD", Total: models.MustParseDecimal("9.99"),
+		Items: []models.OrderItem{{BookID: 1, Name: "Dune", Quantity: 1, Price: models.MustParseDecimal("9.99")}}, CreatedAt: now, UpdatedAt: now}
+	customer := models.Customer{ID: 1, Name: "Ann", Email: "ann@example.com", Address: models.Address{City: "Oslo", Country: "NO"}, CreatedAt: now}
+	promotion := models.Promotion{ID: 1, Name: "Sale", Kind: models.PromotionPercentage, Value: models.MustParseDecimal("10"), Genre: 3, StartsAt: now}
+	priceChange := models.PriceChange{ID: 1, BookID: 1, Price: models.MustParseDecimal("9.99"), Currency: "USD", Actor: "migration", ChangedAt: now}
+	oldPrice := models.MustParseDecimal("12.00")
+	event := models.Event{ID: 1, Type: models.EventBookCreated, BookID: 1, Data: json.RawMessage(`{"id":1}`), CreatedAt: now}
+
+	tests := []struct {
+		name               string
+		method             string
+		path               string
+		body               string
+		mockBehavior       mockBehavior
+		expectedStatusCode int
+	}{
+		{
+			name:   "list books",
+			method: "GET",
+			path:   "/v1/books?genre=3",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetCatalogModified().Return(now, nil)
+				r.EXPECT().GetAllBooks(gomock.Any()).Return([]models.Book{book}, nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{promotion}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:   "get book",
+			method: "GET",
+			path:   "/v1/books/1",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetCatalogModified().Return(now, nil)
+				r.EXPECT().GetBookById(1).Return(book, nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:   "book not found",
+			method: "GET",
+			path:   "/v1/books/2",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetCatalogModified().Return(now, nil)
+				r.EXPECT().GetBookById(2).Return(models.Book{}, sql.ErrNoRows)
+			},
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:   "search books",
+			method: "GET",
+			path:   "/v1/books/search?q=dune",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().SearchBooks("dune", 0).Return([]models.SearchResult{{Book: book, Rank: 0.6, Snippet: "<b>Dune</b>"}}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:   "add book",
+			method: "POST",
+			path:   "/v1/books",
+			body:   `{"name":"Dune","price":"9.99","genre":3,"amount":4}`,
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().AddBook(gomock.Any(), gomock.Any()).Return(1, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "add invalid book",
+			method:             "POST",
+			path:               "/v1/books",
+			body:               `{"name":"Dune","price":"9.99","genre":"3","amount":4}`,
+			mockBehavior:       func(r *MockDatabase) {},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:   "update book",
+			method: "PUT",
+			path:   "/v1/books/1",
+			body:   `{"name":"Dune","price":9.99,"currency":"USD","genre":3,"amount":4}`,
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().UpdateBook(1, gomock.Any(), gomock.Any()).Return(nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:   "delete book",
+			method: "DELETE",
+			path:   "/v1/books/1",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetBookById(1).Return(book, nil)
+				r.EXPECT().DelBook(1, gomock.Any()).Return(nil)
+			},
+			expectedStatusCode: http.StatusNoContent,
+		},
+		{
+			name:   "book history",
+			method: "GET",
+			path:   "/v1/books/1/history",
+			mockBehavior: func(r *MockDatabase) {
+				entry, _ := models.NewAuditEntry(1, models.AuditActionCreate, models.AuditInfo{Actor: "tester", RequestID: "1"}, nil, book)
+				entry.CreatedAt = now
+				r.EXPECT().GetBookHistory(1).Return([]models.AuditEntry{entry}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:   "restore book",
+			method: "POST",
+			path:   "/v1/books/1/restore",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().RestoreBook(1, gomock.Any()).Return(book, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:   "book prices",
+			method: "GET",
+			path:   "/v1/books/1/prices?from=2021-11-01T00:00:00Z",
+			mockBehavior: func(r *MockDatabase) {
+				changed := priceChange
+				changed.OldPrice, changed.OldCurrency = &oldPrice, "USD"
+				r.EXPECT().GetPriceHistory(1, gomock.Any()).Return([]models.PriceChange{changed, priceChange}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:   "audit log",
+			method: "GET",
+			path:   "/v1/audit?action=create",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetAuditLog(gomock.Any()).Return([]models.AuditEntry{}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:   "place order",
+			method: "POST",
+			path:   "/v1/orders",
+			body:   `{"items":[{"book_id":1,"quantity":1}]}`,
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().CreateOrder(gomock.Any(), gomock.Any()).Return(order, nil)
+				r.EXPECT().GetBookById(1).Return(book, nil)
+			},
+			expectedStatusCode: http.StatusCreated,
+		},
+		{
+			name:   "get order",
+			method: "GET",
+			path:   "/v1/orders/1",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetOrder(1).Return(order, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:   "pay order",
+			method: "PUT",
+			path:   "/v1/orders/1/status",
+			body:   `{"status":"paid"}`,
+			mockBehavior: func(r *MockDatabase) {
+				paid := order
+				paid.Status = models.OrderStatusPaid
+				r.EXPECT().UpdateOrderStatus(1, models.OrderStatusPaid, gomock.Any()).Return(paid, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:   "add customer",
+			method: "POST",
+			path:   "/v1/customers",
+			body:   `{"name":"Ann","email":"ann@example.com","address":{"city":"Oslo","country":"NO"}}`,
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().AddCustomer(gomock.Any()).Return(customer, nil)
+			},
+			expectedStatusCode: http.StatusCreated,
+		},
+		{
+			name:   "customer orders",
+			method: "GET",
+			path:   "/v1/customers/1/orders",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetCustomerOrders(1).Return([]models.Order{order}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:   "list promotions",
+			method: "GET",
+			path:   "/v1/promotions",
+			mockBehavior: func(r *MockDatabase) {
+				ended := promotion
+				ends := now.Add(time.Hour)
+				ended.EndsAt = &ends
+				r.EXPECT().GetAllPromotions().Return([]models.Promotion{promotion, ended}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:   "add promotion",
+			method: "POST",
+			path:   "/v1/promotions",
+			body:   `{"name":"Sale","kind":"percentage","value":"10","genre":3,"starts_at":"2021-11-20T10:00:00Z"}`,
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().AddPromotion(gomock.Any()).Return(1, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:   "price change report",
+			method: "GET",
+			path:   "/v1/reports/price-changes?limit=5",
+			mockBehavior: func(r *MockDatabase) {
+				changed := priceChange
+				changed.OldPrice, changed.OldCurrency = &oldPrice, "USD"
+				r.EXPECT().GetPriceChangeReport(gomock.Any()).Return([]models.PriceChangeReport{{PriceChange: changed, Name: "Dune",
+					Change: models.MustParseDecimal("-2.01"), ChangePercent: models.MustParseDecimal("-16.75")}}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:   "low stock",
+			method: "GET",
+			path:   "/v1/inventory/low-stock",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetLowStockBooks().Return([]models.LowStockBook{{Book: book, Threshold: 5}}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:   "update threshold",
+			method: "PUT",
+			path:   "/v1/inventory/thresholds/1",
+			body:   `{"genre":3,"threshold":5}`,
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().UpdateStockThreshold(1, gomock.Any()).Return(nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:   "add webhook",
+			method: "POST",
+			path:   "/v1/webhooks",
+			body:   `{"url":"https://example.com/hook","events":["book.created"]}`,
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().AddWebhook(gomock.Any()).Return(1, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:   "list webhooks",
+			method: "GET",
+			path:   "/v1/webhooks",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetWebhooks().Return([]models.Webhook{{ID: 1, URL: "https://example.com/hook", Events: []string{models.EventBookCreated}, CreatedAt: now}}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:   "dead letters",
+			method: "GET",
+			path:   "/v1/webhooks/dead-letters",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetDeadDeliveries(100).Return([]models.Delivery{{ID: 1, WebhookID: 1, URL: "https://example.com/hook", Event: event,
+					Status: models.DeliveryDead, Attempts: 8, NextAttemptAt: now, ResponseStatus: 502, LastError: "bad gateway"}}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:   "retry dead letter",
+			method: "POST",
+			path:   "/v1/webhooks/dead-letters/1/retry",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().RetryDelivery(int64(1)).Return(nil)
+			},
+			expectedStatusCode: http.StatusNoContent,
+		},
+		{
+			name:   "purge books",
+			method: "DELETE",
+			path:   "/v1/admin/books",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().PurgeBooks(gomock.Any(), gomock.Any()).Return(2, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:   "list books v2",
+			method: "GET",
+			path:   "/v2/books?genre=3",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetCatalogModified().Return(now, nil)
+				r.EXPECT().GetAllBooks(gomock.Any()).Return([]models.Book{book}, nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{promotion}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:   "get book v2",
+			method: "GET",
+			path:   "/v2/books/1",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetCatalogModified().Return(now, nil)
+				r.EXPECT().GetBookById(1).Return(book, nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:   "add book v2",
+			method: "POST",
+			path:   "/v2/books",
+			body:   `{"name":"Dune","authors":["Frank Herbert"],"price":{"amount":"9.99","currency":"USD"},"genre":3,"amount":4}`,
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().AddBook(models.Book{Name: "Dune", Authors: []string{"Frank Herbert"}, Price: models.MustParseDecimal("9.99"),
+					Currency: "USD", Genre: 3, Amount: 4}, gomock.Any()).Return(1, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "add v1 book to v2",
+			method:             "POST",
+			path:               "/v2/books",
+			body:               `{"name":"Dune","price":"9.99","currency":"USD","genre":3,"amount":4}`,
+			mockBehavior:       func(r *MockDatabase) {},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:   "update book v2",
+			method: "PUT",
+			path:   "/v2/books/1",
+			body:   `{"name":"Dune","price":{"amount":9.99,"currency":"USD"},"genre":3,"amount":4}`,
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().UpdateBook(1, models.Book{ID: 0, Name: "Dune", Price: models.MustParseDecimal("9.99"),
+					Currency: "USD", Genre: 3, Amount: 4}, gomock.Any()).Return(nil)
+				r.EXPECT().GetBookById(1).Return(book, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:   "restore book v2",
+			method: "POST",
+			path:   "/v2/books/1/restore",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().RestoreBook(1, gomock.Any()).Return(book, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "OpenAPI document v2",
+			method:             "GET",
+			path:               "/v2/openapi.json",
+			mockBehavior:       func(r *MockDatabase) {},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "OpenAPI document",
+			method:             "GET",
+			path:               "/v1/openapi.json",
+			mockBehavior:       func(r *MockDatabase) {},
+			expectedStatusCode: http.StatusOK,
+		},
+	}
+
+	openAPIRouters := map[string]routers.Router{}
+	for version := 1; version <= 2; version++ {
+		router, err := newOpenAPIRouter(version)
+		if err != nil {
+			t.Fatalf("Invalid OpenAPI document: %s", err)
+		}
+		openAPIRouters[fmt.Sprintf("/v%d/", version)] = router
+	}
+	options := &openapi3filter.Options{AuthenticationFunc: openapi3filter.NoopAuthenticationFunc}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			db := NewMockDatabase(c)
+			test.mockBehavior(db)
+			t.Setenv("ADMIN_TOKEN", "secret")
+			rest_api := InitializeHandler(db)
+
+			req := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+			req.Header.Set(adminTokenHeader, "secret")
+			if test.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			route, params, err := openAPIRouters[test.path[:4]].FindRoute(req)
+			if !assert.NoError(t, err) {
+				return
+			}
+			input := &openapi3filter.RequestValidationInput{Request: req, PathParams: params, Route: route, Options: options}
+			requestErr := openapi3filter.ValidateRequest(context.Background(), input)
+			if test.expectedStatusCode == http.StatusBadRequest {
+				assert.Error(t, requestErr)
+			} else {
+				assert.NoError(t, requestErr)
+			}
+
+			w := httptest.NewRecorder()
+			rest_api.Router.ServeHTTP(w, req)
+
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			err = openapi3filter.ValidateResponse(context.Background(), &openapi3filter.ResponseValidationInput{
+				RequestValidationInput: input,
+				Status:                 w.Code,
+				Header:                 w.Header(),
+				Body:                   io.NopCloser(bytes.NewReader(w.Body.Bytes())),
+				Options:                options,
+			})
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestDocs(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	rest_api := InitializeHandler(nil)
+
+	for path, contentType := range map[string]string{
+		"/docs/":                     "text/html; charset=utf-8",
+		"/docs/swagger-ui-bundle.js": "text/javascript; charset=utf-8",
+	} {
+		w := httptest.NewRecorder()
+		rest_api.Router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		assert.Equal(t, http.StatusOK, w.Code, path)
+		assert.Equal(t, contentType, w.Header().Get("Content-Type"), path)
+	}
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"database/sql"
+	"github.com/gin-gonic/gin"
+	"github.com/porky256/rest-api/db"
+	"github.com/porky256/rest-api/models"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// postOrder creates an order from JSON received in the request body,
+// reserving stock for all of its items.
+func (handler *Handler) postOrder(c *gin.Context) {
+	var newOrder models.Order
+
+	if err := c.BindJSON(&newOrder); err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
+		return
+	}
+
+	order, err := handler.DataBase.CreateOrder(newOrder, auditInfo(c))
+	if err != nil {
+		orderError(c, err)
+		return
+	}
+	handler.publishStockChanges(order.Items)
+	c.JSON(http.StatusCreated, order)
+}
+
+func (handler *Handler) getOrderByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
+		return
+	}
+
+	order, err := handler.DataBase.GetOrder(id)
+	if err != nil {
+		orderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, order)
+}
+
+// updateOrderStatus moves an order to the status received in the request body.
+func (handler *Handler) updateOrderStatus(c *gin.Context) {
+	var status models.OrderStatus
+
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
+		return
+	}
+
+	if err := c.BindJSON(&status); err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
+		return
+	}
+
+	order, err := handler.DataBase.UpdateOrderStatus(id, status.Status, auditInfo(c))
+	if err != nil {
+		orderError(c, err)
+		return
+	}
+	if status.Status == models.OrderStatusCancelled {
+		handler.publishStockChanges(order.Items)
+	}
+	c.JSON(http.StatusOK, order)
+}
+
+func orderError(c *gin.Context, err error) {
+	log.Println(err.Error())
+	switch err {
+	case sql.ErrNoRows:
+		c.AbortWithStatusJSON(http.StatusNotFound, ErrorMessage{"id not found"})
+	case db.ErrUnknownBook:
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"unknown book"})
+	case db.ErrUnknownCustomer:
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"unknown customer"})
+	case db.ErrMixedCurrencies:
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, ErrorMessage{"order items have different currencies"})
+	case models.ErrDecimalOverflow:
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, ErrorMessage{"order total is too large"})
+	case db.ErrInsufficientStock:
+		c.AbortWithStatusJSON(http.StatusConflict, ErrorMessage{"insufficient stock"})
+	case db.ErrInvalidTransition:
+		c.AbortWithStatusJSON(http.StatusConflict, ErrorMessage{"invalid status transition"})
+	default:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorMessage{"internal server error"})
+	}
+}
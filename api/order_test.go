@@ -0,0 +1,199 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/porky256/rest-api/db"
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAPIPostOrder(t *testing.T) {
+	type mockBehavior func(s *MockDatabase, order models.Order)
+	gin.SetMode(gin.ReleaseMode)
+	created := time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name                 string
+		inputBody            string
+		inputOrder           models.Order
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:       "OK",
+			inputBody:  `{"items":[{"book_id":1,"quantity":2}]}`,
+			inputOrder: models.Order{Items: []models.OrderItem{{BookID: 1, Quantity: 2}}},
+			mockBehavior: func(r *MockDatabase, order models.Order) {
+				r.EXPECT().CreateOrder(order, gomock.Any()).Return(models.Order{ID: 1, Status: models.OrderStatusPending, Total: models.MustParseDecimal("4"), Currency: "USD",
+					Items: []models.OrderItem{{BookID: 1, Name: "OK", Quantity: 2, Price: models.MustParseDecimal("2")}}, CreatedAt: created, UpdatedAt: created}, nil)
+			},
+			expectedStatusCode: http.StatusCreated,
+			expectedResponseBody: `{"id":1,"status":"pending","items":[{"book_id":1,"name":"OK","quantity":2,"price":"2"}],"total":"4","currency":"USD",` +
+				`"created_at":"2021-11-20T10:00:00Z","updated_at":"2021-11-20T10:00:00Z"}`,
+		},
+		{
+			name:                 "No items",
+			inputBody:            `{"items":[]}`,
+			mockBehavior:         func(r *MockDatabase, order models.Order) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"invalid input"}`,
+		},
+		{
+			name:                 "Invalid quantity",
+			inputBody:            `{"items":[{"book_id":1,"quantity":0}]}`,
+			mockBehavior:         func(r *MockDatabase, order models.Order) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"invalid input"}`,
+		},
+		{
+			name:       "Insufficient stock",
+			inputBody:  `{"items":[{"book_id":1,"quantity":100}]}`,
+			inputOrder: models.Order{Items: []models.OrderItem{{BookID: 1, Quantity: 100}}},
+			mockBehavior: func(r *MockDatabase, order models.Order) {
+				r.EXPECT().CreateOrder(order, gomock.Any()).Return(models.Order{}, db.ErrInsufficientStock)
+			},
+			expectedStatusCode:   http.StatusConflict,
+			expectedResponseBody: `{"error":"insufficient stock"}`,
+		},
+		{
+			name:       "Unknown book",
+			inputBody:  `{"items":[{"book_id":256,"quantity":1}]}`,
+			inputOrder: models.Order{Items: []models.OrderItem{{BookID: 256, Quantity: 1}}},
+			mockBehavior: func(r *MockDatabase, order models.Order) {
+				r.EXPECT().CreateOrder(order, gomock.Any()).Return(models.Order{}, db.ErrUnknownBook)
+			},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"unknown book"}`,
+		},
+		{
+			name:       "Mixed currencies",
+			inputBody:  `{"items":[{"book_id":1,"quantity":1},{"book_id":2,"quantity":1}]}`,
+			inputOrder: models.Order{Items: []models.OrderItem{{BookID: 1, Quantity: 1}, {BookID: 2, Quantity: 1}}},
+			mockBehavior: func(r *MockDatabase, order models.Order) {
+				r.EXPECT().CreateOrder(order, gomock.Any()).Return(models.Order{}, db.ErrMixedCurrencies)
+			},
+			expectedStatusCode:   http.StatusUnprocessableEntity,
+			expectedResponseBody: `{"error":"order items have different currencies"}`,
+		},
+		{
+			name:       "Total overflow",
+			inputBody:  `{"items":[{"book_id":1,"quantity":2000000000}]}`,
+			inputOrder: models.Order{Items: []models.OrderItem{{BookID: 1, Quantity: 2000000000}}},
+			mockBehavior: func(r *MockDatabase, order models.Order) {
+				r.EXPECT().CreateOrder(order, gomock.Any()).Return(models.Order{}, models.ErrDecimalOverflow)
+			},
+			expectedStatusCode:   http.StatusUnprocessableEntity,
+			expectedResponseBody: `{"error":"order total is too large"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			db := NewMockDatabase(c)
+			test.mockBehavior(db, test.inputOrder)
+			rest_api := Handler{Router: gin.Default(), DataBase: db}
+
+			r := gin.New()
+			r.POST("/orders", rest_api.postOrder)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/orders",
+				bytes.NewBufferString(test.inputBody))
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			assert.Equal(t, test.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestAPIUpdateOrderStatus(t *testing.T) {
+	type mockBehavior func(s *MockDatabase, id interface{})
+	gin.SetMode(gin.ReleaseMode)
+	created := time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name                 string
+		inputID              interface{}
+		inputBody            string
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:      "OK",
+			inputID:   1,
+			inputBody: `{"status":"paid"}`,
+			mockBehavior: func(r *MockDatabase, id interface{}) {
+				r.EXPECT().UpdateOrderStatus(id, models.OrderStatusPaid, gomock.Any()).Return(models.Order{ID: 1, Status: models.OrderStatusPaid,
+					Items: []models.OrderItem{}, Currency: "USD", CreatedAt: created, UpdatedAt: created}, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{"id":1,"status":"paid","items":[],"total":"0","currency":"USD","created_at":"2021-11-20T10:00:00Z","updated_at":"2021-11-20T10:00:00Z"}`,
+		},
+		{
+			name:                 "unknown status",
+			inputID:              1,
+			inputBody:            `{"status":"lost"}`,
+			mockBehavior:         func(r *MockDatabase, id interface{}) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"invalid input"}`,
+		},
+		{
+			name:      "invalid transition",
+			inputID:   1,
+			inputBody: `{"status":"pending"}`,
+			mockBehavior: func(r *MockDatabase, id interface{}) {
+				r.EXPECT().UpdateOrderStatus(id, models.OrderStatusPending, gomock.Any()).Return(models.Order{}, db.ErrInvalidTransition)
+			},
+			expectedStatusCode:   http.StatusConflict,
+			expectedResponseBody: `{"error":"invalid status transition"}`,
+		},
+		{
+			name:      "id not found",
+			inputID:   256,
+			inputBody: `{"status":"paid"}`,
+			mockBehavior: func(r *MockDatabase, id interface{}) {
+				r.EXPECT().UpdateOrderStatus(id, models.OrderStatusPaid, gomock.Any()).Return(models.Order{}, sql.ErrNoRows)
+			},
+			expectedStatusCode:   http.StatusNotFound,
+			expectedResponseBody: `{"error":"id not found"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			db := NewMockDatabase(c)
+			test.mockBehavior(db, test.inputID)
+			rest_api := Handler{Router: gin.Default(), DataBase: db}
+
+			r := gin.New()
+			r.PUT("/orders/:id/status", rest_api.updateOrderStatus)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("PUT", fmt.Sprintf("/orders/%v/status", test.inputID),
+				bytes.NewBufferString(test.inputBody))
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			assert.Equal(t, test.expectedResponseBody, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/porky256/rest-api/models"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var errInvalidRange = errors.New("invalid time range")
+
+// getBookPrices responds with the price changes of a single book narrowed
+// by the query filters from, to (RFC 3339) and limit.
+func (handler *Handler) getBookPrices(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
+		return
+	}
+
+	filter, err := parsePriceFilter(c)
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid filter condition"})
+		return
+	}
+
+	list, err := handler.DataBase.GetPriceHistory(id, filter)
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorMessage{"internal server error"})
+		return
+	}
+	respond(c, http.StatusOK, "price_change", list)
+}
+
+// getPriceChangeReport responds with the largest relative price changes
+// within the from and to query filters.
+func (handler *Handler) getPriceChangeReport(c *gin.Context) {
+	filter, err := parsePriceFilter(c)
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid filter condition"})
+		return
+	}
+
+	list, err := handler.DataBase.GetPriceChangeReport(filter)
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorMessage{"internal server error"})
+		return
+	}
+	respond(c, http.StatusOK, "price_change", list)
+}
+
+func parsePriceFilter(c *gin.Context) (models.PriceFilter, error) {
+	var filter models.PriceFilter
+	var err error
+	if value := c.Query("limit"); value != "" {
+		if filter.Limit, err = strconv.Atoi(value); err != nil {
+			return filter, err
+		}
+	}
+	if value := c.Query("from"); value != "" {
+		if filter.From, err = time.Parse(time.RFC3339, value); err != nil {
+			return filter, err
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		if filter.To, err = time.Parse(time.RFC3339, value); err != nil {
+			return filter, err
+		}
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.To.After(filter.From) {
+		return filter, errInvalidRange
+	}
+	return filter, nil
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAPIGetBookPrices(t *testing.T) {
+	type mockBehavior func(s *MockDatabase)
+	gin.SetMode(gin.ReleaseMode)
+	changed := time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)
+	oldPrice := models.MustParseDecimal("10.00")
+	tests := []struct {
+		name                 string
+		url                  string
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name: "OK",
+			url:  "/books/1/prices?from=2021-11-01T00:00:00Z&to=2021-12-01T00:00:00Z",
+			mockBehavior: func(r *MockDatabase) {
+				filter := models.PriceFilter{From: time.Date(2021, 11, 1, 0, 0, 0, 0, time.UTC),
+					To: time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)}
+				r.EXPECT().GetPriceHistory(1, filter).Return([]models.PriceChange{{ID: 2, BookID: 1, OldPrice: &oldPrice,
+					OldCurrency: "USD", Price: models.MustParseDecimal("12.50"), Currency: "USD", Actor: "tester",
+					RequestID: "abc", ChangedAt: changed}}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedResponseBody: `[{"id":2,"book_id":1,"old_price":"10.00","old_currency":"USD","price":"12.50",` +
+				`"currency":"USD","actor":"tester","request_id":"abc","changed_at":"2021-11-20T10:00:00Z"}]`,
+		},
+		{
+			name: "Initial price",
+			url:  "/books/1/prices",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetPriceHistory(1, models.PriceFilter{}).Return([]models.PriceChange{{ID: 1, BookID: 1,
+					Price: models.MustParseDecimal("10.00"), Currency: "USD", Actor: "tester", RequestID: "abc",
+					ChangedAt: changed}}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedResponseBody: `[{"id":1,"book_id":1,"old_price":null,"price":"10.00",` +
+				`"currency":"USD","actor":"tester","request_id":"abc","changed_at":"2021-11-20T10:00:00Z"}]`,
+		},
+		{
+			name:                 "Invalid id",
+			url:                  "/books/a/prices",
+			mockBehavior:         func(r *MockDatabase) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"invalid input"}`,
+		},
+		{
+			name:                 "Invalid time",
+			url:                  "/books/1/prices?from=yesterday",
+			mockBehavior:         func(r *MockDatabase) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"invalid filter condition"}`,
+		},
+		{
+			name:                 "Reversed range",
+			url:                  "/books/1/prices?from=2021-12-01T00:00:00Z&to=2021-11-01T00:00:00Z",
+			mockBehavior:         func(r *MockDatabase) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"invalid filter condition"}`,
+		},
+		{
+			name: "Database error",
+			url:  "/books/1/prices",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetPriceHistory(1, models.PriceFilter{}).Return(nil, errors.New("database error"))
+			},
+			expectedStatusCode:   http.StatusInternalServerError,
+			expectedResponseBody: `{"error":"internal server error"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			db := NewMockDatabase(c)
+			test.mockBehavior(db)
+			rest_api := Handler{Router: gin.Default(), DataBase: db}
+
+			r := gin.New()
+			r.GET("/books/:id/prices", rest_api.getBookPrices)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", test.url, nil)
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			assert.Equal(t, test.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestAPIGetPriceChangeReport(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	changed := time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)
+	oldPrice := models.MustParseDecimal("10.00")
+
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	db := NewMockDatabase(c)
+	db.EXPECT().GetPriceChangeReport(models.PriceFilter{From: time.Date(2021, 11, 1, 0, 0, 0, 0, time.UTC), Limit: 1}).
+		Return([]models.PriceChangeReport{{
+			PriceChange: models.PriceChange{ID: 2, BookID: 1, OldPrice: &oldPrice, OldCurrency: "USD",
+				Price: models.MustParseDecimal("5.00"), Currency: "USD", Actor: "tester", RequestID: "abc", ChangedAt: changed},
+			Name:          "Dune",
+			Change:        models.MustParseDecimal("-5.00"),
+			ChangePercent: models.MustParseDecimal("-50.00"),
+		}}, nil)
+	rest_api := Handler{Router: gin.Default(), DataBase: db}
+
+	r := gin.New()
+	r.GET("/reports/price-changes", rest_api.getPriceChangeReport)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/reports/price-changes?from=2021-11-01T00:00:00Z&limit=1", nil)
+
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `[{"id":2,"book_id":1,"old_price":"10.00","old_currency":"USD","price":"5.00","currency":"USD",`+
+		`"actor":"tester","request_id":"abc","changed_at":"2021-11-20T10:00:00Z","name":"Dune","change":"-5.00",`+
+		`"change_percent":"-50.00"}]`, w.Body.String())
+}
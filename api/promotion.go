@@ -0,0 +1,129 @@
+package api
+
+import (
+	"database/sql"
+	"github.com/gin-gonic/gin"
+	"github.com/porky256/rest-api/models"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// priceBooks attaches the price after the currently active promotions to every book.
+func (handler *Handler) priceBooks(books []models.Book) ([]models.PricedBook, error) {
+	now := time.Now()
+	promotions, err := handler.DataBase.GetActivePromotions(now)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]models.PricedBook, 0, len(books))
+	for _, book := range books {
+		price, applied, err := models.EffectivePrice(book, promotions, now)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, models.PricedBook{Book: book, EffectivePrice: price, AppliedPromotions: applied})
+	}
+	return list, nil
+}
+
+func (handler *Handler) getPromotions(c *gin.Context) {
+	list, err := handler.DataBase.GetAllPromotions()
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorMessage{"internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+func (handler *Handler) getPromotionByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
+		return
+	}
+
+	promotion, err := handler.DataBase.GetPromotionById(id)
+	if err != nil {
+		promotionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, promotion)
+}
+
+// postPromotion adds a promotion from JSON received in the request body.
+func (handler *Handler) postPromotion(c *gin.Context) {
+	var newPromotion models.Promotion
+
+	if err := c.BindJSON(&newPromotion); err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
+		return
+	}
+
+	id, err := handler.DataBase.AddPromotion(newPromotion)
+	if err != nil {
+		promotionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"id": id})
+}
+
+func (handler *Handler) updatePromotion(c *gin.Context) {
+	var newPromotion models.Promotion
+
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
+		return
+	}
+
+	if err := c.BindJSON(&newPromotion); err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
+		return
+	}
+
+	err = handler.DataBase.UpdatePromotion(id, newPromotion)
+	newPromotion.ID = id
+	if err != nil {
+		promotionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, newPromotion)
+}
+
+func (handler *Handler) deletePromotion(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
+		return
+	}
+
+	err = handler.DataBase.DelPromotion(id)
+	if err != nil {
+		promotionError(c, err)
+		return
+	}
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+func promotionError(c *gin.Context, err error) {
+	log.Println(err.Error())
+	switch err {
+	case sql.ErrNoRows:
+		c.AbortWithStatusJSON(http.StatusNotFound, ErrorMessage{"id not found"})
+	default:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorMessage{"internal server error"})
+	}
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAPIPostPromotion(t *testing.T) {
+	type mockBehavior func(s *MockDatabase, promotion models.Promotion)
+	gin.SetMode(gin.ReleaseMode)
+	start := time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name                 string
+		inputBody            string
+		inputPromotion       models.Promotion
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:      "OK",
+			inputBody: `{"name":"Sale","kind":"percentage","value":"10","genre":2,"starts_at":"2021-11-20T10:00:00Z"}`,
+			inputPromotion: models.Promotion{Name: "Sale", Kind: models.PromotionPercentage, Value: models.MustParseDecimal("10"),
+				Genre: 2, StartsAt: start},
+			mockBehavior: func(r *MockDatabase, promotion models.Promotion) {
+				r.EXPECT().AddPromotion(promotion).Return(1, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{"id":1}`,
+		},
+		{
+			name:                 "Unknown kind",
+			inputBody:            `{"name":"Sale","kind":"bogo","value":"10","starts_at":"2021-11-20T10:00:00Z"}`,
+			mockBehavior:         func(r *MockDatabase, promotion models.Promotion) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"invalid input"}`,
+		},
+		{
+			name:                 "Percentage over hundred",
+			inputBody:            `{"name":"Sale","kind":"percentage","value":"150","starts_at":"2021-11-20T10:00:00Z"}`,
+			mockBehavior:         func(r *MockDatabase, promotion models.Promotion) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"invalid input"}`,
+		},
+		{
+			name:                 "Fixed without currency",
+			inputBody:            `{"name":"Sale","kind":"fixed","value":"2.50","starts_at":"2021-11-20T10:00:00Z"}`,
+			mockBehavior:         func(r *MockDatabase, promotion models.Promotion) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"invalid input"}`,
+		},
+		{
+			name: "Ends before start",
+			inputBody: `{"name":"Sale","kind":"percentage","value":"10","starts_at":"2021-11-20T10:00:00Z",` +
+				`"ends_at":"2021-11-19T10:00:00Z"}`,
+			mockBehavior:         func(r *MockDatabase, promotion models.Promotion) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"invalid input"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			db := NewMockDatabase(c)
+			test.mockBehavior(db, test.inputPromotion)
+			rest_api := Handler{Router: gin.Default(), DataBase: db}
+
+			r := gin.New()
+			r.POST("/promotions", rest_api.postPromotion)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/promotions",
+				bytes.NewBufferString(test.inputBody))
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			assert.Equal(t, test.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestAPIDeletePromotion(t *testing.T) {
+	type mockBehavior func(s *MockDatabase, id int)
+	gin.SetMode(gin.ReleaseMode)
+	tests := []struct {
+		name                 string
+		inputId              string
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:    "OK",
+			inputId: "1",
+			mockBehavior: func(r *MockDatabase, id int) {
+				r.EXPECT().DelPromotion(id).Return(nil)
+			},
+			expectedStatusCode: http.StatusNoContent,
+		},
+		{
+			name:    "Not found",
+			inputId: "1",
+			mockBehavior: func(r *MockDatabase, id int) {
+				r.EXPECT().DelPromotion(id).Return(sql.ErrNoRows)
+			},
+			expectedStatusCode:   http.StatusNotFound,
+			expectedResponseBody: `{"error":"id not found"}`,
+		},
+		{
+			name:                 "Invalid id",
+			inputId:              "a",
+			mockBehavior:         func(r *MockDatabase, id int) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"invalid input"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			db := NewMockDatabase(c)
+			id := 1
+			test.mockBehavior(db, id)
+			rest_api := Handler{Router: gin.Default(), DataBase: db}
+
+			r := gin.New()
+			r.DELETE("/promotions/:id", rest_api.deletePromotion)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("DELETE", "/promotions/"+test.inputId, nil)
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			assert.Equal(t, test.expectedResponseBody, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const maxSearchQueryLength = 100
+
+// searchBooks responds with books whose names match the q query parameter,
+// most relevant first.
+func (handler *Handler) searchBooks(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" || len(query) > maxSearchQueryLength {
+		log.Println("invalid search query")
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid search query"})
+		return
+	}
+
+	var limit int
+	if value := c.Query("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			log.Println("invalid search limit")
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid search query"})
+			return
+		}
+	}
+
+	list, err := handler.DataBase.SearchBooks(query, limit)
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorMessage{"internal server error"})
+		return
+	}
+	renderSearchResults(c, list)
+}
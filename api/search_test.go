@@ -0,0 +1,91 @@
+package api
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestAPISearchBooks(t *testing.T) {
+	type mockBehavior func(s *MockDatabase)
+	gin.SetMode(gin.ReleaseMode)
+	tests := []struct {
+		name                 string
+		query                url.Values
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:  "OK",
+			query: url.Values{"q": {"ring"}},
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().SearchBooks("ring", 0).Return([]models.SearchResult{
+					{Book: models.Book{ID: 1, Name: "The Ring", Price: models.MustParseDecimal("1"), Currency: "USD", Genre: 3, Amount: 1}, Rank: 0.5, Snippet: "The <b>Ring</b>"}}, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `[{"id":1,"name":"The Ring","price":"1","currency":"USD","genre":3,"amount":1,"rank":0.5,"snippet":"The \u003cb\u003eRing\u003c/b\u003e"}]`,
+		},
+		{
+			name:  "OK with limit",
+			query: url.Values{"q": {"ring"}, "limit": {"5"}},
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().SearchBooks("ring", 5).Return([]models.SearchResult{}, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `[]`,
+		},
+		{
+			name:                 "empty query",
+			query:                url.Values{"q": {"  "}},
+			mockBehavior:         func(r *MockDatabase) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"invalid search query"}`,
+		},
+		{
+			name:                 "invalid limit",
+			query:                url.Values{"q": {"ring"}, "limit": {"-1"}},
+			mockBehavior:         func(r *MockDatabase) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"invalid search query"}`,
+		},
+		{
+			name:  "database error",
+			query: url.Values{"q": {"ring"}},
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().SearchBooks("ring", 0).Return(nil, errors.New("database error"))
+			},
+			expectedStatusCode:   http.StatusInternalServerError,
+			expectedResponseBody: `{"error":"internal server error"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			db := NewMockDatabase(c)
+			test.mockBehavior(db)
+			rest_api := Handler{Router: gin.Default(), DataBase: db}
+
+			r := gin.New()
+			r.GET("/books/search", rest_api.searchBooks)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/books/search?"+test.query.Encode(), nil)
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			assert.Equal(t, test.expectedResponseBody, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/porky256/rest-api/models"
+)
+
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterStructValidation(bookStructLevel, models.Book{})
+		v.RegisterStructValidation(bookV2StructLevel, models.BookV2{})
+		v.RegisterStructValidation(promotionStructLevel, models.Promotion{})
+		v.RegisterStructValidation(thresholdStructLevel, models.StockThreshold{})
+	}
+}
+
+// bookStructLevel rejects negative prices and prices with more decimal
+// places than the book's currency has.
+func bookStructLevel(sl validator.StructLevel) {
+	book := sl.Current().Interface().(models.Book)
+	currency := book.Currency
+	if currency == "" {
+		currency = models.DefaultCurrency
+	}
+	if !models.ValidPrice(book.Price, currency) {
+		sl.ReportError(book.Price, "Price", "price", "price", currency)
+	}
+}
+
+func bookV2StructLevel(sl validator.StructLevel) {
+	book := sl.Current().Interface().(models.BookV2)
+	if !models.ValidPrice(book.Price.Amount, book.Price.Currency) {
+		sl.ReportError(book.Price.Amount, "Price", "price", "price", book.Price.Currency)
+	}
+}
+
+func promotionStructLevel(sl validator.StructLevel) {
+	promotion := sl.Current().Interface().(models.Promotion)
+	if !promotion.Valid() {
+		sl.ReportError(promotion.Value, "Value", "value", "promotion", "")
+	}
+}
+
+func thresholdStructLevel(sl validator.StructLevel) {
+	threshold := sl.Current().Interface().(models.StockThreshold)
+	if !threshold.Valid() {
+		sl.ReportError(threshold.BookID, "BookID", "book_id", "book_or_genre", "")
+	}
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/porky256/rest-api/models"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const apiVersionKey = "api_version"
+
+// v1Deprecated and v1Sunset are announced on every v1 response, v1 routes
+// may be removed after v1Sunset.
+var (
+	v1Deprecated = time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	v1Sunset     = time.Date(2027, 4, 18, 0, 0, 0, 0, time.UTC)
+)
+
+// versioned marks the requests of a route group with its API version.
+func versioned(version int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(apiVersionKey, version)
+		c.Next()
+	}
+}
+
+// apiVersion is the API version of the request, handlers called outside a
+// version group speak v1.
+func apiVersion(c *gin.Context) int {
+	if version, ok := c.Get(apiVersionKey); ok {
+		return version.(int)
+	}
+	return 1
+}
+
+// deprecated announces the deprecation (RFC 9745) and sunset (RFC 8594) of
+// v1 and links to the same route in v2.
+func deprecated(c *gin.Context) {
+	c.Header("Deprecation", fmt.Sprintf("@%d", v1Deprecated.Unix()))
+	c.Header("Sunset", v1Sunset.Format(http.TimeFormat))
+	if successor := strings.TrimPrefix(c.Request.URL.Path, "/v1"); successor != c.Request.URL.Path {
+		c.Header("Link", fmt.Sprintf(`</v2%s>; rel="successor-version"`, successor))
+	}
+	c.Next()
+}
+
+// redirectUnversioned sends requests to routes that used to be served at the
+// root to v1.
+func redirectUnversioned(c *gin.Context) {
+	path := c.Request.URL.Path
+	if strings.HasPrefix(path, "/v1/") || strings.HasPrefix(path, "/v2/") || strings.HasPrefix(path, "/docs/") {
+		c.AbortWithStatusJSON(http.StatusNotFound, ErrorMessage{"not found"})
+		return
+	}
+	location := *c.Request.URL
+	location.Path = "/v1" + path
+	c.Redirect(http.StatusPermanentRedirect, location.String())
+}
+
+// bindBook binds the request body in the book representation of the API
+// version.
+func bindBook(c *gin.Context, book *models.Book) error {
+	if apiVersion(c) < 2 {
+		return bindBody(c, book)
+	}
+	var v2 models.BookV2
+	if err := bindBody(c, &v2); err != nil {
+		return err
+	}
+	*book = v2.Book()
+	return nil
+}
+
+// renderBook responds with the book in the representation of the API
+// version, in the format the client accepts.
+func renderBook(c *gin.Context, book models.Book) {
+	if apiVersion(c) < 2 {
+		respond(c, http.StatusOK, "book", book)
+		return
+	}
+	respond(c, http.StatusOK, "book", book.V2())
+}
+
+func renderPricedBook(c *gin.Context, book models.PricedBook) {
+	if apiVersion(c) < 2 {
+		respond(c, http.StatusOK, "book", book)
+		return
+	}
+	respond(c, http.StatusOK, "book", book.V2())
+}
+
+func renderPricedBooks(c *gin.Context, books []models.PricedBook) {
+	if apiVersion(c) < 2 {
+		respond(c, http.StatusOK, "book", books)
+		return
+	}
+	list := make([]models.BookV2, 0, len(books))
+	for _, book := range books {
+		list = append(list, book.V2())
+	}
+	respond(c, http.StatusOK, "book", list)
+}
+
+func renderSearchResults(c *gin.Context, results []models.SearchResult) {
+	if apiVersion(c) < 2 {
+		respond(c, http.StatusOK, "result", results)
+		return
+	}
+	list := make([]models.SearchResultV2, 0, len(results))
+	for _, result := range results {
+		list = append(list, result.V2())
+	}
+	respond(c, http.StatusOK, "result", list)
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIVersions(t *testing.T) {
+	type mockBehavior func(r *MockDatabase)
+	gin.SetMode(gin.ReleaseMode)
+	now := time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)
+	book := models.Book{ID: 1, Name: "Dune", Price: models.MustParseDecimal("9.99"), Currency: "USD", Genre: 3, Amount: 4,
+		Authors: []string{"Frank Herbert"}, CreatedAt: now, UpdatedAt: now}
+	tests := []struct {
+		name                string
+		method              string
+		path                string
+		body                string
+		mockBehavior        mockBehavior
+		expectedStatusCode  int
+		expectedBody        string
+		expectedDeprecation string
+		expectedLink        string
+		expectedLocation    string
+	}{
+		{
+			name:   "v1",
+			method: "GET",
+			path:   "/v1/books/1",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetCatalogModified().Return(time.Time{}, nil)
+				r.EXPECT().GetBookById(1).Return(book, nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{}, nil)
+			},
+			expectedStatusCode:  http.StatusOK,
+			expectedBody:        `{"id":1,"name":"Dune","price":"9.99","currency":"USD","genre":3,"amount":4,"effective_price":"9.99"}`,
+			expectedDeprecation: "@1792281600",
+			expectedLink:        `</v2/books/1>; rel="successor-version"`,
+		},
+		{
+			name:   "v2",
+			method: "GET",
+			path:   "/v2/books/1",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().GetCatalogModified().Return(time.Time{}, nil)
+				r.EXPECT().GetBookById(1).Return(book, nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedBody: `{"id":1,"name":"Dune","authors":["Frank Herbert"],"price":{"amount":"9.99","currency":"USD"},` +
+				`"effective_price":{"amount":"9.99","currency":"USD"},"genre":3,"amount":4,` +
+				`"created_at":"2021-11-20T10:00:00Z","updated_at":"2021-11-20T10:00:00Z"}`,
+		},
+		{
+			name:   "v2 search",
+			method: "GET",
+			path:   "/v2/books/search?q=dune",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().SearchBooks("dune", 0).Return([]models.SearchResult{{Book: book, Rank: 0.5, Snippet: "<b>Dune</b>"}}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedBody: `[{"id":1,"name":"Dune","authors":["Frank Herbert"],"price":{"amount":"9.99","currency":"USD"},"genre":3,"amount":4,` +
+				`"created_at":"2021-11-20T10:00:00Z","updated_at":"2021-11-20T10:00:00Z","rank":0.5,"snippet":"\u003cb\u003eDune\u003c/b\u003e"}]`,
+		},
+		{
+			name:               "v2 price with too many decimal places",
+			method:             "POST",
+			path:               "/v2/books",
+			body:               `{"name":"Dune","price":{"amount":"9.999","currency":"USD"},"genre":3,"amount":4}`,
+			mockBehavior:       func(r *MockDatabase) {},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error":"invalid input"}`,
+		},
+		{
+			name:               "unversioned",
+			method:             "POST",
+			path:               "/books?dry=1",
+			mockBehavior:       func(r *MockDatabase) {},
+			expectedStatusCode: http.StatusPermanentRedirect,
+			expectedLocation:   "/v1/books?dry=1",
+		},
+		{
+			name:               "unknown route",
+			method:             "GET",
+			path:               "/v2/unknown",
+			mockBehavior:       func(r *MockDatabase) {},
+			expectedStatusCode: http.StatusNotFound,
+			expectedBody:       `{"error":"not found"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			db := NewMockDatabase(c)
+			test.mockBehavior(db)
+			rest_api := InitializeHandler(db)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+
+			rest_api.Router.ServeHTTP(w, req)
+
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			if test.expectedBody != "" {
+				assert.Equal(t, test.expectedBody, w.Body.String())
+			}
+			assert.Equal(t, test.expectedDeprecation, w.Header().Get("Deprecation"))
+			assert.Equal(t, test.expectedLink, w.Header().Get("Link"))
+			assert.Equal(t, test.expectedLocation, w.Header().Get("Location"))
+			if test.expectedDeprecation != "" {
+				assert.Equal(t, "Sun, 18 Apr 2027 00:00:00 GMT", w.Header().Get("Sunset"))
+			} else {
+				assert.Empty(t, w.Header().Get("Sunset"))
+			}
+		})
+	}
+}
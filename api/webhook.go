@@ -0,0 +1,176 @@
+package api
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	"github.com/porky256/rest-api/models"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+const defaultDeadLetterLimit = 100
+
+func (handler *Handler) getWebhooks(c *gin.Context) {
+	list, err := handler.DataBase.GetWebhooks()
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorMessage{"internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+func (handler *Handler) getWebhookByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
+		return
+	}
+
+	webhook, err := handler.DataBase.GetWebhookById(id)
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, webhook)
+}
+
+// postWebhook subscribes a URL to book events. When no secret is given one
+// is generated, either way it is returned only in this response.
+func (handler *Handler) postWebhook(c *gin.Context) {
+	var newWebhook models.Webhook
+
+	if err := c.BindJSON(&newWebhook); err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
+		return
+	}
+	if newWebhook.Secret == "" {
+		secret, err := newSecret()
+		if err != nil {
+			log.Println(err.Error())
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorMessage{"internal server error"})
+			return
+		}
+		newWebhook.Secret = secret
+	}
+
+	id, err := handler.DataBase.AddWebhook(newWebhook)
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"id":     id,
+		"secret": newWebhook.Secret})
+}
+
+// updateWebhook changes a webhook, leaving out the secret keeps the current one.
+func (handler *Handler) updateWebhook(c *gin.Context) {
+	var newWebhook models.Webhook
+
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
+		return
+	}
+
+	if err := c.BindJSON(&newWebhook); err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
+		return
+	}
+
+	err = handler.DataBase.UpdateWebhook(id, newWebhook)
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+	webhook, err := handler.DataBase.GetWebhookById(id)
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, webhook)
+}
+
+func (handler *Handler) deleteWebhook(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
+		return
+	}
+
+	err = handler.DataBase.DelWebhook(id)
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+// getDeadLetters responds with the deliveries that failed for good, newest first.
+func (handler *Handler) getDeadLetters(c *gin.Context) {
+	limit := defaultDeadLetterLimit
+	if value := c.Query("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 {
+			log.Println("invalid limit: ", value)
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid filter condition"})
+			return
+		}
+	}
+
+	list, err := handler.DataBase.GetDeadDeliveries(limit)
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorMessage{"internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// retryDeadLetter queues a dead delivery again.
+func (handler *Handler) retryDeadLetter(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+
+	if err != nil {
+		log.Println(err.Error())
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorMessage{"invalid input"})
+		return
+	}
+
+	err = handler.DataBase.RetryDelivery(id)
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+func newSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func webhookError(c *gin.Context, err error) {
+	log.Println(err.Error())
+	switch err {
+	case sql.ErrNoRows:
+		c.AbortWithStatusJSON(http.StatusNotFound, ErrorMessage{"id not found"})
+	default:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorMessage{"internal server error"})
+	}
+}
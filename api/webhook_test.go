@@ -0,0 +1,212 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAPIPostWebhook(t *testing.T) {
+	type mockBehavior func(s *MockDatabase, webhook models.Webhook)
+	gin.SetMode(gin.ReleaseMode)
+	tests := []struct {
+		name                 string
+		inputBody            string
+		inputWebhook         models.Webhook
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:      "OK",
+			inputBody: `{"url":"https://shop.example.com/hook","secret":"0123456789abcdef","events":["book.created"]}`,
+			inputWebhook: models.Webhook{URL: "https://shop.example.com/hook", Secret: "0123456789abcdef",
+				Events: []string{models.EventBookCreated}},
+			mockBehavior: func(r *MockDatabase, webhook models.Webhook) {
+				r.EXPECT().AddWebhook(webhook).Return(1, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{"id":1,"secret":"0123456789abcdef"}`,
+		},
+		{
+			name:                 "Invalid url",
+			inputBody:            `{"url":"not a url","events":["book.created"]}`,
+			mockBehavior:         func(r *MockDatabase, webhook models.Webhook) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"invalid input"}`,
+		},
+		{
+			name:                 "Unknown event",
+			inputBody:            `{"url":"https://shop.example.com/hook","events":["book.sold"]}`,
+			mockBehavior:         func(r *MockDatabase, webhook models.Webhook) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"invalid input"}`,
+		},
+		{
+			name:                 "No events",
+			inputBody:            `{"url":"https://shop.example.com/hook","events":[]}`,
+			mockBehavior:         func(r *MockDatabase, webhook models.Webhook) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"invalid input"}`,
+		},
+		{
+			name:                 "Short secret",
+			inputBody:            `{"url":"https://shop.example.com/hook","secret":"short","events":["book.created"]}`,
+			mockBehavior:         func(r *MockDatabase, webhook models.Webhook) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"invalid input"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			db := NewMockDatabase(c)
+			test.mockBehavior(db, test.inputWebhook)
+			rest_api := Handler{Router: gin.Default(), DataBase: db}
+
+			r := gin.New()
+			r.POST("/webhooks", rest_api.postWebhook)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/webhooks",
+				bytes.NewBufferString(test.inputBody))
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			assert.Equal(t, test.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestAPIPostWebhookGeneratesSecret(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	var stored models.Webhook
+	db := NewMockDatabase(c)
+	db.EXPECT().AddWebhook(gomock.Any()).DoAndReturn(func(webhook models.Webhook) (int, error) {
+		stored = webhook
+		return 1, nil
+	})
+	rest_api := Handler{Router: gin.Default(), DataBase: db}
+
+	r := gin.New()
+	r.POST("/webhooks", rest_api.postWebhook)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/webhooks",
+		bytes.NewBufferString(`{"url":"https://shop.example.com/hook","events":["book.updated"]}`))
+
+	r.ServeHTTP(w, req)
+
+	var response struct {
+		ID     int    `json:"id"`
+		Secret string `json:"secret"`
+	}
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.Secret, 64)
+	assert.Equal(t, stored.Secret, response.Secret)
+}
+
+func TestAPIGetDeadLetters(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	created := time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	db := NewMockDatabase(c)
+	db.EXPECT().GetDeadDeliveries(10).Return([]models.Delivery{{ID: 3, WebhookID: 1, URL: "https://shop.example.com/hook",
+		Secret: "0123456789abcdef", Event: models.Event{ID: 7, Type: models.EventBookDeleted, BookID: 2,
+			Data: json.RawMessage(`{"id":2}`), CreatedAt: created}, Status: models.DeliveryDead, Attempts: 8,
+		NextAttemptAt: created, ResponseStatus: 502, LastError: "webhook responded with 502 Bad Gateway"}}, nil)
+	rest_api := Handler{Router: gin.Default(), DataBase: db}
+
+	r := gin.New()
+	r.GET("/webhooks/dead-letters", rest_api.getDeadLetters)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/webhooks/dead-letters?limit=10", nil)
+
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `[{"id":3,"webhook_id":1,"url":"https://shop.example.com/hook","event":{"id":7,"type":"book.deleted",`+
+		`"book_id":2,"data":{"id":2},"created_at":"2021-11-20T10:00:00Z"},"status":"dead","attempts":8,`+
+		`"next_attempt_at":"2021-11-20T10:00:00Z","response_status":502,"last_error":"webhook responded with 502 Bad Gateway"}]`,
+		w.Body.String())
+}
+
+func TestAPIRetryDeadLetter(t *testing.T) {
+	type mockBehavior func(s *MockDatabase)
+	gin.SetMode(gin.ReleaseMode)
+	tests := []struct {
+		name                 string
+		inputId              string
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:    "OK",
+			inputId: "3",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().RetryDelivery(int64(3)).Return(nil)
+			},
+			expectedStatusCode: http.StatusNoContent,
+		},
+		{
+			name:    "Not dead",
+			inputId: "3",
+			mockBehavior: func(r *MockDatabase) {
+				r.EXPECT().RetryDelivery(int64(3)).Return(sql.ErrNoRows)
+			},
+			expectedStatusCode:   http.StatusNotFound,
+			expectedResponseBody: `{"error":"id not found"}`,
+		},
+		{
+			name:                 "Invalid id",
+			inputId:              "a",
+			mockBehavior:         func(r *MockDatabase) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"error":"invalid input"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			db := NewMockDatabase(c)
+			test.mockBehavior(db)
+			rest_api := Handler{Router: gin.Default(), DataBase: db}
+
+			r := gin.New()
+			r.POST("/webhooks/dead-letters/:id/retry", rest_api.retryDeadLetter)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/webhooks/dead-letters/"+test.inputId+"/retry", nil)
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			assert.Equal(t, test.expectedResponseBody, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/porky256/rest-api/events"
+	"log"
+	"time"
+)
+
+const (
+	wsActionSubscribe   = "subscribe"
+	wsActionUnsubscribe = "unsubscribe"
+	wsBufferSize        = 256
+	wsMaxMessageSize    = 4096
+	wsWriteWait         = 10 * time.Second
+)
+
+var (
+	// wsPingInterval must be shorter than wsPongWait so a healthy client
+	// always answers before its read deadline passes.
+	wsPingInterval = 30 * time.Second
+	wsPongWait     = 60 * time.Second
+
+	upgrader = websocket.Upgrader{ReadBufferSize: 1024, WriteBufferSize: 1024}
+)
+
+// wsRequest is a message sent by WebSocket clients to change their subscriptions.
+type wsRequest struct {
+	Action string `json:"action"`
+	Books  []int  `json:"books"`
+	Genres []int  `json:"genres"`
+}
+
+// wsReply confirms the subscriptions after every request, or reports an error.
+type wsReply struct {
+	Type   string `json:"type"`
+	Books  []int  `json:"books"`
+	Genres []int  `json:"genres"`
+	Error  string `json:"error,omitempty"`
+}
+
+// streamWebSocket upgrades the connection and sends the events of the books
+// and genres the client subscribes to. A client that can't keep up is
+// disconnected with a try-again-later close code, an idle one is pinged. All
+// clients are disconnected with a going-away close code when the event bus
+// is closed.
+func (handler *Handler) streamWebSocket(c *gin.Context) {
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Println(err.Error())
+		return
+	}
+	defer conn.Close()
+
+	subscriber := handler.Events.Hub.Register(wsBufferSize)
+	defer handler.Events.Hub.Unregister(subscriber)
+
+	replies := make(chan wsReply, 1)
+	done := make(chan struct{})
+	quit := make(chan struct{})
+	defer close(quit)
+	go func() {
+		defer close(done)
+		conn.SetReadLimit(wsMaxMessageSize)
+		_ = conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		})
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			reply := handleWSRequest(subscriber, message)
+			select {
+			case replies <- reply:
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+	for {
+		var err error
+		select {
+		case <-done:
+			return
+		case <-subscriber.Lagged():
+			message := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "client too slow")
+			_ = conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(wsWriteWait))
+			return
+		case <-subscriber.Closed():
+			message := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+			_ = conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(wsWriteWait))
+			return
+		case reply := <-replies:
+			_ = conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			err = conn.WriteJSON(reply)
+		case event := <-subscriber.Events():
+			_ = conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			err = conn.WriteJSON(event)
+		case <-ping.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait))
+		}
+		if err != nil {
+			log.Println(err.Error())
+			return
+		}
+	}
+}
+
+func handleWSRequest(subscriber *events.Subscriber, message []byte) wsReply {
+	var request wsRequest
+	if err := json.Unmarshal(message, &request); err != nil {
+		return wsReply{Type: "error", Error: "invalid message"}
+	}
+	reply := wsReply{Type: "subscriptions"}
+	switch request.Action {
+	case wsActionSubscribe:
+		subscriber.Subscribe(request.Books, request.Genres)
+	case wsActionUnsubscribe:
+		subscriber.Unsubscribe(request.Books, request.Genres)
+	default:
+		reply = wsReply{Type: "error", Error: "unknown action"}
+	}
+	reply.Books, reply.Genres = subscriber.Subscriptions()
+	return reply
+}
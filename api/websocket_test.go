@@ -0,0 +1,167 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/porky256/rest-api/events"
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newWebSocketServer(t *testing.T) (*Handler, *websocket.Conn, func()) {
+	gin.SetMode(gin.ReleaseMode)
+	rest_api := &Handler{Router: gin.Default(), Events: events.NewBus(10)}
+	r := gin.New()
+	r.GET("/ws", rest_api.streamWebSocket)
+	server := httptest.NewServer(r)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if err != nil {
+		server.Close()
+		t.Fatalf("Error in dialing: %s", err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return rest_api, conn, func() {
+		conn.Close()
+		server.Close()
+	}
+}
+
+func TestAPIWebSocketSubscriptions(t *testing.T) {
+	rest_api, conn, closeAll := newWebSocketServer(t)
+	defer closeAll()
+
+	tests := []struct {
+		name    string
+		request string
+		reply   wsReply
+	}{
+		{
+			name:    "subscribe",
+			request: `{"action":"subscribe","books":[2,1],"genres":[3]}`,
+			reply:   wsReply{Type: "subscriptions", Books: []int{1, 2}, Genres: []int{3}},
+		},
+		{
+			name:    "unsubscribe",
+			request: `{"action":"unsubscribe","books":[2]}`,
+			reply:   wsReply{Type: "subscriptions", Books: []int{1}, Genres: []int{3}},
+		},
+		{
+			name:    "unknown action",
+			request: `{"action":"watch","books":[7]}`,
+			reply:   wsReply{Type: "error", Books: []int{1}, Genres: []int{3}, Error: "unknown action"},
+		},
+		{
+			name:    "invalid message",
+			request: `books`,
+			reply:   wsReply{Type: "error", Error: "invalid message"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(test.request)))
+			var reply wsReply
+			assert.NoError(t, conn.ReadJSON(&reply))
+			assert.Equal(t, test.reply, reply)
+		})
+	}
+
+	rest_api.publish(models.EventBookUpdated, 2, models.Book{ID: 2, Genre: 1})
+	rest_api.publish(models.EventBookUpdated, 1, models.Book{ID: 1, Genre: 1})
+	rest_api.publish(models.EventBookCreated, 9, models.Book{ID: 9, Genre: 3})
+
+	var event models.Event
+	assert.NoError(t, conn.ReadJSON(&event))
+	assert.Equal(t, 1, event.BookID)
+	assert.Equal(t, models.EventBookUpdated, event.Type)
+	assert.NoError(t, conn.ReadJSON(&event))
+	assert.Equal(t, 9, event.BookID)
+	assert.Equal(t, models.EventBookCreated, event.Type)
+}
+
+func TestAPIWebSocketHeartbeat(t *testing.T) {
+	interval := wsPingInterval
+	wsPingInterval = 10 * time.Millisecond
+	defer func() { wsPingInterval = interval }()
+
+	_, conn, closeAll := newWebSocketServer(t)
+	defer closeAll()
+
+	pinged := make(chan struct{}, 1)
+	conn.SetPingHandler(func(string) error {
+		select {
+		case pinged <- struct{}{}:
+		default:
+		}
+		return nil
+	})
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-pinged:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no ping received")
+	}
+}
+
+func TestAPIWebSocketSlowClient(t *testing.T) {
+	rest_api, conn, closeAll := newWebSocketServer(t)
+	defer closeAll()
+
+	assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"action":"subscribe","books":[1]}`)))
+	var reply wsReply
+	assert.NoError(t, conn.ReadJSON(&reply))
+
+	// Nothing is read while the events pile up, the payload makes sure
+	// they can't all hide in the socket buffers.
+	book := models.Book{ID: 1, Name: strings.Repeat("x", 16<<10)}
+	for i := 0; i < 1000; i++ {
+		rest_api.publish(models.EventBookUpdated, 1, book)
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	var err error
+	for err == nil {
+		_, _, err = conn.ReadMessage()
+	}
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseTryAgainLater), err.Error())
+}
+
+func TestAPIWebSocketShutdown(t *testing.T) {
+	rest_api, conn, closeAll := newWebSocketServer(t)
+	defer closeAll()
+
+	assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"action":"subscribe","books":[1]}`)))
+	var reply wsReply
+	assert.NoError(t, conn.ReadJSON(&reply))
+
+	rest_api.Events.Close()
+
+	_, _, err := conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "unexpected error %v", err)
+}
+
+func TestAPIWebSocketReset(t *testing.T) {
+	rest_api, conn, closeAll := newWebSocketServer(t)
+	defer closeAll()
+
+	assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"action":"subscribe","books":[1]}`)))
+	var reply wsReply
+	assert.NoError(t, conn.ReadJSON(&reply))
+
+	rest_api.Events.Reset()
+
+	var event models.Event
+	assert.NoError(t, conn.ReadJSON(&event))
+	assert.Equal(t, models.EventReset, event.Type)
+}
@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Cache keeps values under string keys for a limited time. Implementations
+// must be safe for concurrent use.
+type Cache interface {
+	Get(key string) (interface{}, bool)
+	Set(key string, value interface{}, ttl time.Duration)
+	Clear()
+}
+
+type entry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+// LRU is an in-memory Cache holding at most capacity entries. When it is
+// full the least recently used entry is evicted.
+type LRU struct {
+	mu       sync.Mutex
+	capacity int
+	items    map[string]*list.Element
+	order    *list.List
+	now      func() time.Time
+}
+
+func NewLRU(capacity int) *LRU {
+	return &LRU{
+		capacity: capacity,
+		items:    map[string]*list.Element{},
+		order:    list.New(),
+		now:      time.Now,
+	}
+}
+
+func (c *LRU) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	e := element.Value.(*entry)
+	if !c.now().Before(e.expires) {
+		c.remove(element)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return e.value, true
+}
+
+// Set stores the value for ttl, a non-positive ttl isn't stored at all.
+func (c *LRU) Set(key string, value interface{}, ttl time.Duration) {
+	if ttl <= 0 || c.capacity <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires := c.now().Add(ttl)
+	if element, ok := c.items[key]; ok {
+		e := element.Value.(*entry)
+		e.value, e.expires = value, expires
+		c.order.MoveToFront(element)
+		return
+	}
+	c.items[key] = c.order.PushFront(&entry{key: key, value: value, expires: expires})
+	for c.order.Len() > c.capacity {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *LRU) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items = map[string]*list.Element{}
+	c.order.Init()
+}
+
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *LRU) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.items, element.Value.(*entry).key)
+}
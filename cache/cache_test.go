@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLRU(t *testing.T) {
+	now := time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)
+	lru := NewLRU(2)
+	lru.now = func() time.Time { return now }
+
+	lru.Set("a", 1, time.Minute)
+	lru.Set("b", 2, time.Minute)
+	value, ok := lru.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, value)
+
+	lru.Set("c", 3, time.Minute)
+	_, ok = lru.Get("b")
+	assert.False(t, ok, "least recently used entry should be evicted")
+	assert.Equal(t, 2, lru.Len())
+
+	lru.Set("a", 4, 2*time.Minute)
+	now = now.Add(time.Minute)
+	_, ok = lru.Get("c")
+	assert.False(t, ok, "expired entry should be gone")
+	value, ok = lru.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 4, value)
+	assert.Equal(t, 1, lru.Len())
+
+	lru.Set("d", 5, 0)
+	_, ok = lru.Get("d")
+	assert.False(t, ok)
+
+	lru.Clear()
+	_, ok = lru.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, lru.Len())
+}
@@ -0,0 +1,287 @@
+// Package client talks to the v2 book API over HTTP.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/porky256/rest-api/models"
+	"github.com/porky256/rest-api/webhook"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultTimeout     = 10 * time.Second
+	DefaultMaxAttempts = 3
+
+	actorHeader          = "X-Actor"
+	idempotencyKeyHeader = "Idempotency-Key"
+)
+
+var (
+	// ErrInvalidInput is matched by errors.Is for rejected books and filters.
+	ErrInvalidInput = errors.New("invalid input")
+	ErrNotFound     = errors.New("not found")
+	ErrNotUnique    = errors.New("book name is not unique")
+	// ErrInsufficientStock is matched by errors.Is when a stock change would
+	// leave less than zero copies.
+	ErrInsufficientStock = errors.New("insufficient stock")
+	// ErrUnavailable is matched by errors.Is for server errors worth trying
+	// again later.
+	ErrUnavailable = errors.New("service unavailable")
+)
+
+// Client calls the API served at BaseURL, e.g. http://localhost:8080.
+// Requests are retried with backoff after connection failures and on 429,
+// 502, 503 and 504 responses, and on 409 while an earlier attempt with the
+// same idempotency key is still handled, up to MaxAttempts times. Books are created and
+// stock is changed with an idempotency key, so retries don't apply them twice.
+// A retried delete may end with ErrNotFound.
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	// Actor is recorded in the audit log of the changes made by the client.
+	Actor       string
+	MaxAttempts int
+	// Backoff returns how long to wait before the next attempt after the given number of failed ones.
+	Backoff func(attempts int) time.Duration
+}
+
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:     strings.TrimRight(baseURL, "/"),
+		HTTPClient:  &http.Client{Timeout: DefaultTimeout},
+		MaxAttempts: DefaultMaxAttempts,
+		Backoff:     webhook.ExponentialBackoff(100*time.Millisecond, 2*time.Second),
+	}
+}
+
+// BookFilter narrows ListBooks down, zero fields don't filter.
+type BookFilter struct {
+	Name  string
+	Genre int
+}
+
+// Error is an error response of the API. Use errors.Is with the Err
+// variables to tell the kinds of errors apart.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (%d %s)", e.Message, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrInvalidInput:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrNotUnique:
+		// The API answers 500 for duplicate names.
+		return e.Message == notUniqueMessage
+	case ErrInsufficientStock:
+		return e.StatusCode == http.StatusConflict && e.Message == insufficientStockMessage
+	case ErrUnavailable:
+		return retryable(e.StatusCode)
+	}
+	return false
+}
+
+// bookInput is the part of a book clients can write.
+type bookInput struct {
+	Name    string       `json:"name"`
+	Authors []string     `json:"authors"`
+	Price   models.Money `json:"price"`
+	Genre   int          `json:"genre"`
+	Amount  int          `json:"amount"`
+}
+
+func newBookInput(book models.BookV2) bookInput {
+	return bookInput{Name: book.Name, Authors: book.Authors, Price: book.Price, Genre: book.Genre, Amount: book.Amount}
+}
+
+const (
+	notUniqueMessage         = "input book name is not unique"
+	insufficientStockMessage = "insufficient stock"
+	inProgressMessage        = "request with the same idempotency key in progress"
+)
+
+// errorMessage is the body of error responses.
+type errorMessage struct {
+	Message string `json:"error"`
+}
+
+// ListBooks returns the books matching the filter, newest first, with their
+// effective prices.
+func (c *Client) ListBooks(ctx context.Context, filter BookFilter) ([]models.BookV2, error) {
+	query := url.Values{}
+	if filter.Name != "" {
+		query.Set("name", filter.Name)
+	}
+	if filter.Genre != 0 {
+		query.Set("genre", strconv.Itoa(filter.Genre))
+	}
+	path := "/books"
+	if len(query) != 0 {
+		path += "?" + query.Encode()
+	}
+	var books []models.BookV2
+	if err := c.do(ctx, http.MethodGet, path, nil, &books); err != nil {
+		return nil, err
+	}
+	return books, nil
+}
+
+func (c *Client) GetBook(ctx context.Context, id int) (models.BookV2, error) {
+	var book models.BookV2
+	err := c.do(ctx, http.MethodGet, "/books/"+strconv.Itoa(id), nil, &book)
+	return book, err
+}
+
+// CreateBook adds the book and returns its ID.
+func (c *Client) CreateBook(ctx context.Context, book models.BookV2) (int, error) {
+	var created struct {
+		ID int `json:"id"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/books", newBookInput(book), &created); err != nil {
+		return 0, err
+	}
+	return created.ID, nil
+}
+
+// UpdateBook replaces the book with the given ID and returns it as stored.
+// Leaving the authors nil keeps the current ones.
+func (c *Client) UpdateBook(ctx context.Context, id int, book models.BookV2) (models.BookV2, error) {
+	var updated models.BookV2
+	err := c.do(ctx, http.MethodPut, "/books/"+strconv.Itoa(id), newBookInput(book), &updated)
+	return updated, err
+}
+
+// AdjustStock adds delta copies of the book to the stock, negative to remove
+// them, and returns the book as stored. Concurrent changes add up.
+func (c *Client) AdjustStock(ctx context.Context, id, delta int) (models.BookV2, error) {
+	var book models.BookV2
+	err := c.do(ctx, http.MethodPost, "/books/"+strconv.Itoa(id)+"/stock", models.StockChange{Delta: delta}, &book)
+	return book, err
+}
+
+func (c *Client) DeleteBook(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodDelete, "/books/"+strconv.Itoa(id), nil, nil)
+}
+
+// do sends a request to a v2 route with body encoded as JSON and decodes the
+// response into out.
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	attempts := 1
+	if c.MaxAttempts > 1 {
+		attempts = c.MaxAttempts
+	}
+	// Retries of a POST carry the key of the first attempt.
+	var key string
+	if method == http.MethodPost {
+		key = newIdempotencyKey()
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := c.send(ctx, method, path, key, data)
+		if err == nil && resp.StatusCode < http.StatusBadRequest {
+			defer resp.Body.Close()
+			if out == nil || resp.StatusCode == http.StatusNoContent {
+				return nil
+			}
+			return json.NewDecoder(resp.Body).Decode(out)
+		}
+		if err == nil {
+			err = decodeError(resp)
+			resp.Body.Close()
+			if !retryable(resp.StatusCode) && !(key != "" && inProgress(err)) {
+				return err
+			}
+		}
+		if attempt >= attempts || ctx.Err() != nil {
+			return err
+		}
+
+		timer := time.NewTimer(c.Backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, path, key string, data []byte) (*http.Response, error) {
+	var body io.Reader
+	if data != nil {
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+"/v2"+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Actor != "" {
+		req.Header.Set(actorHeader, c.Actor)
+	}
+	if key != "" {
+		req.Header.Set(idempotencyKeyHeader, key)
+	}
+	return c.HTTPClient.Do(req)
+}
+
+func newIdempotencyKey() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// retryable tells if a response with the status code may succeed when
+// repeated.
+func retryable(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// inProgress tells if a request was refused because another one with its
+// idempotency key is still handled. A retry gets the response to that one.
+func inProgress(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.StatusCode == http.StatusConflict && e.Message == inProgressMessage
+}
+
+func decodeError(resp *http.Response) error {
+	var message errorMessage
+	if err := json.NewDecoder(resp.Body).Decode(&message); err != nil || message.Message == "" {
+		message.Message = strings.ToLower(http.StatusText(resp.StatusCode))
+	}
+	return &Error{StatusCode: resp.StatusCode, Message: message.Message}
+}
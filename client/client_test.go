@@ -0,0 +1,285 @@
+package client
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/porky256/rest-api/api"
+	"github.com/porky256/rest-api/db"
+	"github.com/porky256/rest-api/internal/booktest"
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestClient serves the API over the mock database, answering the first
+// failures requests with 503 Service Unavailable. It returns the client and
+// the number of requests received.
+func newTestClient(t *testing.T, db *api.MockDatabase, failures int) (*Client, *int) {
+	router := api.InitializeHandler(db).Router
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		router.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	client := New(server.URL)
+	client.Actor = "tester"
+	client.Backoff = func(int) time.Duration { return time.Millisecond }
+	return client, &requests
+}
+
+// actor matches the audit info of changes made by the actor.
+type actor string
+
+func (a actor) Matches(x interface{}) bool {
+	info, ok := x.(models.AuditInfo)
+	return ok && info.Actor == string(a)
+}
+
+func (a actor) String() string {
+	return "made by " + string(a)
+}
+
+func TestClient(t *testing.T) {
+	type mockBehavior func(r *api.MockDatabase)
+	gin.SetMode(gin.ReleaseMode)
+	priced := booktest.Book(1).V2()
+	priced.EffectivePrice = &priced.Price
+	input := booktest.Book(0).V2()
+	added := booktest.Book(0)
+	added.CreatedAt, added.UpdatedAt = time.Time{}, time.Time{}
+	info := actor("tester")
+	tests := []struct {
+		name             string
+		call             func(client *Client, ctx context.Context) (interface{}, error)
+		failures         int
+		mockBehavior     mockBehavior
+		expected         interface{}
+		expectedError    error
+		expectedRequests int
+	}{
+		{
+			name: "list",
+			call: func(client *Client, ctx context.Context) (interface{}, error) {
+				return client.ListBooks(ctx, BookFilter{Name: "Dune", Genre: 3})
+			},
+			mockBehavior: func(r *api.MockDatabase) {
+				r.EXPECT().GetAllBooks(map[string][]string{"name": {"Dune"}, "genre": {"3"}}).Return([]models.Book{booktest.Book(1)}, nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{}, nil)
+			},
+			expected:         []models.BookV2{priced},
+			expectedRequests: 1,
+		},
+		{
+			name: "list with invalid filter",
+			call: func(client *Client, ctx context.Context) (interface{}, error) {
+				return client.ListBooks(ctx, BookFilter{Genre: 4})
+			},
+			mockBehavior:     func(r *api.MockDatabase) {},
+			expectedError:    ErrInvalidInput,
+			expectedRequests: 1,
+		},
+		{
+			name: "get after retries",
+			call: func(client *Client, ctx context.Context) (interface{}, error) {
+				return client.GetBook(ctx, 1)
+			},
+			failures: 2,
+			mockBehavior: func(r *api.MockDatabase) {
+				r.EXPECT().GetBookById(1).Return(booktest.Book(1), nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{}, nil)
+			},
+			expected:         priced,
+			expectedRequests: 3,
+		},
+		{
+			name: "get gives up",
+			call: func(client *Client, ctx context.Context) (interface{}, error) {
+				return client.GetBook(ctx, 1)
+			},
+			failures:         3,
+			mockBehavior:     func(r *api.MockDatabase) {},
+			expectedError:    ErrUnavailable,
+			expectedRequests: 3,
+		},
+		{
+			name: "get unknown",
+			call: func(client *Client, ctx context.Context) (interface{}, error) {
+				return client.GetBook(ctx, 2)
+			},
+			mockBehavior: func(r *api.MockDatabase) {
+				r.EXPECT().GetBookById(2).Return(models.Book{}, sql.ErrNoRows)
+			},
+			expectedError:    ErrNotFound,
+			expectedRequests: 1,
+		},
+		{
+			name: "create",
+			call: func(client *Client, ctx context.Context) (interface{}, error) {
+				return client.CreateBook(ctx, input)
+			},
+			mockBehavior: func(r *api.MockDatabase) {
+				r.EXPECT().ClaimIdempotencyKey(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.IdempotentResponse{}, true, nil)
+				r.EXPECT().AddBook(added, info).Return(1, nil)
+				r.EXPECT().SaveIdempotentResponse(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			expected:         1,
+			expectedRequests: 1,
+		},
+		{
+			name: "create after retry",
+			call: func(client *Client, ctx context.Context) (interface{}, error) {
+				return client.CreateBook(ctx, input)
+			},
+			failures: 1,
+			mockBehavior: func(r *api.MockDatabase) {
+				r.EXPECT().ClaimIdempotencyKey(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.IdempotentResponse{}, true, nil)
+				r.EXPECT().AddBook(added, info).Return(1, nil)
+				r.EXPECT().SaveIdempotentResponse(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			expected:         1,
+			expectedRequests: 2,
+		},
+		{
+			name: "create while in progress",
+			call: func(client *Client, ctx context.Context) (interface{}, error) {
+				return client.CreateBook(ctx, input)
+			},
+			mockBehavior: func(r *api.MockDatabase) {
+				r.EXPECT().ClaimIdempotencyKey(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+					func(key, hash string, ttl time.Duration) (models.IdempotentResponse, bool, error) {
+						return models.IdempotentResponse{RequestHash: hash}, false, nil
+					})
+				r.EXPECT().ClaimIdempotencyKey(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.IdempotentResponse{}, true, nil)
+				r.EXPECT().AddBook(added, info).Return(1, nil)
+				r.EXPECT().SaveIdempotentResponse(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			expected:         1,
+			expectedRequests: 2,
+		},
+		{
+			name: "create duplicate",
+			call: func(client *Client, ctx context.Context) (interface{}, error) {
+				return client.CreateBook(ctx, input)
+			},
+			mockBehavior: func(r *api.MockDatabase) {
+				r.EXPECT().ClaimIdempotencyKey(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.IdempotentResponse{}, true, nil)
+				r.EXPECT().AddBook(added, info).Return(0, errors.New(`pq: duplicate key value violates unique constraint "books_name_key"`))
+				r.EXPECT().ReleaseIdempotencyKey(gomock.Any()).Return(nil)
+			},
+			expectedError:    ErrNotUnique,
+			expectedRequests: 1,
+		},
+		{
+			name: "create invalid",
+			call: func(client *Client, ctx context.Context) (interface{}, error) {
+				invalid := input
+				invalid.Price.Amount = models.MustParseDecimal("9.999")
+				return client.CreateBook(ctx, invalid)
+			},
+			mockBehavior: func(r *api.MockDatabase) {
+				r.EXPECT().ClaimIdempotencyKey(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.IdempotentResponse{}, true, nil)
+				r.EXPECT().SaveIdempotentResponse(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			expectedError:    ErrInvalidInput,
+			expectedRequests: 1,
+		},
+		{
+			name: "update after retry",
+			call: func(client *Client, ctx context.Context) (interface{}, error) {
+				return client.UpdateBook(ctx, 1, input)
+			},
+			failures: 1,
+			mockBehavior: func(r *api.MockDatabase) {
+				r.EXPECT().UpdateBook(1, added, info).Return(nil)
+				r.EXPECT().GetBookById(1).Return(booktest.Book(1), nil)
+			},
+			expected:         booktest.Book(1).V2(),
+			expectedRequests: 2,
+		},
+		{
+			name: "adjust stock",
+			call: func(client *Client, ctx context.Context) (interface{}, error) {
+				return client.AdjustStock(ctx, 1, -3)
+			},
+			mockBehavior: func(r *api.MockDatabase) {
+				adjusted := booktest.Book(1)
+				adjusted.Amount = 1
+				r.EXPECT().ClaimIdempotencyKey(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.IdempotentResponse{}, true, nil)
+				r.EXPECT().AdjustStock(1, -3, info).Return(adjusted, nil)
+				r.EXPECT().SaveIdempotentResponse(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			expected: func() models.BookV2 {
+				adjusted := booktest.Book(1).V2()
+				adjusted.Amount = 1
+				return adjusted
+			}(),
+			expectedRequests: 1,
+		},
+		{
+			name: "adjust stock below zero",
+			call: func(client *Client, ctx context.Context) (interface{}, error) {
+				return client.AdjustStock(ctx, 1, -5)
+			},
+			mockBehavior: func(r *api.MockDatabase) {
+				r.EXPECT().ClaimIdempotencyKey(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.IdempotentResponse{}, true, nil)
+				r.EXPECT().AdjustStock(1, -5, info).Return(models.Book{}, db.ErrInsufficientStock)
+				r.EXPECT().SaveIdempotentResponse(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			expectedError:    ErrInsufficientStock,
+			expectedRequests: 1,
+		},
+		{
+			name: "delete",
+			call: func(client *Client, ctx context.Context) (interface{}, error) {
+				return nil, client.DeleteBook(ctx, 1)
+			},
+			mockBehavior: func(r *api.MockDatabase) {
+				r.EXPECT().GetBookById(1).Return(booktest.Book(1), nil)
+				r.EXPECT().DelBook(1, info).Return(nil)
+			},
+			expectedRequests: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			db := api.NewMockDatabase(c)
+			db.EXPECT().GetCatalogModified().Return(booktest.Created, nil).AnyTimes()
+			test.mockBehavior(db)
+			client, requests := newTestClient(t, db, test.failures)
+
+			result, err := test.call(client, context.Background())
+			assert.Equal(t, test.expectedRequests, *requests)
+			if test.expectedError != nil {
+				assert.True(t, errors.Is(err, test.expectedError), "unexpected error %v", err)
+				return
+			}
+			assert.NoError(t, err)
+			if test.expected != nil {
+				assert.Equal(t, test.expected, result)
+			}
+		})
+	}
+}
+
+func TestErrorIs(t *testing.T) {
+	err := error(&Error{StatusCode: http.StatusInternalServerError, Message: notUniqueMessage})
+	assert.True(t, errors.Is(err, ErrNotUnique))
+	assert.False(t, errors.Is(err, ErrUnavailable))
+	assert.EqualError(t, err, "input book name is not unique (500 Internal Server Error)")
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/porky256/rest-api/client"
+	"github.com/porky256/rest-api/models"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// csvHeader are the columns of CSV files, authors are separated by
+// semicolons.
+var csvHeader = []string{"id", "name", "authors", "price", "currency", "genre", "amount"}
+
+func (c *cli) importBooks(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	file, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var books []models.BookV2
+	if isCSV(args[0]) {
+		books, err = readCSV(file)
+	} else {
+		err = json.NewDecoder(file).Decode(&books)
+	}
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", args[0], err)
+	}
+
+	created, updated := 0, 0
+	for i, book := range books {
+		if book.Price.Currency == "" {
+			book.Price.Currency = models.DefaultCurrency
+		}
+		if book.ID != 0 {
+			_, err = c.client.UpdateBook(ctx, book.ID, book)
+		} else {
+			_, err = c.client.CreateBook(ctx, book)
+		}
+		if err != nil {
+			// The books before were imported, the rest can be imported again
+			// after fixing the file.
+			return fmt.Errorf("book %d of %s: %w", i+1, args[0], err)
+		}
+		if book.ID != 0 {
+			updated++
+		} else {
+			created++
+		}
+	}
+	if c.output == "json" {
+		return writeJSON(c.stdout, map[string]int{"created": created, "updated": updated})
+	}
+	_, err = fmt.Fprintf(c.stdout, "created %d, updated %d books\n", created, updated)
+	return err
+}
+
+func (c *cli) exportBooks(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	books, err := c.client.ListBooks(ctx, client.BookFilter{})
+	if err != nil {
+		return err
+	}
+	if args[0] == "-" {
+		return writeJSON(c.stdout, books)
+	}
+
+	file, err := os.Create(args[0])
+	if err != nil {
+		return err
+	}
+	if isCSV(args[0]) {
+		err = writeCSV(file, books)
+	} else {
+		err = writeJSON(file, books)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if c.output == "table" {
+		_, err = fmt.Fprintf(c.stdout, "exported %d books\n", len(books))
+	}
+	return err
+}
+
+func isCSV(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".csv")
+}
+
+func writeCSV(w io.Writer, books []models.BookV2) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, book := range books {
+		record := []string{
+			strconv.Itoa(book.ID),
+			book.Name,
+			strings.Join(book.Authors, ";"),
+			book.Price.Amount.String(),
+			book.Price.Currency,
+			strconv.Itoa(book.Genre),
+			strconv.Itoa(book.Amount),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// readCSV reads books from CSV with a header naming the columns of
+// csvHeader, in any order. Only name, price and genre are required.
+func readCSV(r io.Reader) ([]models.BookV2, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"name", "price", "genre"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing column %s", name)
+		}
+	}
+
+	books := make([]models.BookV2, 0, len(records)-1)
+	for i, record := range records[1:] {
+		field := func(name string) string {
+			if column, ok := columns[name]; ok {
+				return strings.TrimSpace(record[column])
+			}
+			return ""
+		}
+		book := models.BookV2{Name: field("name"), Price: models.Money{Currency: field("currency")}}
+		if authors := field("authors"); authors != "" {
+			for _, author := range strings.Split(authors, ";") {
+				book.Authors = append(book.Authors, strings.TrimSpace(author))
+			}
+		}
+		if book.Price.Amount, err = models.ParseDecimal(field("price")); err != nil {
+			return nil, fmt.Errorf("line %d: invalid price", i+2)
+		}
+		for name, value := range map[string]*int{"id": &book.ID, "genre": &book.Genre, "amount": &book.Amount} {
+			if text := field(name); text != "" {
+				if *value, err = strconv.Atoi(text); err != nil {
+					return nil, fmt.Errorf("line %d: invalid %s", i+2, name)
+				}
+			}
+		}
+		books = append(books, book)
+	}
+	return books, nil
+}
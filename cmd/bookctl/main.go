@@ -0,0 +1,318 @@
+// Command bookctl manages the books of a running API server.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/porky256/rest-api/client"
+	"github.com/porky256/rest-api/models"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+const usage = `usage: bookctl [flags] <command> [arguments]
+
+commands:
+  list [-name NAME] [-genre GENRE]        list books, newest first
+  get ID                                  show a book
+  create -name NAME -price PRICE -genre GENRE [-author NAME]... [-currency CODE] [-amount N]
+  update ID [-name NAME] [-price PRICE] ...
+                                          change the given fields of a book
+  delete ID                               delete a book
+  stock ID DELTA                          add DELTA copies to the stock, negative to remove
+  import FILE                             create the books of a .json or .csv file,
+                                          books with an id are updated
+  export FILE                             write all books to a .json or .csv file, - for stdout
+
+flags:
+`
+
+var errUsage = errors.New("invalid arguments, see bookctl -h")
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("bookctl: ")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := run(ctx, os.Args[1:], os.Stdout); err != nil && err != flag.ErrHelp {
+		log.Println(err)
+		os.Exit(1)
+	}
+}
+
+// cli holds the global flags of a run.
+type cli struct {
+	client *client.Client
+	output string
+	stdout io.Writer
+}
+
+func run(ctx context.Context, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("bookctl", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+	server := flags.String("server", env("BOOKCTL_SERVER", "http://localhost:8080"), "URL of the API server, $BOOKCTL_SERVER")
+	actor := flags.String("actor", env("BOOKCTL_ACTOR", os.Getenv("USER")), "name recorded in the audit log, $BOOKCTL_ACTOR")
+	output := flags.String("o", "table", "output format, table or json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 || (*output != "table" && *output != "json") {
+		return errUsage
+	}
+
+	c := &cli{client: client.New(*server), output: *output, stdout: stdout}
+	c.client.Actor = *actor
+	command, args := flags.Arg(0), flags.Args()[1:]
+	switch command {
+	case "list":
+		return c.list(ctx, args)
+	case "get":
+		return c.get(ctx, args)
+	case "create":
+		return c.create(ctx, args)
+	case "update":
+		return c.update(ctx, args)
+	case "delete":
+		return c.delete(ctx, args)
+	case "stock":
+		return c.stock(ctx, args)
+	case "import":
+		return c.importBooks(ctx, args)
+	case "export":
+		return c.exportBooks(ctx, args)
+	}
+	return fmt.Errorf("unknown command %q, see bookctl -h", command)
+}
+
+func (c *cli) list(ctx context.Context, args []string) error {
+	var filter client.BookFilter
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	flags.StringVar(&filter.Name, "name", "", "only the book with this name")
+	flags.IntVar(&filter.Genre, "genre", 0, "only books of this genre")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	books, err := c.client.ListBooks(ctx, filter)
+	if err != nil {
+		return err
+	}
+	return c.print(books)
+}
+
+func (c *cli) get(ctx context.Context, args []string) error {
+	id, err := bookID(args, 1)
+	if err != nil {
+		return err
+	}
+	book, err := c.client.GetBook(ctx, id)
+	if err != nil {
+		return err
+	}
+	return c.print([]models.BookV2{book})
+}
+
+func (c *cli) create(ctx context.Context, args []string) error {
+	book := models.BookV2{Price: models.Money{Currency: models.DefaultCurrency}}
+	flags := bookFlags("create", &book)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	set := given(flags)
+	if flags.NArg() != 0 || !set["name"] || !set["price"] || !set["genre"] {
+		return errUsage
+	}
+	id, err := c.client.CreateBook(ctx, book)
+	if err != nil {
+		return err
+	}
+	if c.output == "json" {
+		return writeJSON(c.stdout, map[string]int{"id": id})
+	}
+	_, err = fmt.Fprintf(c.stdout, "created book %d\n", id)
+	return err
+}
+
+func (c *cli) update(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	id, err := bookID(args[:1], 1)
+	if err != nil {
+		return err
+	}
+	book, err := c.client.GetBook(ctx, id)
+	if err != nil {
+		return err
+	}
+	flags := bookFlags("update", &book)
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 || len(given(flags)) == 0 {
+		return errUsage
+	}
+	book, err = c.client.UpdateBook(ctx, id, book)
+	if err != nil {
+		return err
+	}
+	return c.print([]models.BookV2{book})
+}
+
+func (c *cli) delete(ctx context.Context, args []string) error {
+	id, err := bookID(args, 1)
+	if err != nil {
+		return err
+	}
+	if err := c.client.DeleteBook(ctx, id); err != nil {
+		return err
+	}
+	if c.output == "table" {
+		_, err = fmt.Fprintf(c.stdout, "deleted book %d\n", id)
+	}
+	return err
+}
+
+// stock adjusts the amount of a book in stock. The server applies the change,
+// so concurrent changes add up.
+func (c *cli) stock(ctx context.Context, args []string) error {
+	id, err := bookID(args, 2)
+	if err != nil {
+		return err
+	}
+	delta, err := strconv.Atoi(args[1])
+	if err != nil {
+		return errUsage
+	}
+	book, err := c.client.AdjustStock(ctx, id, delta)
+	if errors.Is(err, client.ErrInsufficientStock) {
+		return fmt.Errorf("not enough copies of book %d in stock", id)
+	}
+	if err != nil {
+		return err
+	}
+	return c.print([]models.BookV2{book})
+}
+
+func (c *cli) print(books []models.BookV2) error {
+	if c.output == "json" {
+		if len(books) == 1 {
+			return writeJSON(c.stdout, books[0])
+		}
+		return writeJSON(c.stdout, books)
+	}
+	w := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tAUTHORS\tPRICE\tEFFECTIVE PRICE\tGENRE\tAMOUNT")
+	for _, book := range books {
+		effective := ""
+		if book.EffectivePrice != nil {
+			effective = formatMoney(*book.EffectivePrice)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%d\t%d\n", book.ID, book.Name, strings.Join(book.Authors, ", "),
+			formatMoney(book.Price), effective, book.Genre, book.Amount)
+	}
+	return w.Flush()
+}
+
+// bookFlags defines a flag for every writable field of the book.
+func bookFlags(name string, book *models.BookV2) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.StringVar(&book.Name, "name", book.Name, "name of the book")
+	flags.Var(&authorsFlag{authors: &book.Authors}, "author", "author of the book, repeat for several, replaces the current ones")
+	flags.Var((*decimalFlag)(&book.Price.Amount), "price", "price, e.g. 9.99")
+	flags.StringVar(&book.Price.Currency, "currency", book.Price.Currency, "ISO 4217 currency code of the price")
+	flags.IntVar(&book.Genre, "genre", book.Genre, "genre, 1 to 3")
+	flags.IntVar(&book.Amount, "amount", book.Amount, "copies in stock")
+	return flags
+}
+
+// given returns the names of the flags set by the parsed arguments.
+func given(flags *flag.FlagSet) map[string]bool {
+	set := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	return set
+}
+
+// authorsFlag collects the authors of repeated flags, replacing the authors
+// the book had before.
+type authorsFlag struct {
+	authors *[]string
+	given   bool
+}
+
+func (a *authorsFlag) String() string {
+	if a == nil || a.authors == nil {
+		return ""
+	}
+	return strings.Join(*a.authors, ", ")
+}
+
+func (a *authorsFlag) Set(value string) error {
+	if value == "" {
+		return errors.New("empty author")
+	}
+	if !a.given {
+		*a.authors, a.given = nil, true
+	}
+	*a.authors = append(*a.authors, value)
+	return nil
+}
+
+type decimalFlag models.Decimal
+
+func (d *decimalFlag) String() string {
+	if d == nil {
+		return ""
+	}
+	return models.Decimal(*d).String()
+}
+
+func (d *decimalFlag) Set(value string) error {
+	price, err := models.ParseDecimal(value)
+	if err != nil {
+		return err
+	}
+	*d = decimalFlag(price)
+	return nil
+}
+
+// bookID parses the ID of the first of n arguments.
+func bookID(args []string, n int) (int, error) {
+	if len(args) != n {
+		return 0, errUsage
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil || id < 1 {
+		return 0, fmt.Errorf("invalid book ID %q", args[0])
+	}
+	return id, nil
+}
+
+func formatMoney(money models.Money) string {
+	return money.Amount.String() + " " + money.Currency
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func env(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
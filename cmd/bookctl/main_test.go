@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/porky256/rest-api/api"
+	"github.com/porky256/rest-api/db"
+	"github.com/porky256/rest-api/internal/booktest"
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	type mockBehavior func(r *api.MockDatabase)
+	gin.SetMode(gin.ReleaseMode)
+	dir := t.TempDir()
+	importFile := filepath.Join(dir, "import.csv")
+	if err := os.WriteFile(importFile, []byte("name,price,genre,amount,authors,id\n"+
+		"Dune,9.99,3,4,Frank Herbert,\nEmma,5,1,0,,2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	exportFile := filepath.Join(dir, "export.csv")
+	dune := booktest.Book(1)
+	tests := []struct {
+		name           string
+		args           []string
+		mockBehavior   mockBehavior
+		expectedOutput string
+		expectedError  string
+	}{
+		{
+			name: "list",
+			args: []string{"list", "-genre", "3"},
+			mockBehavior: func(r *api.MockDatabase) {
+				r.EXPECT().GetAllBooks(map[string][]string{"genre": {"3"}}).Return([]models.Book{dune}, nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{}, nil)
+			},
+			expectedOutput: "ID  NAME  AUTHORS        PRICE     EFFECTIVE PRICE  GENRE  AMOUNT\n" +
+				"1   Dune  Frank Herbert  9.99 USD  9.99 USD         3      4\n",
+		},
+		{
+			name: "get as JSON",
+			args: []string{"-o", "json", "get", "1"},
+			mockBehavior: func(r *api.MockDatabase) {
+				r.EXPECT().GetBookById(1).Return(dune, nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{}, nil)
+			},
+			expectedOutput: `{
+  "id": 1,
+  "name": "Dune",
+  "authors": [
+    "Frank Herbert"
+  ],
+  "price": {
+    "amount": "9.99",
+    "currency": "USD"
+  },
+  "effective_price": {
+    "amount": "9.99",
+    "currency": "USD"
+  },
+  "genre": 3,
+  "amount": 4,
+  "created_at": "2021-11-20T10:00:00Z",
+  "updated_at": "2021-11-20T10:00:00Z"
+}
+`,
+		},
+		{
+			name: "get unknown",
+			args: []string{"get", "2"},
+			mockBehavior: func(r *api.MockDatabase) {
+				r.EXPECT().GetBookById(2).Return(models.Book{}, sql.ErrNoRows)
+			},
+			expectedError: "id not found (404 Not Found)",
+		},
+		{
+			name: "create",
+			args: []string{"create", "-name", "Dune", "-author", "Frank Herbert", "-price", "9.99", "-genre", "3", "-amount", "4"},
+			mockBehavior: func(r *api.MockDatabase) {
+				added := dune
+				added.ID, added.CreatedAt, added.UpdatedAt = 0, time.Time{}, time.Time{}
+				r.EXPECT().ClaimIdempotencyKey(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.IdempotentResponse{}, true, nil)
+				r.EXPECT().AddBook(added, gomock.Any()).Return(1, nil)
+				r.EXPECT().SaveIdempotentResponse(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			expectedOutput: "created book 1\n",
+		},
+		{
+			name:          "create without price",
+			args:          []string{"create", "-name", "Dune", "-genre", "3"},
+			mockBehavior:  func(r *api.MockDatabase) {},
+			expectedError: errUsage.Error(),
+		},
+		{
+			name: "update replaces authors",
+			args: []string{"update", "1", "-author", "Brian Herbert", "-author", "Kevin J. Anderson"},
+			mockBehavior: func(r *api.MockDatabase) {
+				r.EXPECT().GetBookById(1).Return(dune, nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{}, nil)
+				updated := dune
+				updated.ID, updated.CreatedAt, updated.UpdatedAt = 0, time.Time{}, time.Time{}
+				updated.Authors = []string{"Brian Herbert", "Kevin J. Anderson"}
+				r.EXPECT().UpdateBook(1, updated, gomock.Any()).Return(nil)
+				updated.ID = 1
+				r.EXPECT().GetBookById(1).Return(updated, nil)
+			},
+			expectedOutput: "ID  NAME  AUTHORS                           PRICE     EFFECTIVE PRICE  GENRE  AMOUNT\n" +
+				"1   Dune  Brian Herbert, Kevin J. Anderson  9.99 USD                   3      4\n",
+		},
+		{
+			name: "stock",
+			args: []string{"stock", "1", "-3"},
+			mockBehavior: func(r *api.MockDatabase) {
+				adjusted := dune
+				adjusted.Amount = 1
+				r.EXPECT().ClaimIdempotencyKey(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.IdempotentResponse{}, true, nil)
+				r.EXPECT().AdjustStock(1, -3, gomock.Any()).Return(adjusted, nil)
+				r.EXPECT().SaveIdempotentResponse(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			expectedOutput: "ID  NAME  AUTHORS        PRICE     EFFECTIVE PRICE  GENRE  AMOUNT\n" +
+				"1   Dune  Frank Herbert  9.99 USD                   3      1\n",
+		},
+		{
+			name: "stock below zero",
+			args: []string{"stock", "1", "-5"},
+			mockBehavior: func(r *api.MockDatabase) {
+				r.EXPECT().ClaimIdempotencyKey(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.IdempotentResponse{}, true, nil)
+				r.EXPECT().AdjustStock(1, -5, gomock.Any()).Return(models.Book{}, db.ErrInsufficientStock)
+				r.EXPECT().SaveIdempotentResponse(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			expectedError: "not enough copies of book 1 in stock",
+		},
+		{
+			name: "delete",
+			args: []string{"delete", "1"},
+			mockBehavior: func(r *api.MockDatabase) {
+				r.EXPECT().GetBookById(1).Return(dune, nil)
+				r.EXPECT().DelBook(1, gomock.Any()).Return(nil)
+			},
+			expectedOutput: "deleted book 1\n",
+		},
+		{
+			name: "import",
+			args: []string{"import", importFile},
+			mockBehavior: func(r *api.MockDatabase) {
+				added := dune
+				added.ID, added.CreatedAt, added.UpdatedAt = 0, time.Time{}, time.Time{}
+				r.EXPECT().ClaimIdempotencyKey(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.IdempotentResponse{}, true, nil)
+				r.EXPECT().AddBook(added, gomock.Any()).Return(1, nil)
+				r.EXPECT().SaveIdempotentResponse(gomock.Any(), gomock.Any()).Return(nil)
+				r.EXPECT().UpdateBook(2, models.Book{Name: "Emma", Price: models.MustParseDecimal("5"), Currency: "USD", Genre: 1},
+					gomock.Any()).Return(nil)
+				r.EXPECT().GetBookById(2).Return(models.Book{ID: 2, Name: "Emma"}, nil)
+			},
+			expectedOutput: "created 1, updated 1 books\n",
+		},
+		{
+			name: "export",
+			args: []string{"export", exportFile},
+			mockBehavior: func(r *api.MockDatabase) {
+				r.EXPECT().GetAllBooks(map[string][]string{}).Return([]models.Book{dune}, nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{}, nil)
+			},
+			expectedOutput: "exported 1 books\n",
+		},
+		{
+			name:          "unknown command",
+			args:          []string{"purge"},
+			mockBehavior:  func(r *api.MockDatabase) {},
+			expectedError: `unknown command "purge", see bookctl -h`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			db := api.NewMockDatabase(c)
+			db.EXPECT().GetCatalogModified().Return(booktest.Created, nil).AnyTimes()
+			test.mockBehavior(db)
+			server := httptest.NewServer(api.InitializeHandler(db).Router)
+			defer server.Close()
+
+			var stdout bytes.Buffer
+			args := append([]string{"-server", server.URL, "-actor", "tester"}, test.args...)
+			err := run(context.Background(), args, &stdout)
+			if test.expectedError != "" {
+				assert.EqualError(t, err, test.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedOutput, stdout.String())
+		})
+	}
+
+	exported, err := os.ReadFile(exportFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "id,name,authors,price,currency,genre,amount\n1,Dune,Frank Herbert,9.99,USD,3,4\n", string(exported))
+}
@@ -0,0 +1,104 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/porky256/rest-api/models"
+	"strings"
+)
+
+const defaultAuditLimit = 100
+
+// writeAudit records a book mutation inside the transaction performing it,
+// so the entry is committed or rolled back together with the change.
+func writeAudit(tx *sql.Tx, bookID int, action string, info models.AuditInfo, before, after interface{}) error {
+	entry, err := models.NewAuditEntry(bookID, action, info, before, after)
+	if err != nil {
+		return err
+	}
+	diff, err := json.Marshal(entry.Diff)
+	if err != nil {
+		return err
+	}
+	query := "insert into audit_log (book_id,action,actor,request_id,before,after,diff) values ($1, $2, $3, $4, $5, $6, $7);"
+	_, err = tx.Exec(query, entry.BookID, entry.Action, entry.Actor, entry.RequestID,
+		nullJSON(entry.Before), nullJSON(entry.After), diff)
+	return err
+}
+
+func nullJSON(data json.RawMessage) interface{} {
+	if data == nil {
+		return nil
+	}
+	return []byte(data)
+}
+
+func (db *DatabasePostgres) GetBookHistory(id int) ([]models.AuditEntry, error) {
+	return db.GetAuditLog(models.AuditFilter{BookID: id})
+}
+
+func (db *DatabasePostgres) GetAuditLog(filter models.AuditFilter) ([]models.AuditEntry, error) {
+	var conditions []string
+	var queryinfo []interface{}
+	if filter.BookID != 0 {
+		queryinfo = append(queryinfo, filter.BookID)
+		conditions = append(conditions, fmt.Sprintf("book_id=$%d", len(queryinfo)))
+	}
+	if filter.Actor != "" {
+		queryinfo = append(queryinfo, filter.Actor)
+		conditions = append(conditions, fmt.Sprintf("actor=$%d", len(queryinfo)))
+	}
+	if filter.Action != "" {
+		queryinfo = append(queryinfo, filter.Action)
+		conditions = append(conditions, fmt.Sprintf("action=$%d", len(queryinfo)))
+	}
+	if !filter.From.IsZero() {
+		queryinfo = append(queryinfo, filter.From)
+		conditions = append(conditions, fmt.Sprintf("created_at>=$%d", len(queryinfo)))
+	}
+	if !filter.To.IsZero() {
+		queryinfo = append(queryinfo, filter.To)
+		conditions = append(conditions, fmt.Sprintf("created_at<$%d", len(queryinfo)))
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultAuditLimit
+	}
+	queryinfo = append(queryinfo, limit)
+
+	query := "select id, book_id, action, actor, request_id, before, after, diff, created_at from audit_log"
+	if len(conditions) > 0 {
+		query += " where " + strings.Join(conditions, " and ")
+	}
+	query += fmt.Sprintf(" order by created_at desc, id desc limit $%d;", len(queryinfo))
+
+	list := []models.AuditEntry{}
+	rows, err := db.Conn.Query(query, queryinfo...)
+	if err != nil {
+		return list, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry models.AuditEntry
+		var before, after, diff []byte
+		err = rows.Scan(&entry.ID, &entry.BookID, &entry.Action, &entry.Actor, &entry.RequestID,
+			&before, &after, &diff, &entry.CreatedAt)
+		if err != nil {
+			return list, err
+		}
+		if before != nil {
+			entry.Before = before
+		}
+		if after != nil {
+			entry.After = after
+		}
+		err = json.Unmarshal(diff, &entry.Diff)
+		if err != nil {
+			return list, err
+		}
+		list = append(list, entry)
+	}
+	return list, rows.Err()
+}
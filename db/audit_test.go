@@ -0,0 +1,77 @@
+package db
+
+import (
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestDatabasePostgres_GetAuditLog(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error in creating mock: %s", err)
+	}
+	db := DatabasePostgres{Conn: conn}
+	created := time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)
+	columns := []string{"id", "book_id", "action", "actor", "request_id", "before", "after", "diff", "created_at"}
+	type MockBehavior func(mock sqlmock.Sqlmock, filter models.AuditFilter)
+	tests := []struct {
+		name          string
+		filter        models.AuditFilter
+		returnEntries []models.AuditEntry
+		mockBehavior  MockBehavior
+		returnErr     bool
+	}{
+		{
+			name:   "OK",
+			filter: models.AuditFilter{},
+			returnEntries: []models.AuditEntry{
+				{ID: 1, BookID: 1, Action: models.AuditActionCreate, Actor: "tester", RequestID: "1",
+					After: []byte(`{"id":1}`), Diff: map[string]models.AuditChange{"id": {Before: []byte(`null`), After: []byte(`1`)}}, CreatedAt: created},
+			},
+			mockBehavior: func(mock sqlmock.Sqlmock, filter models.AuditFilter) {
+				mock.ExpectQuery("select (.+) from audit_log order by").
+					WithArgs(defaultAuditLimit).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(1, 1, models.AuditActionCreate, "tester", "1", nil, []byte(`{"id":1}`), []byte(`{"id":{"before":null,"after":1}}`), created))
+			},
+		},
+		{
+			name:          "OK with filter",
+			filter:        models.AuditFilter{BookID: 2, Actor: "tester", Action: models.AuditActionDelete, From: created, Limit: 10},
+			returnEntries: []models.AuditEntry{},
+			mockBehavior: func(mock sqlmock.Sqlmock, filter models.AuditFilter) {
+				mock.ExpectQuery("from audit_log where book_id=\\$1 and actor=\\$2 and action=\\$3 and created_at>=\\$4").
+					WithArgs(filter.BookID, filter.Actor, filter.Action, filter.From, filter.Limit).
+					WillReturnRows(sqlmock.NewRows(columns))
+			},
+		},
+		{
+			name:      "Query error",
+			filter:    models.AuditFilter{BookID: 3},
+			returnErr: true,
+			mockBehavior: func(mock sqlmock.Sqlmock, filter models.AuditFilter) {
+				mock.ExpectQuery("from audit_log").
+					WithArgs(filter.BookID, defaultAuditLimit).
+					WillReturnError(errors.New("query error"))
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.mockBehavior(mock, test.filter)
+
+			entries, err := db.GetAuditLog(test.filter)
+			if test.returnErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.returnEntries, entries)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
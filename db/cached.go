@@ -0,0 +1,145 @@
+package db
+
+import (
+	"fmt"
+	"github.com/porky256/rest-api/cache"
+	"github.com/porky256/rest-api/models"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// CachedDatabase serves book reads from a cache. Every method writing books
+// or promotions invalidates it, all other methods go straight to the wrapped
+// Database. That includes GetCatalogModified, promotions starting or ending
+// change effective prices without any write.
+type CachedDatabase struct {
+	Database
+	Cache cache.Cache
+	TTL   time.Duration
+	// generation is part of every key, so reads that raced with a write
+	// store their results under keys no one looks up anymore.
+	generation uint64
+}
+
+func NewCachedDatabase(database Database, cache cache.Cache, ttl time.Duration) *CachedDatabase {
+	return &CachedDatabase{Database: database, Cache: cache, TTL: ttl}
+}
+
+// Invalidate drops everything cached, e.g. after another instance changed a book.
+func (db *CachedDatabase) Invalidate() {
+	atomic.AddUint64(&db.generation, 1)
+	db.Cache.Clear()
+}
+
+func (db *CachedDatabase) key(format string, args ...interface{}) string {
+	return fmt.Sprintf("%d:", atomic.LoadUint64(&db.generation)) + fmt.Sprintf(format, args...)
+}
+
+func (db *CachedDatabase) GetAllBooks(filter map[string][]string) ([]models.Book, error) {
+	key := db.key("books?%s", url.Values(filter).Encode())
+	if value, ok := db.Cache.Get(key); ok {
+		return append([]models.Book{}, value.([]models.Book)...), nil
+	}
+	books, err := db.Database.GetAllBooks(filter)
+	if err != nil {
+		return books, err
+	}
+	db.Cache.Set(key, append([]models.Book{}, books...), db.TTL)
+	return books, nil
+}
+
+func (db *CachedDatabase) GetBookById(id int) (models.Book, error) {
+	key := db.key("book/%d", id)
+	if value, ok := db.Cache.Get(key); ok {
+		return value.(models.Book), nil
+	}
+	book, err := db.Database.GetBookById(id)
+	if err != nil {
+		return book, err
+	}
+	db.Cache.Set(key, book, db.TTL)
+	return book, nil
+}
+
+func (db *CachedDatabase) GetAllBooksFields(filter map[string][]string, columns []string) ([]models.Book, error) {
+	key := db.key("books?%s#%s", url.Values(filter).Encode(), strings.Join(columns, ","))
+	if value, ok := db.Cache.Get(key); ok {
+		return append([]models.Book{}, value.([]models.Book)...), nil
+	}
+	books, err := db.Database.GetAllBooksFields(filter, columns)
+	if err != nil {
+		return books, err
+	}
+	db.Cache.Set(key, append([]models.Book{}, books...), db.TTL)
+	return books, nil
+}
+
+func (db *CachedDatabase) GetBookByIdFields(id int, columns []string) (models.Book, error) {
+	key := db.key("book/%d#%s", id, strings.Join(columns, ","))
+	if value, ok := db.Cache.Get(key); ok {
+		return value.(models.Book), nil
+	}
+	book, err := db.Database.GetBookByIdFields(id, columns)
+	if err != nil {
+		return book, err
+	}
+	db.Cache.Set(key, book, db.TTL)
+	return book, nil
+}
+
+func (db *CachedDatabase) AddBook(book models.Book, info models.AuditInfo) (int, error) {
+	defer db.Invalidate()
+	return db.Database.AddBook(book, info)
+}
+
+func (db *CachedDatabase) DelBook(id int, info models.AuditInfo) error {
+	defer db.Invalidate()
+	return db.Database.DelBook(id, info)
+}
+
+func (db *CachedDatabase) UpdateBook(id int, book models.Book, info models.AuditInfo) error {
+	defer db.Invalidate()
+	return db.Database.UpdateBook(id, book, info)
+}
+
+func (db *CachedDatabase) RestoreBook(id int, info models.AuditInfo) (models.Book, error) {
+	defer db.Invalidate()
+	return db.Database.RestoreBook(id, info)
+}
+
+func (db *CachedDatabase) PurgeBooks(before time.Time, info models.AuditInfo) (int, error) {
+	defer db.Invalidate()
+	return db.Database.PurgeBooks(before, info)
+}
+
+func (db *CachedDatabase) CreateOrder(order models.Order, info models.AuditInfo) (models.Order, error) {
+	defer db.Invalidate()
+	return db.Database.CreateOrder(order, info)
+}
+
+func (db *CachedDatabase) UpdateOrderStatus(id int, status string, info models.AuditInfo) (models.Order, error) {
+	defer db.Invalidate()
+	return db.Database.UpdateOrderStatus(id, status, info)
+}
+
+func (db *CachedDatabase) AdjustStock(id int, delta int, info models.AuditInfo) (models.Book, error) {
+	defer db.Invalidate()
+	return db.Database.AdjustStock(id, delta, info)
+}
+
+func (db *CachedDatabase) AddPromotion(promotion models.Promotion) (int, error) {
+	defer db.Invalidate()
+	return db.Database.AddPromotion(promotion)
+}
+
+func (db *CachedDatabase) UpdatePromotion(id int, promotion models.Promotion) error {
+	defer db.Invalidate()
+	return db.Database.UpdatePromotion(id, promotion)
+}
+
+func (db *CachedDatabase) DelPromotion(id int) error {
+	defer db.Invalidate()
+	return db.Database.DelPromotion(id)
+}
@@ -0,0 +1,102 @@
+package db
+
+import (
+	"database/sql"
+	"github.com/porky256/rest-api/cache"
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// countingDatabase counts the reads reaching the database.
+type countingDatabase struct {
+	Database
+	reads int
+	books []models.Book
+}
+
+func (db *countingDatabase) GetAllBooks(filter map[string][]string) ([]models.Book, error) {
+	db.reads++
+	return append([]models.Book{}, db.books...), nil
+}
+
+func (db *countingDatabase) GetAllBooksFields(filter map[string][]string, columns []string) ([]models.Book, error) {
+	return db.GetAllBooks(filter)
+}
+
+func (db *countingDatabase) GetBookById(id int) (models.Book, error) {
+	db.reads++
+	for _, book := range db.books {
+		if book.ID == id {
+			return book, nil
+		}
+	}
+	return models.Book{}, sql.ErrNoRows
+}
+
+func (db *countingDatabase) GetCatalogModified() (time.Time, error) {
+	db.reads++
+	return time.Unix(int64(db.reads), 0), nil
+}
+
+func (db *countingDatabase) UpdateBook(id int, book models.Book, info models.AuditInfo) error {
+	db.books[id-1] = book
+	return nil
+}
+
+func TestCachedDatabase(t *testing.T) {
+	info := models.AuditInfo{Actor: "tester", RequestID: "1"}
+	source := &countingDatabase{books: []models.Book{{ID: 1, Name: "OK", Price: models.MustParseDecimal("1.00"), Currency: "USD", Genre: 1, Amount: 1}}}
+	db := NewCachedDatabase(source, cache.NewLRU(10), time.Minute)
+
+	for i := 0; i < 2; i++ {
+		books, err := db.GetAllBooks(map[string][]string{"genre": {"1"}})
+		assert.NoError(t, err)
+		assert.Equal(t, source.books, books)
+		book, err := db.GetBookById(1)
+		assert.NoError(t, err)
+		assert.Equal(t, source.books[0], book)
+	}
+	assert.Equal(t, 2, source.reads)
+
+	books, _ := db.GetAllBooks(map[string][]string{"genre": {"2"}})
+	books[0].Name = "changed by the caller"
+	books, _ = db.GetAllBooks(map[string][]string{"genre": {"2"}})
+	assert.Equal(t, source.books, books, "callers can't change cached books")
+	assert.Equal(t, 3, source.reads, "other filters are cached separately")
+
+	for i := 0; i < 2; i++ {
+		_, err := db.GetAllBooksFields(map[string][]string{"genre": {"2"}}, []string{"id", "name"})
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 4, source.reads, "other columns are cached separately")
+
+	_, err := db.GetBookById(2)
+	assert.Equal(t, sql.ErrNoRows, err)
+	_, err = db.GetBookById(2)
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Equal(t, 6, source.reads, "errors aren't cached")
+
+	updated := models.Book{ID: 1, Name: "Updated", Price: models.MustParseDecimal("2.00"), Currency: "USD", Genre: 1, Amount: 1}
+	assert.NoError(t, db.UpdateBook(1, updated, info))
+	book, err := db.GetBookById(1)
+	assert.NoError(t, err)
+	assert.Equal(t, updated, book)
+	books, err = db.GetAllBooks(map[string][]string{"genre": {"2"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Book{updated}, books)
+	assert.Equal(t, 8, source.reads)
+}
+
+func TestCachedDatabaseCatalogModified(t *testing.T) {
+	source := &countingDatabase{}
+	db := NewCachedDatabase(source, cache.NewLRU(10), time.Minute)
+
+	first, err := db.GetCatalogModified()
+	assert.NoError(t, err)
+	second, err := db.GetCatalogModified()
+	assert.NoError(t, err)
+	assert.True(t, second.After(first), "a promotion starting in between moves it without a write")
+	assert.Equal(t, 2, source.reads)
+}
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/porky256/rest-api/models"
+	"strings"
+)
+
+// scanner is implemented by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// bookProjection is a list of book columns, selected and scanned in its
+// order. Queries name the columns they read, so columns added to books by
+// later migrations don't shift the ones scanned.
+type bookProjection []string
+
+// bookColumns are the columns of books that can be selected, in the order
+// they are selected in.
+var bookColumns = bookProjection{"id", "name", "price", "currency", "genre", "amount", "authors", "created_at", "updated_at"}
+
+// bookSummary are the columns audit entries and events record.
+var bookSummary = bookProjection{"id", "name", "price", "currency", "genre", "amount"}
+
+// bookColumnFields returns the field of a book a column is scanned into.
+var bookColumnFields = map[string]func(book *models.Book) interface{}{
+	"id":         func(book *models.Book) interface{} { return &book.ID },
+	"name":       func(book *models.Book) interface{} { return &book.Name },
+	"price":      func(book *models.Book) interface{} { return &book.Price },
+	"currency":   func(book *models.Book) interface{} { return &book.Currency },
+	"genre":      func(book *models.Book) interface{} { return &book.Genre },
+	"amount":     func(book *models.Book) interface{} { return &book.Amount },
+	"authors":    func(book *models.Book) interface{} { return pq.Array(&book.Authors) },
+	"created_at": func(book *models.Book) interface{} { return &book.CreatedAt },
+	"updated_at": func(book *models.Book) interface{} { return &book.UpdatedAt },
+}
+
+// projectBook returns the projection of the columns in the order of
+// bookColumns, every column when there are none. The price is always selected
+// with its currency, which it is rounded to.
+func projectBook(columns []string) (bookProjection, error) {
+	if len(columns) == 0 {
+		return bookColumns, nil
+	}
+	selected := map[string]bool{}
+	for _, column := range columns {
+		if _, ok := bookColumnFields[column]; !ok {
+			return nil, fmt.Errorf("unknown book column %q", column)
+		}
+		selected[column] = true
+	}
+	if selected["price"] {
+		selected["currency"] = true
+	}
+	var projection bookProjection
+	for _, column := range bookColumns {
+		if selected[column] {
+			projection = append(projection, column)
+		}
+	}
+	return projection, nil
+}
+
+func (p bookProjection) String() string {
+	return strings.Join(p, ", ")
+}
+
+// qualified returns the select list with the columns qualified by the alias
+// of books in a join.
+func (p bookProjection) qualified(alias string) string {
+	columns := make([]string, 0, len(p))
+	for _, column := range p {
+		columns = append(columns, alias+"."+column)
+	}
+	return strings.Join(columns, ", ")
+}
+
+// scan reads a book selected with the projection followed by the extra
+// destinations, giving the price as many decimal places as its currency has.
+func (p bookProjection) scan(row scanner, book *models.Book, extra ...interface{}) error {
+	dest := make([]interface{}, 0, len(p)+len(extra))
+	for _, column := range p {
+		dest = append(dest, bookColumnFields[column](book))
+	}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return err
+	}
+	book.Price, err = currencyPrice(book.Price, book.Currency)
+	return err
+}
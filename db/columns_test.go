@@ -0,0 +1,282 @@
+package db
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	createBooks    = regexp.MustCompile(`(?is)create table (?:if not exists )?books\s*\((.*?)\n\);`)
+	addBookColumn  = regexp.MustCompile(`(?i)alter table books add column (?:if not exists )?(\w+)`)
+	dropBookColumn = regexp.MustCompile(`(?i)alter table books drop column (?:if exists )?(\w+)`)
+)
+
+// migrateBooks returns the columns of books after the migration.
+func migrateBooks(columns []string, migration string) []string {
+	if create := createBooks.FindStringSubmatch(migration); create != nil {
+		columns = nil
+		for _, line := range strings.Split(create[1], "\n") {
+			fields := strings.Fields(strings.TrimSpace(line))
+			if len(fields) == 0 {
+				continue
+			}
+			switch strings.ToLower(fields[0]) {
+			case "constraint", "primary", "unique", "check", "foreign":
+				continue
+			}
+			columns = append(columns, fields[0])
+		}
+	}
+	for _, add := range addBookColumn.FindAllStringSubmatch(migration, -1) {
+		columns = append(columns, add[1])
+	}
+	for _, drop := range dropBookColumn.FindAllStringSubmatch(migration, -1) {
+		for i, column := range columns {
+			if column == drop[1] {
+				columns = append(columns[:i], columns[i+1:]...)
+				break
+			}
+		}
+	}
+	return columns
+}
+
+// migratedBooks returns the columns of books after all up migrations.
+func migratedBooks(t *testing.T) []string {
+	files, err := filepath.Glob(filepath.Join("..", "migrations", "*.up.sql"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("Error in reading migrations: %v", err)
+	}
+	var columns []string
+	for _, file := range files {
+		migration, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Error in reading migrations: %s", err)
+		}
+		columns = migrateBooks(columns, string(migration))
+	}
+	return columns
+}
+
+var bookValues = map[string]driver.Value{
+	"id":         int64(1),
+	"name":       "Dune",
+	"price":      "9.99",
+	"currency":   "USD",
+	"genre":      int64(3),
+	"amount":     int64(4),
+	"authors":    `{"Frank Herbert"}`,
+	"created_at": bookCreated,
+	"updated_at": bookCreated,
+}
+
+// readsEveryColumn finds select lists reading every column of a table.
+var readsEveryColumn = regexp.MustCompile(`(?i)\b(select|returning)\s+(\w+\.)?\*`)
+
+// namedColumns matches queries by regular expression like sqlmock does by
+// default, failing those reading every column: once a migration adds a
+// column to books they read it too, shifting the ones scanned.
+var namedColumns = sqlmock.QueryMatcherFunc(func(expected, actual string) error {
+	if readsEveryColumn.MatchString(actual) {
+		return fmt.Errorf("query reads every column: %s", actual)
+	}
+	return sqlmock.QueryMatcherRegexp.Match(expected, actual)
+})
+
+// tableRows returns a row of the columns of a book followed by the extra
+// values, failing the test on columns the table doesn't have.
+func tableRows(t *testing.T, table []string, projection bookProjection, extra ...driver.Value) *sqlmock.Rows {
+	columns := append([]string{}, projection...)
+	values := make([]driver.Value, 0, len(projection)+len(extra))
+	for _, column := range projection {
+		assert.Contains(t, table, column)
+		values = append(values, bookValues[column])
+	}
+	for i, value := range extra {
+		columns = append(columns, fmt.Sprintf("extra%d", i))
+		values = append(values, value)
+	}
+	return sqlmock.NewRows(columns).AddRow(values...)
+}
+
+func TestMigrateBooks(t *testing.T) {
+	assert.Subset(t, migratedBooks(t), []string(bookColumns))
+}
+
+func TestBookQueriesWithNewColumn(t *testing.T) {
+	table := migrateBooks(migratedBooks(t), "alter table books add column if not exists isbn varchar(13);")
+	if !assert.Contains(t, table, "isbn") {
+		return
+	}
+	conn, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(namedColumns))
+	if err != nil {
+		t.Fatalf("Error in creating mock: %s", err)
+	}
+	db := DatabasePostgres{Conn: conn}
+	info := models.AuditInfo{Actor: "tester", RequestID: "1"}
+	summary := models.Book{ID: 1, Name: "Dune", Price: models.MustParseDecimal("9.99"), Currency: "USD", Genre: 3, Amount: 4}
+	book := summary
+	book.Authors, book.CreatedAt, book.UpdatedAt = []string{"Frank Herbert"}, bookCreated, bookCreated
+	tests := []struct {
+		name         string
+		mockBehavior func(mock sqlmock.Sqlmock)
+		query        func() (interface{}, error)
+		expected     interface{}
+	}{
+		{
+			name: "GetAllBooks",
+			mockBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("select " + bookColumns.String() + " from books")).
+					WillReturnRows(tableRows(t, table, bookColumns))
+				mock.ExpectCommit()
+			},
+			query:    func() (interface{}, error) { return db.GetAllBooks(map[string][]string{"genre": {"3"}}) },
+			expected: []models.Book{book},
+		},
+		{
+			name: "GetAllBooksFields",
+			mockBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("select name, price, currency from books")).
+					WillReturnRows(tableRows(t, table, bookProjection{"name", "price", "currency"}))
+				mock.ExpectCommit()
+			},
+			query: func() (interface{}, error) {
+				return db.GetAllBooksFields(map[string][]string{}, []string{"name", "price"})
+			},
+			expected: []models.Book{{Name: "Dune", Price: summary.Price, Currency: "USD"}},
+		},
+		{
+			name: "GetBooksPage",
+			mockBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("select " + bookColumns.String() + " from books")).
+					WillReturnRows(tableRows(t, table, bookColumns))
+			},
+			query:    func() (interface{}, error) { return db.GetBooksPage(map[string][]string{}, 2, 10) },
+			expected: []models.Book{book},
+		},
+		{
+			name: "GetBookById",
+			mockBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("select " + bookColumns.String() + " from books")).
+					WillReturnRows(tableRows(t, table, bookColumns))
+				mock.ExpectCommit()
+			},
+			query:    func() (interface{}, error) { return db.GetBookById(1) },
+			expected: book,
+		},
+		{
+			name: "SearchBooks",
+			mockBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("select " + bookColumns.String() + ",")).
+					WillReturnRows(tableRows(t, table, bookColumns, 0.5, "<b>Dune</b>"))
+			},
+			query:    func() (interface{}, error) { return db.SearchBooks("dune", 0) },
+			expected: []models.SearchResult{{Book: book, Rank: 0.5, Snippet: "<b>Dune</b>"}},
+		},
+		{
+			name: "GetLowStockBooks",
+			mockBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("select " + bookSummary.qualified("b") + ",")).
+					WillReturnRows(tableRows(t, table, bookSummary, int64(5)))
+			},
+			query:    func() (interface{}, error) { return db.GetLowStockBooks() },
+			expected: []models.LowStockBook{{Book: summary, Threshold: 5}},
+		},
+		{
+			name: "RestoreBook",
+			mockBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("returning " + bookColumns.String() + ";")).
+					WillReturnRows(tableRows(t, table, bookColumns))
+				mock.ExpectExec("insert into audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+			query:    func() (interface{}, error) { return db.RestoreBook(1, info) },
+			expected: book,
+		},
+		{
+			name: "DelBook",
+			mockBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("returning " + bookSummary.String() + ";")).
+					WillReturnRows(tableRows(t, table, bookSummary))
+				mock.ExpectExec("insert into audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("insert into outbox").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+			query:    func() (interface{}, error) { return nil, db.DelBook(1, info) },
+			expected: nil,
+		},
+		{
+			name: "PurgeBooks",
+			mockBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("returning " + bookSummary.String() + ";")).
+					WillReturnRows(tableRows(t, table, bookSummary))
+				mock.ExpectExec("insert into audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+			query:    func() (interface{}, error) { return db.PurgeBooks(time.Time{}, info) },
+			expected: 1,
+		},
+		{
+			name: "adjustStock",
+			mockBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("select " + bookSummary.String() + " from books")).
+					WillReturnRows(tableRows(t, table, bookSummary))
+				mock.ExpectExec("update books set amount").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("insert into audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+			query: func() (interface{}, error) {
+				tx, err := db.Conn.Begin()
+				if err != nil {
+					return nil, err
+				}
+				after, err := adjustStock(tx, 1, -1, info)
+				if err != nil {
+					return nil, err
+				}
+				return after.Amount, tx.Commit()
+			},
+			expected: 3,
+		},
+		{
+			name: "AdjustStock",
+			mockBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("select " + bookSummary.String() + " from books")).
+					WillReturnRows(tableRows(t, table, bookSummary))
+				mock.ExpectExec("update books set amount").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("insert into audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectQuery(regexp.QuoteMeta("select " + bookColumns.String() + " from books")).
+					WillReturnRows(tableRows(t, table, bookColumns))
+				mock.ExpectCommit()
+			},
+			query:    func() (interface{}, error) { return db.AdjustStock(1, -1, info) },
+			expected: book,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.mockBehavior(mock)
+			result, err := test.query()
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, result)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package db
+
+import (
+	"database/sql"
+	"github.com/porky256/rest-api/models"
+)
+
+const customerColumns = "id, name, email, phone, street, city, postal_code, country, created_at"
+
+func scanCustomer(row scanner, customer *models.Customer) error {
+	return row.Scan(&customer.ID, &customer.Name, &customer.Email, &customer.Phone,
+		&customer.Address.Street, &customer.Address.City, &customer.Address.PostalCode, &customer.Address.Country,
+		&customer.CreatedAt)
+}
+
+func (db *DatabasePostgres) GetAllCustomers() ([]models.Customer, error) {
+	list := []models.Customer{}
+	query := "select " + customerColumns + " from customers order by id desc;"
+	rows, err := db.Conn.Query(query)
+	if err != nil {
+		return list, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var customer models.Customer
+		err = scanCustomer(rows, &customer)
+		if err != nil {
+			return list, err
+		}
+		list = append(list, customer)
+	}
+	return list, rows.Err()
+}
+
+func (db *DatabasePostgres) GetCustomerById(id int) (models.Customer, error) {
+	var customer models.Customer
+	query := "select " + customerColumns + " from customers where id=$1;"
+	err := scanCustomer(db.Conn.QueryRow(query, id), &customer)
+	return customer, err
+}
+
+func (db *DatabasePostgres) AddCustomer(customer models.Customer) (models.Customer, error) {
+	query := "insert into customers (name,email,phone,street,city,postal_code,country) values ($1, $2, $3, $4, $5, $6, $7) returning " +
+		customerColumns + ";"
+	row := db.Conn.QueryRow(query, customer.Name, customer.Email, customer.Phone,
+		customer.Address.Street, customer.Address.City, customer.Address.PostalCode, customer.Address.Country)
+	var created models.Customer
+	err := scanCustomer(row, &created)
+	return created, err
+}
+
+func (db *DatabasePostgres) UpdateCustomer(id int, customer models.Customer) (models.Customer, error) {
+	query := "update customers set name=$1, email=$2, phone=$3, street=$4, city=$5, postal_code=$6, country=$7 where id=$8 returning " +
+		customerColumns + ";"
+	row := db.Conn.QueryRow(query, customer.Name, customer.Email, customer.Phone,
+		customer.Address.Street, customer.Address.City, customer.Address.PostalCode, customer.Address.Country, id)
+	var updated models.Customer
+	err := scanCustomer(row, &updated)
+	return updated, err
+}
+
+func (db *DatabasePostgres) DelCustomer(id int) error {
+	query := "delete from customers where id=$1;"
+	res, err := db.Conn.Exec(query, id)
+	if err != nil {
+		return err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetCustomerOrders responds with the customer's orders, newest first.
+// It returns sql.ErrNoRows if there is no such customer.
+func (db *DatabasePostgres) GetCustomerOrders(id int) ([]models.Order, error) {
+	tx, err := db.Conn.Begin()
+	if err != nil {
+		return []models.Order{}, err
+	}
+
+	defer func() {
+		switch err {
+		case nil:
+			err = tx.Commit()
+		default:
+			err = tx.Rollback()
+		}
+	}()
+
+	list := []models.Order{}
+	query := "select id from customers where id=$1;"
+	err = tx.QueryRow(query, id).Scan(&id)
+	if err != nil {
+		return list, err
+	}
+
+	query = "select " + orderColumns + " from orders where customer_id=$1 order by created_at desc, id desc;"
+	rows, err := tx.Query(query, id)
+	if err != nil {
+		return list, err
+	}
+	for rows.Next() {
+		var order models.Order
+		err = scanOrder(rows, &order)
+		if err != nil {
+			rows.Close()
+			return list, err
+		}
+		list = append(list, order)
+	}
+	err = rows.Err()
+	if err != nil {
+		return list, err
+	}
+
+	err = loadOrderItems(tx, list)
+	return list, err
+}
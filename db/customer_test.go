@@ -0,0 +1,174 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+var customerColumnNames = []string{"id", "name", "email", "phone", "street", "city", "postal_code", "country", "created_at"}
+
+func TestDatabasePostgres_AddCustomer(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error in creating mock: %s", err)
+	}
+	db := DatabasePostgres{Conn: conn}
+	created := time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)
+	type MockBehavior func(mock sqlmock.Sqlmock, customer models.Customer)
+	tests := []struct {
+		name           string
+		inputCustomer  models.Customer
+		returnCustomer models.Customer
+		mockBehavior   MockBehavior
+		returnErr      bool
+	}{
+		{
+			name:          "OK",
+			inputCustomer: models.Customer{Name: "Ann", Email: "ann@example.com", Address: models.Address{City: "Oslo", Country: "NO"}},
+			returnCustomer: models.Customer{ID: 1, Name: "Ann", Email: "ann@example.com",
+				Address: models.Address{City: "Oslo", Country: "NO"}, CreatedAt: created},
+			mockBehavior: func(mock sqlmock.Sqlmock, customer models.Customer) {
+				mock.ExpectQuery("insert into customers").
+					WithArgs(customer.Name, customer.Email, "", "", "Oslo", "", "NO").
+					WillReturnRows(sqlmock.NewRows(customerColumnNames).
+						AddRow(1, "Ann", "ann@example.com", "", "", "Oslo", "", "NO", created))
+			},
+		},
+		{
+			name:          "Duplicate email",
+			inputCustomer: models.Customer{Name: "Ann", Email: "ann@example.com"},
+			returnErr:     true,
+			mockBehavior: func(mock sqlmock.Sqlmock, customer models.Customer) {
+				mock.ExpectQuery("insert into customers").
+					WillReturnError(errors.New("duplicate key value"))
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.mockBehavior(mock, test.inputCustomer)
+
+			customer, err := db.AddCustomer(test.inputCustomer)
+			if test.returnErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.returnCustomer, customer)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestDatabasePostgres_DelCustomer(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error in creating mock: %s", err)
+	}
+	db := DatabasePostgres{Conn: conn}
+	type MockBehavior func(mock sqlmock.Sqlmock, id int)
+	tests := []struct {
+		name         string
+		inputId      int
+		mockBehavior MockBehavior
+		returnErr    error
+	}{
+		{
+			name:    "OK",
+			inputId: 1,
+			mockBehavior: func(mock sqlmock.Sqlmock, id int) {
+				mock.ExpectExec("delete from customers").
+					WithArgs(id).WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+		},
+		{
+			name:      "No such id",
+			inputId:   1422,
+			returnErr: sql.ErrNoRows,
+			mockBehavior: func(mock sqlmock.Sqlmock, id int) {
+				mock.ExpectExec("delete from customers").
+					WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.mockBehavior(mock, test.inputId)
+
+			err := db.DelCustomer(test.inputId)
+			assert.Equal(t, test.returnErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestDatabasePostgres_GetCustomerOrders(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error in creating mock: %s", err)
+	}
+	db := DatabasePostgres{Conn: conn}
+	created := time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)
+	type MockBehavior func(mock sqlmock.Sqlmock, id int)
+	tests := []struct {
+		name         string
+		inputId      int
+		returnOrders []models.Order
+		mockBehavior MockBehavior
+		returnErr    error
+	}{
+		{
+			name:    "OK",
+			inputId: 3,
+			returnOrders: []models.Order{
+				{ID: 5, CustomerID: 3, Status: models.OrderStatusPaid, Total: models.MustParseDecimal("2.00"), Currency: "USD", CreatedAt: created, UpdatedAt: created,
+					Items: []models.OrderItem{{BookID: 1, Name: "book1", Quantity: 1, Price: models.MustParseDecimal("2.00")}}},
+				{ID: 4, CustomerID: 3, Status: models.OrderStatusCancelled, Total: models.MustParseDecimal("0.00"), Currency: "USD", CreatedAt: created, UpdatedAt: created,
+					Items: []models.OrderItem{}},
+			},
+			mockBehavior: func(mock sqlmock.Sqlmock, id int) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("select id from customers").WithArgs(id).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(id))
+				mock.ExpectQuery("from orders where customer_id").WithArgs(id).
+					WillReturnRows(sqlmock.NewRows(orderColumnNames).
+						AddRow(5, id, models.OrderStatusPaid, 2, "USD", created, created).
+						AddRow(4, id, models.OrderStatusCancelled, 0, "USD", created, created))
+				mock.ExpectQuery("from order_items").WithArgs(pq.Array([]int64{5, 4})).
+					WillReturnRows(sqlmock.NewRows(itemColumnNames).AddRow(5, 1, "book1", 1, 2))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name:      "No such customer",
+			inputId:   1422,
+			returnErr: sql.ErrNoRows,
+			mockBehavior: func(mock sqlmock.Sqlmock, id int) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("select id from customers").WithArgs(id).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				mock.ExpectRollback()
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.mockBehavior(mock, test.inputId)
+
+			orders, err := db.GetCustomerOrders(test.inputId)
+			if test.returnErr != nil {
+				assert.Equal(t, test.returnErr, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.returnOrders, orders)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -3,10 +3,12 @@ package db
 import (
 	"database/sql"
 	"fmt"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/porky256/rest-api/models"
 	"log"
 	"strconv"
+	"strings"
+	"time"
 )
 
 const (
@@ -16,20 +18,78 @@ const (
 
 type Database interface {
 	GetAllBooks(filter map[string][]string) ([]models.Book, error)
-	AddBook(book models.Book) (int, error)
-	DelBook(id int) error
-	UpdateBook(id int, book models.Book) error
+	AddBook(book models.Book, info models.AuditInfo) (int, error)
+	DelBook(id int, info models.AuditInfo) error
+	UpdateBook(id int, book models.Book, info models.AuditInfo) error
 	GetBookById(id int) (models.Book, error)
+	GetAllBooksFields(filter map[string][]string, columns []string) ([]models.Book, error)
+	GetBooksPage(filter map[string][]string, after int, limit int) ([]models.Book, error)
+	GetBookByIdFields(id int, columns []string) (models.Book, error)
+	GetCatalogModified() (time.Time, error)
+	GetGenres(ids []int) ([]models.Genre, error)
+	SearchBooks(query string, limit int) ([]models.SearchResult, error)
+	RestoreBook(id int, info models.AuditInfo) (models.Book, error)
+	PurgeBooks(before time.Time, info models.AuditInfo) (int, error)
+	GetBookHistory(id int) ([]models.AuditEntry, error)
+	GetAuditLog(filter models.AuditFilter) ([]models.AuditEntry, error)
+	CreateOrder(order models.Order, info models.AuditInfo) (models.Order, error)
+	GetOrder(id int) (models.Order, error)
+	UpdateOrderStatus(id int, status string, info models.AuditInfo) (models.Order, error)
+	GetAllCustomers() ([]models.Customer, error)
+	GetCustomerById(id int) (models.Customer, error)
+	AddCustomer(customer models.Customer) (models.Customer, error)
+	UpdateCustomer(id int, customer models.Customer) (models.Customer, error)
+	DelCustomer(id int) error
+	GetCustomerOrders(id int) ([]models.Order, error)
+	GetAllPromotions() ([]models.Promotion, error)
+	GetActivePromotions(at time.Time) ([]models.Promotion, error)
+	GetPromotionById(id int) (models.Promotion, error)
+	AddPromotion(promotion models.Promotion) (int, error)
+	UpdatePromotion(id int, promotion models.Promotion) error
+	DelPromotion(id int) error
+	GetPriceHistory(id int, filter models.PriceFilter) ([]models.PriceChange, error)
+	GetPriceChangeReport(filter models.PriceFilter) ([]models.PriceChangeReport, error)
+	AdjustStock(id int, delta int, info models.AuditInfo) (models.Book, error)
+	GetStockThresholds() ([]models.StockThreshold, error)
+	AddStockThreshold(threshold models.StockThreshold) (int, error)
+	UpdateStockThreshold(id int, threshold models.StockThreshold) error
+	DelStockThreshold(id int) error
+	GetLowStockBooks() ([]models.LowStockBook, error)
+	ClaimLowStockAlerts() ([]models.LowStockBook, error)
+	ReleaseLowStockAlert(bookID int) error
+	GetWebhooks() ([]models.Webhook, error)
+	GetWebhookById(id int) (models.Webhook, error)
+	AddWebhook(webhook models.Webhook) (int, error)
+	UpdateWebhook(id int, webhook models.Webhook) error
+	DelWebhook(id int) error
+	ClaimDeliveries(limit int, lease time.Duration) ([]models.Delivery, error)
+	RecordDeliveryAttempt(id int64, attempt models.DeliveryAttempt) error
+	GetDeadDeliveries(limit int) ([]models.Delivery, error)
+	RetryDelivery(id int64) error
+	ClaimIdempotencyKey(key, requestHash string, ttl time.Duration) (models.IdempotentResponse, bool, error)
+	SaveIdempotentResponse(key string, response models.IdempotentResponse) error
+	ReleaseIdempotencyKey(key string) error
 }
 
 type DatabasePostgres struct {
 	Conn *sql.DB
 }
 
+func currencyPrice(price models.Decimal, currency string) (models.Decimal, error) {
+	if exponent, ok := models.CurrencyExponent(currency); ok {
+		return price.Rescale(exponent)
+	}
+	return price, nil
+}
+
+// ConnectionString returns the lib/pq connection string for the database.
+func ConnectionString(username, password, database string) string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", Host, Port, username, password, database)
+}
+
 func Initialize(username, password, database string) (DatabasePostgres, error) {
 	db := DatabasePostgres{}
-	connectionString := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", Host, Port, username, password, database)
-	conn, err := sql.Open("postgres", connectionString)
+	conn, err := sql.Open("postgres", ConnectionString(username, password, database))
 
 	if err != nil {
 		return db, err
@@ -47,6 +107,16 @@ func Initialize(username, password, database string) (DatabasePostgres, error) {
 }
 
 func (db *DatabasePostgres) GetAllBooks(filter map[string][]string) ([]models.Book, error) {
+	return db.GetAllBooksFields(filter, nil)
+}
+
+// GetAllBooksFields is GetAllBooks selecting only the given columns of
+// bookColumns, the other fields of the books are left zero.
+func (db *DatabasePostgres) GetAllBooksFields(filter map[string][]string, columns []string) ([]models.Book, error) {
+	projection, err := projectBook(columns)
+	if err != nil {
+		return []models.Book{}, err
+	}
 
 	tx, err := db.Conn.Begin()
 	if err != nil {
@@ -70,17 +140,17 @@ func (db *DatabasePostgres) GetAllBooks(filter map[string][]string) ([]models.Bo
 		_, hasName := filter["name"]
 		_, hasGenre := filter["genre"]
 		if hasName && hasGenre {
-			query = "select * from books where genre=$1 and name=$2 and amount>0 order by id desc;"
+			query = "select " + projection.String() + " from books where genre=$1 and name=$2 and amount>0 and deleted_at is null order by id desc;"
 			genre, err := strconv.Atoi(filter["genre"][0])
 			if err != nil {
 				return list, err
 			}
 			queryinfo = append(queryinfo, genre, filter["name"][0])
 		} else if hasName {
-			query = "select * from books where name=$1 and amount>0 order by id desc;"
+			query = "select " + projection.String() + " from books where name=$1 and amount>0 and deleted_at is null order by id desc;"
 			queryinfo = append(queryinfo, filter["name"][0])
 		} else if hasGenre {
-			query = "select * from books where genre=$1 and amount>0 order by id desc;"
+			query = "select " + projection.String() + " from books where genre=$1 and amount>0 and deleted_at is null order by id desc;"
 			genre, err := strconv.Atoi(filter["genre"][0])
 			if err != nil {
 				return list, err
@@ -88,7 +158,7 @@ func (db *DatabasePostgres) GetAllBooks(filter map[string][]string) ([]models.Bo
 			queryinfo = append(queryinfo, genre)
 		}
 	} else {
-		query = "select * from books where amount>0 order by id desc;"
+		query = "select " + projection.String() + " from books where amount>0 and deleted_at is null order by id desc;"
 	}
 	rows, err = tx.Query(query, queryinfo...)
 	if err != nil {
@@ -98,7 +168,7 @@ func (db *DatabasePostgres) GetAllBooks(filter map[string][]string) ([]models.Bo
 	if rows != nil {
 		for rows.Next() {
 			var book models.Book
-			err := rows.Scan(&book.ID, &book.Name, &book.Price, &book.Genre, &book.Amount)
+			err := projection.scan(rows, &book)
 			if err != nil {
 				return list, err
 			}
@@ -107,7 +177,51 @@ func (db *DatabasePostgres) GetAllBooks(filter map[string][]string) ([]models.Bo
 	}
 	return list, err
 }
-func (db *DatabasePostgres) AddBook(book models.Book) (int, error) {
+
+// GetBooksPage returns up to limit books of GetAllBooks, newest first,
+// starting after the book with the ID after. An after of 0 starts at the
+// newest book.
+func (db *DatabasePostgres) GetBooksPage(filter map[string][]string, after int, limit int) ([]models.Book, error) {
+	list := []models.Book{}
+	conditions := []string{"amount>0", "deleted_at is null"}
+	var args []interface{}
+	if genre, ok := filter["genre"]; ok {
+		id, err := strconv.Atoi(genre[0])
+		if err != nil {
+			return list, err
+		}
+		args = append(args, id)
+		conditions = append(conditions, fmt.Sprintf("genre=$%d", len(args)))
+	}
+	if name, ok := filter["name"]; ok {
+		args = append(args, name[0])
+		conditions = append(conditions, fmt.Sprintf("name=$%d", len(args)))
+	}
+	if after != 0 {
+		args = append(args, after)
+		conditions = append(conditions, fmt.Sprintf("id<$%d", len(args)))
+	}
+	args = append(args, limit)
+	query := fmt.Sprintf("select %s from books where %s order by id desc limit $%d;",
+		bookColumns, strings.Join(conditions, " and "), len(args))
+
+	rows, err := db.Conn.Query(query, args...)
+	if err != nil {
+		return list, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var book models.Book
+		if err := bookColumns.scan(rows, &book); err != nil {
+			return list, err
+		}
+		list = append(list, book)
+	}
+	return list, rows.Err()
+}
+
+func (db *DatabasePostgres) AddBook(book models.Book, info models.AuditInfo) (int, error) {
 	tx, err := db.Conn.Begin()
 	if err != nil {
 		return 0, err
@@ -123,15 +237,28 @@ func (db *DatabasePostgres) AddBook(book models.Book) (int, error) {
 	}()
 
 	var id int
-	query := "insert into books (name,price,genre,amount) values ($1, $2, $3, $4) returning id;"
-	err = tx.QueryRow(query, book.Name, book.Price, book.Genre, book.Amount).Scan(&id)
+	query := "insert into books (name,price,currency,genre,amount,authors) values ($1, $2, $3, $4, $5, coalesce($6, '{}'::text[])) returning id;"
+	err = tx.QueryRow(query, book.Name, book.Price, book.Currency, book.Genre, book.Amount, pq.Array(book.Authors)).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	book.ID = id
+	err = writeAudit(tx, id, models.AuditActionCreate, info, nil, book)
+	if err != nil {
+		return 0, err
+	}
+	err = writePriceChange(tx, nil, book, info)
+	if err != nil {
+		return 0, err
+	}
+	err = writeEvent(tx, models.EventBookCreated, book)
 	if err != nil {
 		return 0, err
 	}
 	return id, nil
 }
 
-func (db *DatabasePostgres) DelBook(id int) error {
+func (db *DatabasePostgres) DelBook(id int, info models.AuditInfo) error {
 	tx, err := db.Conn.Begin()
 	if err != nil {
 		return err
@@ -146,22 +273,21 @@ func (db *DatabasePostgres) DelBook(id int) error {
 		}
 	}()
 
-	query := "delete from books where id =$1;"
-	res, err := tx.Exec(query, id)
+	var before models.Book
+	query := "update books set deleted_at=now() where id =$1 and deleted_at is null returning " + bookSummary.String() + ";"
+	err = bookSummary.scan(tx.QueryRow(query, id), &before)
 	if err != nil {
 		return err
 	}
-	count, err := res.RowsAffected()
+	err = writeAudit(tx, id, models.AuditActionDelete, info, before, nil)
 	if err != nil {
 		return err
 	}
-	if count == 0 {
-		return sql.ErrNoRows
-	}
+	err = writeEvent(tx, models.EventBookDeleted, before)
 	return err
 }
 
-func (db *DatabasePostgres) UpdateBook(id int, book models.Book) error {
+func (db *DatabasePostgres) UpdateBook(id int, book models.Book, info models.AuditInfo) error {
 	tx, err := db.Conn.Begin()
 	if err != nil {
 		return err
@@ -175,8 +301,16 @@ func (db *DatabasePostgres) UpdateBook(id int, book models.Book) error {
 			err = tx.Rollback()
 		}
 	}()
-	query := "update books set name=$1, price=$2, genre=$3, amount=$4 where id=$5;"
-	res, err := tx.Exec(query, book.Name, book.Price, book.Genre, book.Amount, id)
+
+	var before models.Book
+	query := "select " + bookSummary.String() + " from books where id =$1 and deleted_at is null for update;"
+	err = bookSummary.scan(tx.QueryRow(query, id), &before)
+	if err != nil {
+		return err
+	}
+
+	query = "update books set name=$1, price=$2, currency=$3, genre=$4, amount=$5, authors=coalesce($6, authors) where id=$7 and deleted_at is null;"
+	res, err := tx.Exec(query, book.Name, book.Price, book.Currency, book.Genre, book.Amount, pq.Array(book.Authors), id)
 	if err != nil {
 		return err
 	}
@@ -187,10 +321,31 @@ func (db *DatabasePostgres) UpdateBook(id int, book models.Book) error {
 	if aff == 0 {
 		return sql.ErrNoRows
 	}
+	book.ID = id
+	err = writeAudit(tx, id, models.AuditActionUpdate, info, before, book)
+	if err != nil {
+		return err
+	}
+	err = writePriceChange(tx, &before, book, info)
+	if err != nil {
+		return err
+	}
+	err = writeEvent(tx, models.EventBookUpdated, book)
 	return err
 }
 
 func (db *DatabasePostgres) GetBookById(id int) (models.Book, error) {
+	return db.GetBookByIdFields(id, nil)
+}
+
+// GetBookByIdFields is GetBookById selecting only the given columns of
+// bookColumns, the other fields of the book are left zero.
+func (db *DatabasePostgres) GetBookByIdFields(id int, columns []string) (models.Book, error) {
+	projection, err := projectBook(columns)
+	if err != nil {
+		return models.Book{}, err
+	}
+
 	tx, err := db.Conn.Begin()
 	if err != nil {
 		return models.Book{}, err
@@ -206,8 +361,101 @@ func (db *DatabasePostgres) GetBookById(id int) (models.Book, error) {
 	}()
 
 	var book models.Book
-	query := "select * from books where id =$1;"
-	row := tx.QueryRow(query, id)
-	err = row.Scan(&book.ID, &book.Name, &book.Price, &book.Genre, &book.Amount)
+	query := "select " + projection.String() + " from books where id =$1 and deleted_at is null;"
+	err = projection.scan(tx.QueryRow(query, id), &book)
 	return book, err
 }
+
+// GetCatalogModified returns when the books or their effective prices last
+// changed: the latest write to books or promotions, or the latest promotion
+// start or end that has passed.
+func (db *DatabasePostgres) GetCatalogModified() (time.Time, error) {
+	var modified time.Time
+	query := "select greatest((select max(changed_at) from catalog_changes), " +
+		"(select max(case when ends_at<=now() then ends_at else starts_at end) from promotions where starts_at<=now()), " +
+		"'epoch'::timestamptz);"
+	err := db.Conn.QueryRow(query).Scan(&modified)
+	return modified, err
+}
+
+// RestoreBook brings back a soft-deleted book. Restoring fails with a unique
+// violation if an active book has taken the name in the meantime.
+func (db *DatabasePostgres) RestoreBook(id int, info models.AuditInfo) (models.Book, error) {
+	tx, err := db.Conn.Begin()
+	if err != nil {
+		return models.Book{}, err
+	}
+
+	defer func() {
+		switch err {
+		case nil:
+			err = tx.Commit()
+		default:
+			err = tx.Rollback()
+		}
+	}()
+
+	var book models.Book
+	query := "update books set deleted_at=null where id =$1 and deleted_at is not null returning " + bookColumns.String() + ";"
+	err = bookColumns.scan(tx.QueryRow(query, id), &book)
+	if err != nil {
+		return models.Book{}, err
+	}
+	err = writeAudit(tx, id, models.AuditActionRestore, info, nil, book)
+	if err != nil {
+		return models.Book{}, err
+	}
+	return book, nil
+}
+
+// PurgeBooks permanently removes books soft-deleted before the given time,
+// a zero time purges every soft-deleted book. It returns the number of purged books.
+func (db *DatabasePostgres) PurgeBooks(before time.Time, info models.AuditInfo) (int, error) {
+	tx, err := db.Conn.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	defer func() {
+		switch err {
+		case nil:
+			err = tx.Commit()
+		default:
+			err = tx.Rollback()
+		}
+	}()
+
+	query := "delete from books where deleted_at is not null returning " + bookSummary.String() + ";"
+	var queryinfo []interface{}
+	if !before.IsZero() {
+		query = "delete from books where deleted_at is not null and deleted_at<$1 returning " + bookSummary.String() + ";"
+		queryinfo = append(queryinfo, before)
+	}
+	rows, err := tx.Query(query, queryinfo...)
+	if err != nil {
+		return 0, err
+	}
+
+	var purged []models.Book
+	for rows.Next() {
+		var book models.Book
+		err = bookSummary.scan(rows, &book)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		purged = append(purged, book)
+	}
+	err = rows.Err()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, book := range purged {
+		err = writeAudit(tx, book.ID, models.AuditActionPurge, info, book, nil)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(purged), nil
+}
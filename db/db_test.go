@@ -5,10 +5,14 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/porky256/rest-api/models"
 	"github.com/stretchr/testify/assert"
+	"regexp"
 	"strconv"
 	"testing"
+	"time"
 )
 
+var bookCreated = time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)
+
 func TestDatabasePostgres_AddBook(t *testing.T) {
 	conn, mock, err := sqlmock.New()
 	if err != nil {
@@ -25,13 +29,23 @@ func TestDatabasePostgres_AddBook(t *testing.T) {
 	}{
 		{
 			name:      "OK",
-			inputBook: models.Book{Name: "OK", Price: 1, Genre: 1, Amount: 1},
+			inputBook: models.Book{Name: "OK", Authors: []string{"Ann Author"}, Price: models.MustParseDecimal("1.00"), Currency: "USD", Genre: 1, Amount: 1},
 			returnId:  1,
 			mockBehavior: func(mock sqlmock.Sqlmock, id int, book models.Book) {
 				mock.ExpectBegin()
 				mock.ExpectQuery("insert into books").
-					WithArgs(book.Name, book.Price, book.Genre, book.Amount).
+					WithArgs(book.Name, book.Price, book.Currency, book.Genre, book.Amount, `{"Ann Author"}`).
 					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(id))
+				mock.ExpectExec("insert into audit_log").
+					WithArgs(id, models.AuditActionCreate, "tester", "1", nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("insert into price_history").
+					WithArgs(id, nil, nil, book.Price, book.Currency, "tester", "1").
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("insert into outbox (.+) insert into webhook_deliveries").
+					WithArgs(models.EventBookCreated, id,
+						[]byte(`{"id":1,"name":"OK","price":"1.00","currency":"USD","genre":1,"amount":1}`)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
 				mock.ExpectCommit()
 			},
 		},
@@ -39,11 +53,11 @@ func TestDatabasePostgres_AddBook(t *testing.T) {
 			name:      "Missing name",
 			returnId:  0,
 			returnErr: true,
-			inputBook: models.Book{Price: 1, Genre: 1, Amount: 1},
+			inputBook: models.Book{Price: models.MustParseDecimal("1.00"), Currency: "USD", Genre: 1, Amount: 1},
 			mockBehavior: func(mock sqlmock.Sqlmock, id int, book models.Book) {
 				mock.ExpectBegin()
 				mock.ExpectQuery("insert into books").
-					WithArgs(book.Name, book.Price, book.Genre, book.Amount).
+					WithArgs(book.Name, book.Price, book.Currency, book.Genre, book.Amount, nil).
 					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(id).RowError(0, errors.New("input error")))
 				mock.ExpectRollback()
 			},
@@ -53,7 +67,7 @@ func TestDatabasePostgres_AddBook(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			test.mockBehavior(mock, test.returnId, test.inputBook)
 
-			id, err := db.AddBook(test.inputBook)
+			id, err := db.AddBook(test.inputBook, models.AuditInfo{Actor: "tester", RequestID: "1"})
 			if test.returnErr {
 				assert.Error(t, err)
 			} else {
@@ -83,15 +97,15 @@ func TestDatabasePostgres_GetAllBooks(t *testing.T) {
 			name:   "OK",
 			filter: map[string][]string{},
 			returnBooks: []models.Book{
-				{ID: 1, Name: "book1", Price: 1, Genre: 1, Amount: 1},
-				{ID: 2, Name: "book2", Price: 1, Genre: 2, Amount: 1},
+				{ID: 1, Name: "book1", Price: models.MustParseDecimal("1.00"), Currency: "USD", Genre: 1, Amount: 1, Authors: []string{}, CreatedAt: bookCreated, UpdatedAt: bookCreated},
+				{ID: 2, Name: "book2", Price: models.MustParseDecimal("1.00"), Currency: "USD", Genre: 2, Amount: 1, Authors: []string{}, CreatedAt: bookCreated, UpdatedAt: bookCreated},
 			},
 			mockBehavior: func(mock sqlmock.Sqlmock, filter map[string][]string) {
 				mock.ExpectBegin()
 				mock.ExpectQuery("select").
-					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "genre", "amount"}).
-						AddRow(1, "book1", 1, 1, 1).
-						AddRow(2, "book2", 1, 2, 1))
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "currency", "genre", "amount", "authors", "created_at", "updated_at"}).
+						AddRow(1, "book1", 1, "USD", 1, 1, "{}", bookCreated, bookCreated).
+						AddRow(2, "book2", 1, "USD", 2, 1, "{}", bookCreated, bookCreated))
 				mock.ExpectCommit()
 			},
 		},
@@ -99,15 +113,15 @@ func TestDatabasePostgres_GetAllBooks(t *testing.T) {
 			name:   "Ok with filter",
 			filter: map[string][]string{"genre": {"1"}},
 			returnBooks: []models.Book{
-				{ID: 1, Name: "book1", Price: 1, Genre: 1, Amount: 1},
+				{ID: 1, Name: "book1", Price: models.MustParseDecimal("1.00"), Currency: "USD", Genre: 1, Amount: 1, Authors: []string{}, CreatedAt: bookCreated, UpdatedAt: bookCreated},
 			},
 			mockBehavior: func(mock sqlmock.Sqlmock, filter map[string][]string) {
 				mock.ExpectBegin()
 				genre, _ := strconv.Atoi(filter["genre"][0])
 				mock.ExpectQuery("select").
 					WithArgs(genre).
-					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "genre", "amount"}).
-						AddRow(1, "book1", 1, 1, 1))
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "currency", "genre", "amount", "authors", "created_at", "updated_at"}).
+						AddRow(1, "book1", 1, "USD", 1, 1, "{}", bookCreated, bookCreated))
 				mock.ExpectCommit()
 			},
 		},
@@ -120,7 +134,7 @@ func TestDatabasePostgres_GetAllBooks(t *testing.T) {
 				genre, _ := strconv.Atoi(filter["genre"][0])
 				mock.ExpectQuery("select").
 					WithArgs(genre).
-					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "genre", "amount"}))
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "currency", "genre", "amount", "authors", "created_at", "updated_at"}))
 				mock.ExpectCommit()
 			},
 		},
@@ -157,14 +171,14 @@ func TestDatabasePostgres_GetBookById(t *testing.T) {
 	}{
 		{
 			name:       "OK",
-			returnBook: models.Book{ID: 1, Name: "OK", Price: 1, Genre: 1, Amount: 1},
+			returnBook: models.Book{ID: 1, Name: "OK", Price: models.MustParseDecimal("1.00"), Currency: "USD", Genre: 1, Amount: 1, Authors: []string{}, CreatedAt: bookCreated, UpdatedAt: bookCreated},
 			inputId:    1,
 			mockBehavior: func(mock sqlmock.Sqlmock, id int) {
 				mock.ExpectBegin()
 				mock.ExpectQuery("select").
 					WithArgs(id).
-					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "genre", "amount"}).
-						AddRow(1, "OK", 1, 1, 1))
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "currency", "genre", "amount", "authors", "created_at", "updated_at"}).
+						AddRow(1, "OK", 1, "USD", 1, 1, "{}", bookCreated, bookCreated))
 				mock.ExpectCommit()
 			},
 		},
@@ -176,8 +190,8 @@ func TestDatabasePostgres_GetBookById(t *testing.T) {
 				mock.ExpectBegin()
 				mock.ExpectQuery("select").
 					WithArgs(id).
-					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "genre", "amount"}).
-						AddRow(0, "", 0, 0, 0).RowError(0, errors.New("no such id")))
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "currency", "genre", "amount", "authors", "created_at", "updated_at"}).
+						AddRow(0, "", 0, "USD", 0, 0, "{}", bookCreated, bookCreated).RowError(0, errors.New("no such id")))
 				mock.ExpectRollback()
 			},
 		},
@@ -198,6 +212,129 @@ func TestDatabasePostgres_GetBookById(t *testing.T) {
 	}
 }
 
+func TestDatabasePostgres_GetAllBooksFields(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error in creating mock: %s", err)
+	}
+	db := DatabasePostgres{Conn: conn}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("select id, name, price, currency from books where name=\\$1 and amount>0").
+		WithArgs("book1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "currency"}).AddRow(1, "book1", 1, "USD"))
+	mock.ExpectCommit()
+	books, err := db.GetAllBooksFields(map[string][]string{"name": {"book1"}}, []string{"price", "name", "id"})
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Book{{ID: 1, Name: "book1", Price: models.MustParseDecimal("1.00"), Currency: "USD"}}, books)
+
+	_, err = db.GetAllBooksFields(map[string][]string{}, []string{"deleted_at"})
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDatabasePostgres_GetBooksPage(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error in creating mock: %s", err)
+	}
+	db := DatabasePostgres{Conn: conn}
+	columns := []string{"id", "name", "price", "currency", "genre", "amount", "authors", "created_at", "updated_at"}
+	tests := []struct {
+		name         string
+		filter       map[string][]string
+		after        int
+		mockBehavior func(mock sqlmock.Sqlmock)
+		returnBooks  []models.Book
+		returnErr    bool
+	}{
+		{
+			name:   "First page",
+			filter: map[string][]string{},
+			mockBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("from books where amount>0 and deleted_at is null order by id desc limit $1;")).
+					WithArgs(2).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(3, "book3", 1, "USD", 1, 1, "{}", bookCreated, bookCreated).
+						AddRow(2, "book2", 1, "USD", 2, 1, "{}", bookCreated, bookCreated))
+			},
+			returnBooks: []models.Book{
+				{ID: 3, Name: "book3", Price: models.MustParseDecimal("1.00"), Currency: "USD", Genre: 1, Amount: 1, Authors: []string{}, CreatedAt: bookCreated, UpdatedAt: bookCreated},
+				{ID: 2, Name: "book2", Price: models.MustParseDecimal("1.00"), Currency: "USD", Genre: 2, Amount: 1, Authors: []string{}, CreatedAt: bookCreated, UpdatedAt: bookCreated},
+			},
+		},
+		{
+			name:   "Next page with filter",
+			filter: map[string][]string{"genre": {"1"}, "name": {"book1"}},
+			after:  2,
+			mockBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("where amount>0 and deleted_at is null and genre=$1 and name=$2 and id<$3 order by id desc limit $4;")).
+					WithArgs(1, "book1", 2, 2).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(1, "book1", 1, "USD", 1, 1, "{}", bookCreated, bookCreated))
+			},
+			returnBooks: []models.Book{
+				{ID: 1, Name: "book1", Price: models.MustParseDecimal("1.00"), Currency: "USD", Genre: 1, Amount: 1, Authors: []string{}, CreatedAt: bookCreated, UpdatedAt: bookCreated},
+			},
+		},
+		{
+			name:         "Invalid genre",
+			filter:       map[string][]string{"genre": {"fantasy"}},
+			mockBehavior: func(mock sqlmock.Sqlmock) {},
+			returnBooks:  []models.Book{},
+			returnErr:    true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.mockBehavior(mock)
+			books, err := db.GetBooksPage(test.filter, test.after, 2)
+			if test.returnErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, test.returnBooks, books)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestDatabasePostgres_GetBookByIdFields(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error in creating mock: %s", err)
+	}
+	db := DatabasePostgres{Conn: conn}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("select genre, authors from books where id =\\$1").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"genre", "authors"}).AddRow(2, `{"Ann Author"}`))
+	mock.ExpectCommit()
+	book, err := db.GetBookByIdFields(1, []string{"authors", "genre"})
+	assert.NoError(t, err)
+	assert.Equal(t, models.Book{Genre: 2, Authors: []string{"Ann Author"}}, book)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDatabasePostgres_GetCatalogModified(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error in creating mock: %s", err)
+	}
+	db := DatabasePostgres{Conn: conn}
+	modified := time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("select greatest\\(\\(select max\\(changed_at\\) from catalog_changes\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"greatest"}).AddRow(modified))
+
+	result, err := db.GetCatalogModified()
+	assert.NoError(t, err)
+	assert.Equal(t, modified, result)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestDatabasePostgres_DelBook(t *testing.T) {
 	conn, mock, err := sqlmock.New()
 	if err != nil {
@@ -216,8 +353,17 @@ func TestDatabasePostgres_DelBook(t *testing.T) {
 			inputId: 1,
 			mockBehavior: func(mock sqlmock.Sqlmock, id int) {
 				mock.ExpectBegin()
-				mock.ExpectExec("delete").
-					WithArgs(id).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectQuery("delete").
+					WithArgs(id).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "currency", "genre", "amount"}).
+						AddRow(id, "OK", 1, "USD", 1, 1))
+				mock.ExpectExec("insert into audit_log").
+					WithArgs(id, models.AuditActionDelete, "tester", "1", sqlmock.AnyArg(), nil, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("insert into outbox").
+					WithArgs(models.EventBookDeleted, id,
+						[]byte(`{"id":1,"name":"OK","price":"1.00","currency":"USD","genre":1,"amount":1}`)).
+					WillReturnResult(sqlmock.NewResult(0, 0))
 				mock.ExpectCommit()
 			},
 		},
@@ -227,10 +373,10 @@ func TestDatabasePostgres_DelBook(t *testing.T) {
 			returnErr: true,
 			mockBehavior: func(mock sqlmock.Sqlmock, id int) {
 				mock.ExpectBegin()
-				mock.ExpectExec("delete").
+				mock.ExpectQuery("delete").
 					WithArgs(id).
-					WillReturnResult(sqlmock.NewResult(1, 0))
-				mock.ExpectCommit()
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "currency", "genre", "amount"}))
+				mock.ExpectRollback()
 			},
 		},
 	}
@@ -238,7 +384,7 @@ func TestDatabasePostgres_DelBook(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			test.mockBehavior(mock, test.inputId)
 
-			err := db.DelBook(test.inputId)
+			err := db.DelBook(test.inputId, models.AuditInfo{Actor: "tester", RequestID: "1"})
 			if test.returnErr {
 				assert.Error(t, err)
 			}
@@ -263,13 +409,48 @@ func TestDatabasePostgres_UpdateBook(t *testing.T) {
 	}{
 		{
 			name:      "OK",
-			inputBook: models.Book{ID: 1, Name: "OK", Price: 1, Genre: 1, Amount: 1},
+			inputBook: models.Book{ID: 1, Name: "OK", Price: models.MustParseDecimal("1.00"), Currency: "USD", Genre: 1, Amount: 1},
 			inputId:   1,
 			mockBehavior: func(mock sqlmock.Sqlmock, id int, book models.Book) {
 				mock.ExpectBegin()
+				mock.ExpectQuery("select").
+					WithArgs(id).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "currency", "genre", "amount"}).
+						AddRow(id, "Old", 2, "USD", 1, 1))
 				mock.ExpectExec("update books").
-					WithArgs(book.Name, book.Price, book.Genre, book.Amount, id).
+					WithArgs(book.Name, book.Price, book.Currency, book.Genre, book.Amount, nil, id).
 					WillReturnResult(sqlmock.NewResult(int64(id), 1))
+				mock.ExpectExec("insert into audit_log").
+					WithArgs(id, models.AuditActionUpdate, "tester", "1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("insert into price_history").
+					WithArgs(id, models.MustParseDecimal("2.00"), "USD", book.Price, book.Currency, "tester", "1").
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("insert into outbox").
+					WithArgs(models.EventBookUpdated, id, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name:      "Price unchanged",
+			inputBook: models.Book{ID: 1, Name: "New", Price: models.MustParseDecimal("2"), Currency: "USD", Genre: 1, Amount: 5},
+			inputId:   1,
+			mockBehavior: func(mock sqlmock.Sqlmock, id int, book models.Book) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("select").
+					WithArgs(id).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "currency", "genre", "amount"}).
+						AddRow(id, "Old", "2.0000", "USD", 1, 1))
+				mock.ExpectExec("update books").
+					WithArgs(book.Name, book.Price, book.Currency, book.Genre, book.Amount, nil, id).
+					WillReturnResult(sqlmock.NewResult(int64(id), 1))
+				mock.ExpectExec("insert into audit_log").
+					WithArgs(id, models.AuditActionUpdate, "tester", "1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("insert into outbox").
+					WithArgs(models.EventBookUpdated, id, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
 				mock.ExpectCommit()
 			},
 		},
@@ -277,13 +458,13 @@ func TestDatabasePostgres_UpdateBook(t *testing.T) {
 			name:      "No such id",
 			inputId:   1422,
 			returnErr: true,
-			inputBook: models.Book{ID: 1422, Name: "OK", Price: 1, Genre: 1, Amount: 1},
+			inputBook: models.Book{ID: 1422, Name: "OK", Price: models.MustParseDecimal("1.00"), Currency: "USD", Genre: 1, Amount: 1},
 			mockBehavior: func(mock sqlmock.Sqlmock, id int, book models.Book) {
 				mock.ExpectBegin()
-				mock.ExpectExec("update books").
-					WithArgs(book.Name, book.Price, book.Genre, book.Amount, id).
-					WillReturnResult(sqlmock.NewResult(int64(id), 0))
-				mock.ExpectCommit()
+				mock.ExpectQuery("select").
+					WithArgs(id).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "currency", "genre", "amount"}))
+				mock.ExpectRollback()
 			},
 		},
 		{
@@ -292,8 +473,12 @@ func TestDatabasePostgres_UpdateBook(t *testing.T) {
 			returnErr: true,
 			mockBehavior: func(mock sqlmock.Sqlmock, id int, book models.Book) {
 				mock.ExpectBegin()
+				mock.ExpectQuery("select").
+					WithArgs(id).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "currency", "genre", "amount"}).
+						AddRow(id, "Old", 2, "USD", 1, 1))
 				mock.ExpectExec("update books set").
-					WithArgs(book.Name, book.Price, book.Genre, book.Amount, id).
+					WithArgs(book.Name, book.Price, book.Currency, book.Genre, book.Amount, nil, id).
 					WillReturnResult(sqlmock.NewResult(int64(id), 0)).
 					WillReturnError(errors.New("duplicate name"))
 				mock.ExpectRollback()
@@ -304,7 +489,7 @@ func TestDatabasePostgres_UpdateBook(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			test.mockBehavior(mock, test.inputId, test.inputBook)
 
-			err := db.UpdateBook(test.inputId, test.inputBook)
+			err := db.UpdateBook(test.inputId, test.inputBook, models.AuditInfo{Actor: "tester", RequestID: "1"})
 			if test.returnErr {
 				assert.Error(t, err)
 			}
@@ -312,3 +497,133 @@ func TestDatabasePostgres_UpdateBook(t *testing.T) {
 		})
 	}
 }
+
+func TestDatabasePostgres_RestoreBook(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error in creating mock: %s", err)
+	}
+	db := DatabasePostgres{Conn: conn}
+	type MockBehavior func(mock sqlmock.Sqlmock, id int)
+	tests := []struct {
+		name         string
+		inputId      int
+		returnBook   models.Book
+		mockBehavior MockBehavior
+		returnErr    bool
+	}{
+		{
+			name:       "OK",
+			inputId:    1,
+			returnBook: models.Book{ID: 1, Name: "OK", Price: models.MustParseDecimal("1.00"), Currency: "USD", Genre: 1, Amount: 1, Authors: []string{}, CreatedAt: bookCreated, UpdatedAt: bookCreated},
+			mockBehavior: func(mock sqlmock.Sqlmock, id int) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("update books set deleted_at=null").
+					WithArgs(id).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "currency", "genre", "amount", "authors", "created_at", "updated_at"}).
+						AddRow(id, "OK", 1, "USD", 1, 1, "{}", bookCreated, bookCreated))
+				mock.ExpectExec("insert into audit_log").
+					WithArgs(id, models.AuditActionRestore, "tester", "1", nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name:      "Not deleted",
+			inputId:   1422,
+			returnErr: true,
+			mockBehavior: func(mock sqlmock.Sqlmock, id int) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("update books set deleted_at=null").
+					WithArgs(id).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "currency", "genre", "amount", "authors", "created_at", "updated_at"}))
+				mock.ExpectRollback()
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.mockBehavior(mock, test.inputId)
+
+			book, err := db.RestoreBook(test.inputId, models.AuditInfo{Actor: "tester", RequestID: "1"})
+			if test.returnErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.returnBook, book)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestDatabasePostgres_PurgeBooks(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error in creating mock: %s", err)
+	}
+	db := DatabasePostgres{Conn: conn}
+	type MockBehavior func(mock sqlmock.Sqlmock, before time.Time)
+	tests := []struct {
+		name         string
+		before       time.Time
+		returnCount  int
+		mockBehavior MockBehavior
+		returnErr    bool
+	}{
+		{
+			name:        "OK",
+			returnCount: 2,
+			mockBehavior: func(mock sqlmock.Sqlmock, before time.Time) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("delete from books where deleted_at is not null returning").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "currency", "genre", "amount"}).
+						AddRow(1, "book1", 1, "USD", 1, 1).
+						AddRow(2, "book2", 1, "USD", 2, 1))
+				mock.ExpectExec("insert into audit_log").
+					WithArgs(1, models.AuditActionPurge, "tester", "1", sqlmock.AnyArg(), nil, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("insert into audit_log").
+					WithArgs(2, models.AuditActionPurge, "tester", "1", sqlmock.AnyArg(), nil, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(2, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name:        "OK with time",
+			before:      time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC),
+			returnCount: 0,
+			mockBehavior: func(mock sqlmock.Sqlmock, before time.Time) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("delete from books where deleted_at is not null and deleted_at<\\$1").
+					WithArgs(before).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "currency", "genre", "amount"}))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name:      "Query error",
+			returnErr: true,
+			mockBehavior: func(mock sqlmock.Sqlmock, before time.Time) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("delete from books").
+					WillReturnError(errors.New("query error"))
+				mock.ExpectRollback()
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.mockBehavior(mock, test.before)
+
+			count, err := db.PurgeBooks(test.before, models.AuditInfo{Actor: "tester", RequestID: "1"})
+			if test.returnErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.returnCount, count)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package db
+
+import (
+	"github.com/lib/pq"
+	"github.com/porky256/rest-api/models"
+)
+
+// GetGenres returns the genres with the given IDs in one query, or every
+// genre when ids is empty.
+func (db *DatabasePostgres) GetGenres(ids []int) ([]models.Genre, error) {
+	list := []models.Genre{}
+	query := "select id, name from genres order by id;"
+	var args []interface{}
+	if len(ids) != 0 {
+		query = "select id, name from genres where id = any($1) order by id;"
+		keys := make([]int64, 0, len(ids))
+		for _, id := range ids {
+			keys = append(keys, int64(id))
+		}
+		args = append(args, pq.Array(keys))
+	}
+	rows, err := db.Conn.Query(query, args...)
+	if err != nil {
+		return list, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var genre models.Genre
+		err = rows.Scan(&genre.ID, &genre.Name)
+		if err != nil {
+			return list, err
+		}
+		list = append(list, genre)
+	}
+	return list, rows.Err()
+}
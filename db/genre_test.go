@@ -0,0 +1,31 @@
+package db
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDatabasePostgres_GetGenres(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error in creating mock: %s", err)
+	}
+	db := DatabasePostgres{Conn: conn}
+
+	mock.ExpectQuery(`select id, name from genres where id = any\(\$1\)`).
+		WithArgs(pq.Array([]int64{1, 3})).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Adventure").AddRow(3, "Fantasy"))
+	list, err := db.GetGenres([]int{1, 3})
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Genre{{ID: 1, Name: "Adventure"}, {ID: 3, Name: "Fantasy"}}, list)
+
+	mock.ExpectQuery("select id, name from genres order by id").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(2, "Classics"))
+	list, err = db.GetGenres(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Genre{{ID: 2, Name: "Classics"}}, list)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
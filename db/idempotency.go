@@ -0,0 +1,46 @@
+package db
+
+import (
+	"database/sql"
+	"github.com/porky256/rest-api/models"
+	"time"
+)
+
+// ClaimIdempotencyKey reserves an idempotency key for a request with the
+// given hash until ttl has passed. If the key is already taken it returns
+// false and the stored response, which has no status code while the first
+// request is still being handled. Expired keys are dropped on the way.
+func (db *DatabasePostgres) ClaimIdempotencyKey(key, requestHash string, ttl time.Duration) (models.IdempotentResponse, bool, error) {
+	query := "with expired as (delete from idempotency_keys where expires_at<now() and key<>$1) " +
+		"insert into idempotency_keys (key,request_hash,expires_at) values ($1, $2, now()+make_interval(secs => $3)) " +
+		"on conflict (key) do update set request_hash=excluded.request_hash, status_code=null, content_type=null, body=null, " +
+		"expires_at=excluded.expires_at where idempotency_keys.expires_at<now() returning key;"
+	err := db.Conn.QueryRow(query, key, requestHash, ttl.Seconds()).Scan(&key)
+	if err == nil {
+		return models.IdempotentResponse{RequestHash: requestHash}, true, nil
+	}
+	if err != sql.ErrNoRows {
+		return models.IdempotentResponse{}, false, err
+	}
+
+	var response models.IdempotentResponse
+	query = "select request_hash, coalesce(status_code, 0), coalesce(content_type, ''), body from idempotency_keys where key=$1;"
+	err = db.Conn.QueryRow(query, key).Scan(&response.RequestHash, &response.StatusCode, &response.ContentType, &response.Body)
+	return response, false, err
+}
+
+// SaveIdempotentResponse stores the response to the request that claimed the
+// key, to be replayed until the key expires.
+func (db *DatabasePostgres) SaveIdempotentResponse(key string, response models.IdempotentResponse) error {
+	query := "update idempotency_keys set status_code=$1, content_type=$2, body=$3 where key=$4;"
+	_, err := db.Conn.Exec(query, response.StatusCode, response.ContentType, response.Body, key)
+	return err
+}
+
+// ReleaseIdempotencyKey gives up a claimed key without a stored response, so
+// the request can be tried again with it.
+func (db *DatabasePostgres) ReleaseIdempotencyKey(key string) error {
+	query := "delete from idempotency_keys where key=$1 and status_code is null;"
+	_, err := db.Conn.Exec(query, key)
+	return err
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestDatabasePostgres_ClaimIdempotencyKey(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error in creating mock: %s", err)
+	}
+	db := DatabasePostgres{Conn: conn}
+	tests := []struct {
+		name             string
+		mockBehavior     func()
+		expectedResponse models.IdempotentResponse
+		expectedClaimed  bool
+	}{
+		{
+			name: "new key",
+			mockBehavior: func() {
+				mock.ExpectQuery(`with expired as \(delete from idempotency_keys where expires_at<now\(\) and key<>\$1\) `+
+					`insert into idempotency_keys (.+) on conflict \(key\) do update (.+) where idempotency_keys.expires_at<now\(\) returning key`).
+					WithArgs("key", "hash", float64(3600)).
+					WillReturnRows(sqlmock.NewRows([]string{"key"}).AddRow("key"))
+			},
+			expectedResponse: models.IdempotentResponse{RequestHash: "hash"},
+			expectedClaimed:  true,
+		},
+		{
+			name: "stored response",
+			mockBehavior: func() {
+				mock.ExpectQuery("insert into idempotency_keys").
+					WithArgs("key", "hash", float64(3600)).
+					WillReturnRows(sqlmock.NewRows([]string{"key"}))
+				mock.ExpectQuery(`select request_hash, coalesce\(status_code, 0\), coalesce\(content_type, ''\), body from idempotency_keys where key=\$1`).
+					WithArgs("key").
+					WillReturnRows(sqlmock.NewRows([]string{"request_hash", "status_code", "content_type", "body"}).
+						AddRow("hash", 200, "application/json", []byte(`{"id":1}`)))
+			},
+			expectedResponse: models.IdempotentResponse{RequestHash: "hash", StatusCode: 200, ContentType: "application/json", Body: []byte(`{"id":1}`)},
+		},
+		{
+			name: "in progress",
+			mockBehavior: func() {
+				mock.ExpectQuery("insert into idempotency_keys").
+					WithArgs("key", "hash", float64(3600)).
+					WillReturnRows(sqlmock.NewRows([]string{"key"}))
+				mock.ExpectQuery("select request_hash, (.+) from idempotency_keys where key=\\$1").
+					WithArgs("key").
+					WillReturnRows(sqlmock.NewRows([]string{"request_hash", "status_code", "content_type", "body"}).
+						AddRow("other", 0, "", nil))
+			},
+			expectedResponse: models.IdempotentResponse{RequestHash: "other"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.mockBehavior()
+			response, claimed, err := db.ClaimIdempotencyKey("key", "hash", time.Hour)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedClaimed, claimed)
+			assert.Equal(t, test.expectedResponse, response)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestDatabasePostgres_SaveIdempotentResponse(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error in creating mock: %s", err)
+	}
+	db := DatabasePostgres{Conn: conn}
+
+	mock.ExpectExec(`update idempotency_keys set status_code=\$1, content_type=\$2, body=\$3 where key=\$4`).
+		WithArgs(200, "application/json", []byte(`{"id":1}`), "key").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	err = db.SaveIdempotentResponse("key", models.IdempotentResponse{RequestHash: "hash", StatusCode: 200,
+		ContentType: "application/json", Body: []byte(`{"id":1}`)})
+	assert.NoError(t, err)
+
+	mock.ExpectExec(`delete from idempotency_keys where key=\$1 and status_code is null`).
+		WithArgs("key").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, db.ReleaseIdempotencyKey("key"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
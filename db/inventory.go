@@ -0,0 +1,198 @@
+package db
+
+import (
+	"database/sql"
+	"github.com/lib/pq"
+	"github.com/porky256/rest-api/models"
+)
+
+const thresholdColumns = "id, coalesce(book_id, 0), coalesce(genre, 0), threshold"
+
+// lowStockQuery selects the active books whose amount is below the threshold
+// of the book itself or, failing that, of its genre.
+var lowStockQuery = "select " + bookSummary.qualified("b") + ", coalesce(bt.threshold, gt.threshold) " +
+	"from books b left join stock_thresholds bt on bt.book_id=b.id left join stock_thresholds gt on gt.genre=b.genre " +
+	"where b.deleted_at is null and b.amount<coalesce(bt.threshold, gt.threshold) order by b.amount, b.id;"
+
+// AdjustStock changes the amount of a book in stock by delta, refusing to go
+// below zero, and returns the changed book.
+func (db *DatabasePostgres) AdjustStock(id int, delta int, info models.AuditInfo) (models.Book, error) {
+	tx, err := db.Conn.Begin()
+	if err != nil {
+		return models.Book{}, err
+	}
+
+	defer func() {
+		switch err {
+		case nil:
+			err = tx.Commit()
+		default:
+			err = tx.Rollback()
+		}
+	}()
+
+	_, err = adjustStock(tx, id, delta, info)
+	if err != nil {
+		return models.Book{}, err
+	}
+	var book models.Book
+	query := "select " + bookColumns.String() + " from books where id =$1;"
+	err = bookColumns.scan(tx.QueryRow(query, id), &book)
+	if err != nil {
+		return models.Book{}, err
+	}
+	return book, nil
+}
+
+func (db *DatabasePostgres) GetStockThresholds() ([]models.StockThreshold, error) {
+	list := []models.StockThreshold{}
+	query := "select " + thresholdColumns + " from stock_thresholds order by id;"
+	rows, err := db.Conn.Query(query)
+	if err != nil {
+		return list, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var threshold models.StockThreshold
+		err = rows.Scan(&threshold.ID, &threshold.BookID, &threshold.Genre, &threshold.Threshold)
+		if err != nil {
+			return list, err
+		}
+		list = append(list, threshold)
+	}
+	return list, rows.Err()
+}
+
+func (db *DatabasePostgres) AddStockThreshold(threshold models.StockThreshold) (int, error) {
+	var id int
+	query := "insert into stock_thresholds (book_id,genre,threshold) values (nullif($1, 0), nullif($2, 0), $3) returning id;"
+	err := db.Conn.QueryRow(query, threshold.BookID, threshold.Genre, threshold.Threshold).Scan(&id)
+	return id, err
+}
+
+func (db *DatabasePostgres) UpdateStockThreshold(id int, threshold models.StockThreshold) error {
+	query := "update stock_thresholds set book_id=nullif($1, 0), genre=nullif($2, 0), threshold=$3 where id=$4;"
+	res, err := db.Conn.Exec(query, threshold.BookID, threshold.Genre, threshold.Threshold, id)
+	if err != nil {
+		return err
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if aff == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (db *DatabasePostgres) DelStockThreshold(id int) error {
+	query := "delete from stock_thresholds where id=$1;"
+	res, err := db.Conn.Exec(query, id)
+	if err != nil {
+		return err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (db *DatabasePostgres) GetLowStockBooks() ([]models.LowStockBook, error) {
+	return queryLowStock(db.Conn)
+}
+
+// ClaimLowStockAlerts returns the books that have dropped below their
+// threshold since the last call and forgets the ones that have recovered,
+// so every crossing is reported once no matter which mutation caused it.
+// Concurrent callers never claim the same book twice.
+func (db *DatabasePostgres) ClaimLowStockAlerts() ([]models.LowStockBook, error) {
+	tx, err := db.Conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		switch err {
+		case nil:
+			err = tx.Commit()
+		default:
+			err = tx.Rollback()
+		}
+	}()
+
+	low, err := queryLowStock(tx)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, len(low))
+	for i, book := range low {
+		ids[i] = int64(book.ID)
+	}
+
+	query := "delete from low_stock_alerts where book_id <> all($1);"
+	_, err = tx.Exec(query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	query = "insert into low_stock_alerts (book_id) select unnest($1::int[]) on conflict do nothing returning book_id;"
+	rows, err := tx.Query(query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	claimed := map[int]bool{}
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		claimed[id] = true
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	list := []models.LowStockBook{}
+	for _, book := range low {
+		if claimed[book.ID] {
+			list = append(list, book)
+		}
+	}
+	return list, nil
+}
+
+func queryLowStock(q queryer) ([]models.LowStockBook, error) {
+	list := []models.LowStockBook{}
+	rows, err := q.Query(lowStockQuery)
+	if err != nil {
+		return list, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var book models.LowStockBook
+		err = bookSummary.scan(rows, &book.Book, &book.Threshold)
+		if err != nil {
+			return list, err
+		}
+		list = append(list, book)
+	}
+	return list, rows.Err()
+}
+
+// ReleaseLowStockAlert gives up the claimed alert of a book, so the next
+// claim returns the book again while it is still low on stock.
+func (db *DatabasePostgres) ReleaseLowStockAlert(bookID int) error {
+	query := "delete from low_stock_alerts where book_id=$1;"
+	_, err := db.Conn.Exec(query, bookID)
+	return err
+}
@@ -0,0 +1,187 @@
+package db
+
+import (
+	"database/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var lowStockColumnNames = []string{"id", "name", "price", "currency", "genre", "amount", "threshold"}
+
+func TestDatabasePostgres_GetLowStockBooks(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error in creating mock: %s", err)
+	}
+	db := DatabasePostgres{Conn: conn}
+
+	mock.ExpectQuery("select (.+) from books b left join stock_thresholds bt (.+) where b.deleted_at is null").
+		WillReturnRows(sqlmock.NewRows(lowStockColumnNames).AddRow(1, "Dune", "10.0000", "USD", 3, 1, 5))
+
+	list, err := db.GetLowStockBooks()
+	assert.NoError(t, err)
+	assert.Equal(t, []models.LowStockBook{{Book: models.Book{ID: 1, Name: "Dune", Price: models.MustParseDecimal("10.00"),
+		Currency: "USD", Genre: 3, Amount: 1}, Threshold: 5}}, list)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDatabasePostgres_ClaimLowStockAlerts(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error in creating mock: %s", err)
+	}
+	db := DatabasePostgres{Conn: conn}
+	tests := []struct {
+		name         string
+		mockBehavior func(mock sqlmock.Sqlmock)
+		returnList   []models.LowStockBook
+		returnErr    bool
+	}{
+		{
+			name: "OK",
+			mockBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("select (.+) from books b").
+					WillReturnRows(sqlmock.NewRows(lowStockColumnNames).
+						AddRow(1, "Dune", "10.0000", "USD", 3, 1, 5).
+						AddRow(2, "Emma", "5.0000", "USD", 2, 2, 3))
+				mock.ExpectExec("delete from low_stock_alerts where book_id <> all\\(\\$1\\)").
+					WithArgs(pq.Array([]int64{1, 2})).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery("insert into low_stock_alerts (.+) on conflict do nothing returning book_id").
+					WithArgs(pq.Array([]int64{1, 2})).
+					WillReturnRows(sqlmock.NewRows([]string{"book_id"}).AddRow(2))
+				mock.ExpectCommit()
+			},
+			returnList: []models.LowStockBook{{Book: models.Book{ID: 2, Name: "Emma", Price: models.MustParseDecimal("5.00"),
+				Currency: "USD", Genre: 2, Amount: 2}, Threshold: 3}},
+		},
+		{
+			name: "Nothing low",
+			mockBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("select (.+) from books b").
+					WillReturnRows(sqlmock.NewRows(lowStockColumnNames))
+				mock.ExpectExec("delete from low_stock_alerts").
+					WithArgs(pq.Array([]int64{})).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectQuery("insert into low_stock_alerts").
+					WithArgs(pq.Array([]int64{})).
+					WillReturnRows(sqlmock.NewRows([]string{"book_id"}))
+				mock.ExpectCommit()
+			},
+			returnList: []models.LowStockBook{},
+		},
+		{
+			name: "Error",
+			mockBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("select (.+) from books b").WillReturnError(sql.ErrConnDone)
+				mock.ExpectRollback()
+			},
+			returnErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.mockBehavior(mock)
+
+			list, err := db.ClaimLowStockAlerts()
+			if test.returnErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.returnList, list)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestDatabasePostgres_ReleaseLowStockAlert(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error in creating mock: %s", err)
+	}
+	db := DatabasePostgres{Conn: conn}
+
+	mock.ExpectExec(`delete from low_stock_alerts where book_id=\$1`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, db.ReleaseLowStockAlert(1))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDatabasePostgres_AdjustStock(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error in creating mock: %s", err)
+	}
+	db := DatabasePostgres{Conn: conn}
+	info := models.AuditInfo{Actor: "tester", RequestID: "1"}
+	type MockBehavior func(mock sqlmock.Sqlmock)
+	tests := []struct {
+		name         string
+		delta        int
+		mockBehavior MockBehavior
+		returnBook   models.Book
+		returnErr    error
+	}{
+		{
+			name:  "OK",
+			delta: -2,
+			mockBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("deleted_at is null for update").WithArgs(1).
+					WillReturnRows(sqlmock.NewRows(bookColumnNames).AddRow(1, "book1", 2, "USD", 1, 3))
+				mock.ExpectExec("update books set amount").WithArgs(1, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("insert into audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectQuery("select (.+) from books where id").WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "currency", "genre", "amount", "authors", "created_at", "updated_at"}).
+						AddRow(1, "book1", 2, "USD", 1, 1, "{}", bookCreated, bookCreated))
+				mock.ExpectCommit()
+			},
+			returnBook: models.Book{ID: 1, Name: "book1", Price: models.MustParseDecimal("2.00"), Currency: "USD", Genre: 1, Amount: 1,
+				Authors: []string{}, CreatedAt: bookCreated, UpdatedAt: bookCreated},
+		},
+		{
+			name:  "Insufficient stock",
+			delta: -4,
+			mockBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("deleted_at is null for update").WithArgs(1).
+					WillReturnRows(sqlmock.NewRows(bookColumnNames).AddRow(1, "book1", 2, "USD", 1, 3))
+				mock.ExpectRollback()
+			},
+			returnErr: ErrInsufficientStock,
+		},
+		{
+			name:  "Unknown book",
+			delta: 1,
+			mockBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("deleted_at is null for update").WithArgs(1).
+					WillReturnRows(sqlmock.NewRows(bookColumnNames))
+				mock.ExpectRollback()
+			},
+			returnErr: ErrUnknownBook,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.mockBehavior(mock)
+
+			book, err := db.AdjustStock(1, test.delta, info)
+			if test.returnErr != nil {
+				assert.Equal(t, test.returnErr, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.returnBook, book)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/lib/pq"
+	"github.com/porky256/rest-api/models"
+	"log"
+	"time"
+)
+
+const (
+	// BookEventsChannel is the channel the books trigger notifies on.
+	BookEventsChannel = "book_events"
+
+	minReconnectInterval = 10 * time.Second
+	maxReconnectInterval = time.Minute
+	listenerPingInterval = 90 * time.Second
+)
+
+// bookNotification is the payload sent by the notify_book_change trigger.
+type bookNotification struct {
+	Type string      `json:"type"`
+	Book models.Book `json:"book"`
+}
+
+// ChangeFeed receives the book changes committed by any instance through
+// Postgres LISTEN/NOTIFY.
+type ChangeFeed struct {
+	notify <-chan *pq.Notification
+	ping   func() error
+	close  func() error
+	// Reconnected is called when the connection was lost and restored,
+	// changes made in between were missed.
+	Reconnected func()
+}
+
+// ListenBookEvents opens a dedicated connection listening on BookEventsChannel.
+func ListenBookEvents(connectionString string) (*ChangeFeed, error) {
+	listener := pq.NewListener(connectionString, minReconnectInterval, maxReconnectInterval, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Println("change feed: ", err)
+		}
+	})
+	if err := listener.Listen(BookEventsChannel); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	log.Println("Listening for book changes")
+	return &ChangeFeed{notify: listener.Notify, ping: listener.Ping, close: listener.Close}, nil
+}
+
+// Run passes every book change to publish until ctx is done, then closes
+// the connection.
+func (feed *ChangeFeed) Run(ctx context.Context, publish func(eventType string, book models.Book)) {
+	defer feed.close()
+	ticker := time.NewTicker(listenerPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			go feed.ping()
+		case notification := <-feed.notify:
+			if notification == nil {
+				if feed.Reconnected != nil {
+					feed.Reconnected()
+				}
+				continue
+			}
+			eventType, book, err := parseBookNotification(notification.Extra)
+			if err != nil {
+				log.Println("change feed: ", err)
+				continue
+			}
+			publish(eventType, book)
+		}
+	}
+}
+
+func parseBookNotification(payload string) (string, models.Book, error) {
+	var notification bookNotification
+	if err := json.Unmarshal([]byte(payload), &notification); err != nil {
+		return "", models.Book{}, err
+	}
+	book := notification.Book
+	var err error
+	if book.Price, err = currencyPrice(book.Price, book.Currency); err != nil {
+		return "", models.Book{}, err
+	}
+	return notification.Type, book, nil
+}
@@ -0,0 +1,76 @@
+package db
+
+import (
+	"context"
+	"github.com/lib/pq"
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseBookNotification(t *testing.T) {
+	tests := []struct {
+		name      string
+		payload   string
+		eventType string
+		book      models.Book
+		wantErr   bool
+	}{
+		{
+			name:      "ok",
+			payload:   `{"type":"book.updated","book":{"id":1,"name":"Dune","price":9.9900,"currency":"USD","genre":3,"amount":4}}`,
+			eventType: models.EventBookUpdated,
+			book:      models.Book{ID: 1, Name: "Dune", Price: models.MustParseDecimal("9.99"), Currency: "USD", Genre: 3, Amount: 4},
+		},
+		{
+			name:      "currency without decimals",
+			payload:   `{"type":"book.created","book":{"id":2,"name":"Ringu","price":1500.0000,"currency":"JPY","genre":1,"amount":1}}`,
+			eventType: models.EventBookCreated,
+			book:      models.Book{ID: 2, Name: "Ringu", Price: models.MustParseDecimal("1500"), Currency: "JPY", Genre: 1, Amount: 1},
+		},
+		{
+			name:    "invalid payload",
+			payload: `{"type":`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eventType, book, err := parseBookNotification(tt.payload)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.eventType, eventType)
+			assert.Equal(t, tt.book, book)
+		})
+	}
+}
+
+func TestChangeFeedRun(t *testing.T) {
+	notify := make(chan *pq.Notification)
+	closed := make(chan struct{})
+	reconnected := 0
+	feed := &ChangeFeed{
+		notify:      notify,
+		ping:        func() error { return nil },
+		close:       func() error { close(closed); return nil },
+		Reconnected: func() { reconnected++ },
+	}
+
+	var published []int
+	ctx, cancel := context.WithCancel(context.Background())
+	go feed.Run(ctx, func(eventType string, book models.Book) {
+		published = append(published, book.ID)
+	})
+	notify <- &pq.Notification{Channel: BookEventsChannel, Extra: `{"type":"book.deleted","book":{"id":1,"price":1,"currency":"USD"}}`}
+	notify <- &pq.Notification{Channel: BookEventsChannel, Extra: `not json`}
+	notify <- nil
+	notify <- &pq.Notification{Channel: BookEventsChannel, Extra: `{"type":"book.created","book":{"id":2,"price":1,"currency":"USD"}}`}
+	cancel()
+	<-closed
+
+	assert.Equal(t, []int{1, 2}, published)
+	assert.Equal(t, 1, reconnected)
+}
@@ -0,0 +1,286 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"github.com/porky256/rest-api/models"
+	"sort"
+)
+
+var (
+	ErrUnknownBook         = errors.New("unknown book")
+	ErrUnknownCustomer     = errors.New("unknown customer")
+	ErrInsufficientStock   = errors.New("insufficient stock")
+	ErrInvalidTransition   = errors.New("invalid order status transition")
+	ErrMixedCurrencies     = errors.New("order items have different currencies")
+	errEmptyOrder          = errors.New("order has no items")
+	errNonPositiveQuantity = errors.New("order item quantity must be positive")
+)
+
+// queryer is implemented by both *sql.DB and *sql.Tx.
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// CreateOrder reserves stock for every item and stores the order in one
+// transaction. Book rows are locked in id order so concurrent orders can't
+// deadlock or drive the stock below zero.
+func (db *DatabasePostgres) CreateOrder(order models.Order, info models.AuditInfo) (models.Order, error) {
+	items, err := mergeOrderItems(order.Items)
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	tx, err := db.Conn.Begin()
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	defer func() {
+		switch err {
+		case nil:
+			err = tx.Commit()
+		default:
+			err = tx.Rollback()
+		}
+	}()
+
+	if order.CustomerID != 0 {
+		query := "select id from customers where id=$1 for share;"
+		err = tx.QueryRow(query, order.CustomerID).Scan(&order.CustomerID)
+		if err == sql.ErrNoRows {
+			err = ErrUnknownCustomer
+		}
+		if err != nil {
+			return models.Order{}, err
+		}
+	}
+
+	var total models.Decimal
+	var currency string
+	for i, item := range items {
+		var book models.Book
+		book, err = adjustStock(tx, item.BookID, -item.Quantity, info)
+		if err != nil {
+			return models.Order{}, err
+		}
+		if currency == "" {
+			currency = book.Currency
+		} else if currency != book.Currency {
+			err = ErrMixedCurrencies
+			return models.Order{}, err
+		}
+		items[i].Name = book.Name
+		items[i].Price = book.Price
+		var price models.Decimal
+		if price, err = book.Price.Mul(int64(item.Quantity)); err != nil {
+			return models.Order{}, err
+		}
+		if total, err = total.Add(price); err != nil {
+			return models.Order{}, err
+		}
+	}
+
+	query := "insert into orders (status,total,currency,customer_id) values ($1, $2, $3, nullif($4, 0)) returning id;"
+	var id int
+	err = tx.QueryRow(query, models.OrderStatusPending, total, currency, order.CustomerID).Scan(&id)
+	if err != nil {
+		return models.Order{}, err
+	}
+	for _, item := range items {
+		query = "insert into order_items (order_id,book_id,name,quantity,price) values ($1, $2, $3, $4, $5);"
+		_, err = tx.Exec(query, id, item.BookID, item.Name, item.Quantity, item.Price)
+		if err != nil {
+			return models.Order{}, err
+		}
+	}
+
+	order, err = getOrder(tx, id)
+	return order, err
+}
+
+func (db *DatabasePostgres) GetOrder(id int) (models.Order, error) {
+	return getOrder(db.Conn, id)
+}
+
+// UpdateOrderStatus moves an order to a new status, returning the reserved
+// stock to the books when the order gets cancelled.
+func (db *DatabasePostgres) UpdateOrderStatus(id int, status string, info models.AuditInfo) (models.Order, error) {
+	tx, err := db.Conn.Begin()
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	defer func() {
+		switch err {
+		case nil:
+			err = tx.Commit()
+		default:
+			err = tx.Rollback()
+		}
+	}()
+
+	var current string
+	query := "select status from orders where id=$1 for update;"
+	err = tx.QueryRow(query, id).Scan(&current)
+	if err != nil {
+		return models.Order{}, err
+	}
+	if !models.CanTransition(current, status) {
+		err = ErrInvalidTransition
+		return models.Order{}, err
+	}
+
+	order, err := getOrder(tx, id)
+	if err != nil {
+		return models.Order{}, err
+	}
+	if status == models.OrderStatusCancelled {
+		items, _ := mergeOrderItems(order.Items)
+		for _, item := range items {
+			if item.BookID == 0 {
+				continue
+			}
+			_, err = returnStock(tx, item.BookID, item.Quantity, info)
+			if err != nil && err != ErrUnknownBook {
+				return models.Order{}, err
+			}
+		}
+	}
+
+	query = "update orders set status=$1, updated_at=now() where id=$2;"
+	_, err = tx.Exec(query, status, id)
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	order, err = getOrder(tx, id)
+	return order, err
+}
+
+// adjustStock locks the book row and changes its amount by delta, refusing
+// to go below zero. The change is recorded in the audit log.
+func adjustStock(tx *sql.Tx, bookID int, delta int, info models.AuditInfo) (models.Book, error) {
+	query := "select " + bookSummary.String() + " from books where id =$1 and deleted_at is null for update;"
+	return changeStock(tx, query, bookID, delta, info)
+}
+
+// returnStock puts the quantity of a cancelled order item back on the book,
+// soft deleted books included so that restoring them restores their stock.
+func returnStock(tx *sql.Tx, bookID int, quantity int, info models.AuditInfo) (models.Book, error) {
+	query := "select " + bookSummary.String() + " from books where id =$1 for update;"
+	return changeStock(tx, query, bookID, quantity, info)
+}
+
+// changeStock locks the book row selected by query and changes its amount by
+// delta, see adjustStock.
+func changeStock(tx *sql.Tx, query string, bookID int, delta int, info models.AuditInfo) (models.Book, error) {
+	var before models.Book
+	err := bookSummary.scan(tx.QueryRow(query, bookID), &before)
+	if err == sql.ErrNoRows {
+		return before, ErrUnknownBook
+	}
+	if err != nil {
+		return before, err
+	}
+	if before.Amount+delta < 0 {
+		return before, ErrInsufficientStock
+	}
+
+	after := before
+	after.Amount += delta
+	query = "update books set amount=$1 where id=$2;"
+	_, err = tx.Exec(query, after.Amount, bookID)
+	if err != nil {
+		return before, err
+	}
+	err = writeAudit(tx, bookID, models.AuditActionUpdate, info, before, after)
+	return after, err
+}
+
+// mergeOrderItems sums up quantities of repeated books and sorts the items by book id.
+func mergeOrderItems(items []models.OrderItem) ([]models.OrderItem, error) {
+	if len(items) == 0 {
+		return nil, errEmptyOrder
+	}
+	merged := []models.OrderItem{}
+	index := map[int]int{}
+	for _, item := range items {
+		if item.Quantity <= 0 {
+			return nil, errNonPositiveQuantity
+		}
+		if i, ok := index[item.BookID]; ok {
+			merged[i].Quantity += item.Quantity
+			continue
+		}
+		index[item.BookID] = len(merged)
+		merged = append(merged, item)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].BookID < merged[j].BookID
+	})
+	return merged, nil
+}
+
+func getOrder(q queryer, id int) (models.Order, error) {
+	var order models.Order
+	query := "select " + orderColumns + " from orders where id=$1;"
+	err := scanOrder(q.QueryRow(query, id), &order)
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	orders := []models.Order{order}
+	err = loadOrderItems(q, orders)
+	return orders[0], err
+}
+
+const orderColumns = "id, coalesce(customer_id, 0), status, total, currency, created_at, updated_at"
+
+func scanOrder(row scanner, order *models.Order) error {
+	err := row.Scan(&order.ID, &order.CustomerID, &order.Status, &order.Total, &order.Currency, &order.CreatedAt, &order.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	order.Total, err = currencyPrice(order.Total, order.Currency)
+	return err
+}
+
+// loadOrderItems fills in the items of all given orders with a single query.
+func loadOrderItems(q queryer, orders []models.Order) error {
+	ids := make([]int64, len(orders))
+	index := map[int]int{}
+	for i := range orders {
+		orders[i].Items = []models.OrderItem{}
+		ids[i] = int64(orders[i].ID)
+		index[orders[i].ID] = i
+	}
+	if len(orders) == 0 {
+		return nil
+	}
+
+	query := "select order_id, coalesce(book_id, 0), name, quantity, price from order_items where order_id = any($1) order by id;"
+	rows, err := q.Query(query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var orderID int
+		var item models.OrderItem
+		err = rows.Scan(&orderID, &item.BookID, &item.Name, &item.Quantity, &item.Price)
+		if err != nil {
+			return err
+		}
+		i := index[orderID]
+		item.Price, err = currencyPrice(item.Price, orders[i].Currency)
+		if err != nil {
+			return err
+		}
+		orders[i].Items = append(orders[i].Items, item)
+	}
+	return rows.Err()
+}
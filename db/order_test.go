@@ -0,0 +1,259 @@
+package db
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+	"time"
+)
+
+var (
+	bookColumnNames  = []string{"id", "name", "price", "currency", "genre", "amount"}
+	orderColumnNames = []string{"id", "customer_id", "status", "total", "currency", "created_at", "updated_at"}
+	itemColumnNames  = []string{"order_id", "book_id", "name", "quantity", "price"}
+)
+
+func TestDatabasePostgres_CreateOrder(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error in creating mock: %s", err)
+	}
+	db := DatabasePostgres{Conn: conn}
+	created := time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)
+	type MockBehavior func(mock sqlmock.Sqlmock)
+	tests := []struct {
+		name         string
+		inputOrder   models.Order
+		returnOrder  models.Order
+		mockBehavior MockBehavior
+		returnErr    error
+	}{
+		{
+			name: "OK",
+			inputOrder: models.Order{Items: []models.OrderItem{
+				{BookID: 2, Quantity: 1}, {BookID: 1, Quantity: 1}, {BookID: 2, Quantity: 2}}},
+			returnOrder: models.Order{ID: 7, Status: models.OrderStatusPending, Total: models.MustParseDecimal("8.00"), Currency: "USD",
+				Items:     []models.OrderItem{{BookID: 1, Name: "book1", Quantity: 1, Price: models.MustParseDecimal("2.00")}, {BookID: 2, Name: "book2", Quantity: 3, Price: models.MustParseDecimal("2.00")}},
+				CreatedAt: created, UpdatedAt: created},
+			mockBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("select (.+) from books where id =\\$1 and deleted_at is null for update").
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows(bookColumnNames).AddRow(1, "book1", 2, "USD", 1, 5))
+				mock.ExpectExec("update books set amount").WithArgs(4, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("insert into audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectQuery("select (.+) from books where id =\\$1 and deleted_at is null for update").
+					WithArgs(2).
+					WillReturnRows(sqlmock.NewRows(bookColumnNames).AddRow(2, "book2", 2, "USD", 1, 3))
+				mock.ExpectExec("update books set amount").WithArgs(0, 2).WillReturnResult(sqlmock.NewResult(2, 1))
+				mock.ExpectExec("insert into audit_log").WillReturnResult(sqlmock.NewResult(2, 1))
+				mock.ExpectQuery("insert into orders").
+					WithArgs(models.OrderStatusPending, models.MustParseDecimal("8.00"), "USD", 0).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+				mock.ExpectExec("insert into order_items").WithArgs(7, 1, "book1", 1, models.MustParseDecimal("2.00")).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("insert into order_items").WithArgs(7, 2, "book2", 3, models.MustParseDecimal("2.00")).WillReturnResult(sqlmock.NewResult(2, 1))
+				mock.ExpectQuery("from orders where id").WithArgs(7).
+					WillReturnRows(sqlmock.NewRows(orderColumnNames).AddRow(7, 0, models.OrderStatusPending, 8, "USD", created, created))
+				mock.ExpectQuery("from order_items").WithArgs(pq.Array([]int64{7})).
+					WillReturnRows(sqlmock.NewRows(itemColumnNames).AddRow(7, 1, "book1", 1, 2).AddRow(7, 2, "book2", 3, 2))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name:       "Insufficient stock",
+			inputOrder: models.Order{Items: []models.OrderItem{{BookID: 1, Quantity: 6}}},
+			returnErr:  ErrInsufficientStock,
+			mockBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("select (.+) from books").
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows(bookColumnNames).AddRow(1, "book1", 2, "USD", 1, 5))
+				mock.ExpectRollback()
+			},
+		},
+		{
+			name:       "Mixed currencies",
+			inputOrder: models.Order{Items: []models.OrderItem{{BookID: 1, Quantity: 1}, {BookID: 2, Quantity: 1}}},
+			returnErr:  ErrMixedCurrencies,
+			mockBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("select (.+) from books").
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows(bookColumnNames).AddRow(1, "book1", 2, "USD", 1, 5))
+				mock.ExpectExec("update books set amount").WithArgs(4, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("insert into audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectQuery("select (.+) from books").
+					WithArgs(2).
+					WillReturnRows(sqlmock.NewRows(bookColumnNames).AddRow(2, "book2", 2, "EUR", 1, 5))
+				mock.ExpectExec("update books set amount").WithArgs(4, 2).WillReturnResult(sqlmock.NewResult(2, 1))
+				mock.ExpectExec("insert into audit_log").WillReturnResult(sqlmock.NewResult(2, 1))
+				mock.ExpectRollback()
+			},
+		},
+		{
+			name:       "Total overflow",
+			inputOrder: models.Order{Items: []models.OrderItem{{BookID: 1, Quantity: 10}}},
+			returnErr:  models.ErrDecimalOverflow,
+			mockBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("select (.+) from books").
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows(bookColumnNames).AddRow(1, "book1", "999999999999999999", "JPY", 1, 50))
+				mock.ExpectExec("update books set amount").WithArgs(40, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("insert into audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectRollback()
+			},
+		},
+		{
+			name:       "Unknown book",
+			inputOrder: models.Order{Items: []models.OrderItem{{BookID: 1422, Quantity: 1}}},
+			returnErr:  ErrUnknownBook,
+			mockBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("select (.+) from books").
+					WithArgs(1422).
+					WillReturnRows(sqlmock.NewRows(bookColumnNames))
+				mock.ExpectRollback()
+			},
+		},
+		{
+			name:       "Unknown customer",
+			inputOrder: models.Order{CustomerID: 9, Items: []models.OrderItem{{BookID: 1, Quantity: 1}}},
+			returnErr:  ErrUnknownCustomer,
+			mockBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("select id from customers").
+					WithArgs(9).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				mock.ExpectRollback()
+			},
+		},
+		{
+			name:         "No items",
+			inputOrder:   models.Order{},
+			returnErr:    errEmptyOrder,
+			mockBehavior: func(mock sqlmock.Sqlmock) {},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.mockBehavior(mock)
+
+			order, err := db.CreateOrder(test.inputOrder, models.AuditInfo{Actor: "tester", RequestID: "1"})
+			if test.returnErr != nil {
+				assert.Equal(t, test.returnErr, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.returnOrder, order)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestDatabasePostgres_UpdateOrderStatus(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error in creating mock: %s", err)
+	}
+	db := DatabasePostgres{Conn: conn}
+	created := time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)
+	type MockBehavior func(mock sqlmock.Sqlmock, id int)
+	tests := []struct {
+		name         string
+		inputId      int
+		status       string
+		returnOrder  models.Order
+		mockBehavior MockBehavior
+		returnErr    error
+	}{
+		{
+			name:    "Cancel returns stock",
+			inputId: 7,
+			status:  models.OrderStatusCancelled,
+			returnOrder: models.Order{ID: 7, Status: models.OrderStatusCancelled, Total: models.MustParseDecimal("2.00"), Currency: "USD",
+				Items:     []models.OrderItem{{BookID: 1, Name: "book1", Quantity: 1, Price: models.MustParseDecimal("2.00")}},
+				CreatedAt: created, UpdatedAt: created},
+			mockBehavior: func(mock sqlmock.Sqlmock, id int) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("select status from orders").WithArgs(id).
+					WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.OrderStatusPaid))
+				mock.ExpectQuery("from orders where id").WithArgs(id).
+					WillReturnRows(sqlmock.NewRows(orderColumnNames).AddRow(id, 0, models.OrderStatusPaid, 2, "USD", created, created))
+				mock.ExpectQuery("from order_items").WithArgs(pq.Array([]int64{int64(id)})).
+					WillReturnRows(sqlmock.NewRows(itemColumnNames).AddRow(id, 1, "book1", 1, 2))
+				mock.ExpectQuery("select (.+) from books").WithArgs(1).
+					WillReturnRows(sqlmock.NewRows(bookColumnNames).AddRow(1, "book1", 2, "USD", 1, 0))
+				mock.ExpectExec("update books set amount").WithArgs(1, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("insert into audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("update orders set status").WithArgs(models.OrderStatusCancelled, id).
+					WillReturnResult(sqlmock.NewResult(int64(id), 1))
+				mock.ExpectQuery("from orders where id").WithArgs(id).
+					WillReturnRows(sqlmock.NewRows(orderColumnNames).AddRow(id, 0, models.OrderStatusCancelled, 2, "USD", created, created))
+				mock.ExpectQuery("from order_items").WithArgs(pq.Array([]int64{int64(id)})).
+					WillReturnRows(sqlmock.NewRows(itemColumnNames).AddRow(id, 1, "book1", 1, 2))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name:    "Cancel returns stock to deleted books",
+			inputId: 8,
+			status:  models.OrderStatusCancelled,
+			returnOrder: models.Order{ID: 8, Status: models.OrderStatusCancelled, Total: models.MustParseDecimal("6.00"), Currency: "USD",
+				Items: []models.OrderItem{
+					{BookID: 2, Name: "book2", Quantity: 2, Price: models.MustParseDecimal("2.00")},
+					{BookID: 0, Name: "purged", Quantity: 1, Price: models.MustParseDecimal("2.00")},
+				},
+				CreatedAt: created, UpdatedAt: created},
+			mockBehavior: func(mock sqlmock.Sqlmock, id int) {
+				items := sqlmock.NewRows(itemColumnNames).AddRow(id, 2, "book2", 2, 2).AddRow(id, 0, "purged", 1, 2)
+				mock.ExpectBegin()
+				mock.ExpectQuery("select status from orders").WithArgs(id).
+					WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.OrderStatusPending))
+				mock.ExpectQuery("from orders where id").WithArgs(id).
+					WillReturnRows(sqlmock.NewRows(orderColumnNames).AddRow(id, 0, models.OrderStatusPending, 6, "USD", created, created))
+				mock.ExpectQuery("from order_items").WithArgs(pq.Array([]int64{int64(id)})).WillReturnRows(items)
+				mock.ExpectQuery(regexp.QuoteMeta("from books where id =$1 for update;")).WithArgs(2).
+					WillReturnRows(sqlmock.NewRows(bookColumnNames).AddRow(2, "book2", 2, "USD", 1, 0))
+				mock.ExpectExec("update books set amount").WithArgs(2, 2).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("insert into audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("update orders set status").WithArgs(models.OrderStatusCancelled, id).
+					WillReturnResult(sqlmock.NewResult(int64(id), 1))
+				mock.ExpectQuery("from orders where id").WithArgs(id).
+					WillReturnRows(sqlmock.NewRows(orderColumnNames).AddRow(id, 0, models.OrderStatusCancelled, 6, "USD", created, created))
+				mock.ExpectQuery("from order_items").WithArgs(pq.Array([]int64{int64(id)})).
+					WillReturnRows(sqlmock.NewRows(itemColumnNames).AddRow(id, 2, "book2", 2, 2).AddRow(id, 0, "purged", 1, 2))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name:      "Invalid transition",
+			inputId:   7,
+			status:    models.OrderStatusPending,
+			returnErr: ErrInvalidTransition,
+			mockBehavior: func(mock sqlmock.Sqlmock, id int) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("select status from orders").WithArgs(id).
+					WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.OrderStatusShipped))
+				mock.ExpectRollback()
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.mockBehavior(mock, test.inputId)
+
+			order, err := db.UpdateOrderStatus(test.inputId, test.status, models.AuditInfo{Actor: "tester", RequestID: "1"})
+			if test.returnErr != nil {
+				assert.Equal(t, test.returnErr, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.returnOrder, order)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/porky256/rest-api/models"
+	"strings"
+)
+
+const defaultPriceLimit = 100
+
+const priceColumns = "id, book_id, old_price, coalesce(old_currency, ''), price, currency, actor, request_id, changed_at"
+
+const priceReportColumns = "p.id, p.book_id, p.old_price, p.old_currency, p.price, p.currency, p.actor, p.request_id, p.changed_at, " +
+	"b.name, p.price-p.old_price, round((p.price-p.old_price)*100/p.old_price, 2)"
+
+// writePriceChange records a new price of a book inside the transaction
+// setting it. before is nil when the book has just been created.
+func writePriceChange(tx *sql.Tx, before *models.Book, after models.Book, info models.AuditInfo) error {
+	var oldPrice interface{}
+	var oldCurrency interface{}
+	if before != nil {
+		if before.Price.Cmp(after.Price) == 0 && before.Currency == after.Currency {
+			return nil
+		}
+		oldPrice, oldCurrency = before.Price, before.Currency
+	}
+	query := "insert into price_history (book_id,old_price,old_currency,price,currency,actor,request_id) " +
+		"values ($1, $2, $3, $4, $5, $6, $7);"
+	_, err := tx.Exec(query, after.ID, oldPrice, oldCurrency, after.Price, after.Currency, info.Actor, info.RequestID)
+	return err
+}
+
+// scanPriceChange reads a change selected as priceColumns followed by the
+// extra destinations, giving the prices as many decimal places as their currency has.
+func scanPriceChange(row scanner, change *models.PriceChange, extra ...interface{}) error {
+	dest := append([]interface{}{&change.ID, &change.BookID, &change.OldPrice, &change.OldCurrency, &change.Price,
+		&change.Currency, &change.Actor, &change.RequestID, &change.ChangedAt}, extra...)
+	err := row.Scan(dest...)
+	if err != nil {
+		return err
+	}
+	if change.OldPrice != nil {
+		oldPrice, err := currencyPrice(*change.OldPrice, change.OldCurrency)
+		if err != nil {
+			return err
+		}
+		change.OldPrice = &oldPrice
+	}
+	change.Price, err = currencyPrice(change.Price, change.Currency)
+	return err
+}
+
+// priceConditions turns the time range of the filter into where conditions
+// on the changed_at column, appending their arguments to queryinfo.
+func priceConditions(filter models.PriceFilter, conditions []string, queryinfo []interface{}) ([]string, []interface{}) {
+	if !filter.From.IsZero() {
+		queryinfo = append(queryinfo, filter.From)
+		conditions = append(conditions, fmt.Sprintf("changed_at>=$%d", len(queryinfo)))
+	}
+	if !filter.To.IsZero() {
+		queryinfo = append(queryinfo, filter.To)
+		conditions = append(conditions, fmt.Sprintf("changed_at<$%d", len(queryinfo)))
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultPriceLimit
+	}
+	return conditions, append(queryinfo, limit)
+}
+
+// GetPriceHistory returns the price changes of a book, newest first.
+func (db *DatabasePostgres) GetPriceHistory(id int, filter models.PriceFilter) ([]models.PriceChange, error) {
+	conditions, queryinfo := priceConditions(filter, []string{"book_id=$1"}, []interface{}{id})
+	query := "select " + priceColumns + " from price_history where " + strings.Join(conditions, " and ") +
+		fmt.Sprintf(" order by changed_at desc, id desc limit $%d;", len(queryinfo))
+
+	list := []models.PriceChange{}
+	rows, err := db.Conn.Query(query, queryinfo...)
+	if err != nil {
+		return list, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var change models.PriceChange
+		err = scanPriceChange(rows, &change)
+		if err != nil {
+			return list, err
+		}
+		list = append(list, change)
+	}
+	return list, rows.Err()
+}
+
+// GetPriceChangeReport returns the largest price changes in the filter's
+// time range, ranked by their relative size. Currency conversions and initial
+// prices are left out since they have nothing to compare against.
+func (db *DatabasePostgres) GetPriceChangeReport(filter models.PriceFilter) ([]models.PriceChangeReport, error) {
+	conditions, queryinfo := priceConditions(filter,
+		[]string{"p.old_price is not null", "p.old_price>0", "p.old_currency=p.currency"}, nil)
+	query := "select " + priceReportColumns + " from price_history p join books b on b.id=p.book_id where " +
+		strings.Join(conditions, " and ") +
+		fmt.Sprintf(" order by abs(p.price-p.old_price)/p.old_price desc, p.id desc limit $%d;", len(queryinfo))
+
+	list := []models.PriceChangeReport{}
+	rows, err := db.Conn.Query(query, queryinfo...)
+	if err != nil {
+		return list, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var report models.PriceChangeReport
+		err = scanPriceChange(rows, &report.PriceChange, &report.Name, &report.Change, &report.ChangePercent)
+		if err != nil {
+			return list, err
+		}
+		report.Change, err = currencyPrice(report.Change, report.Currency)
+		if err != nil {
+			return list, err
+		}
+		list = append(list, report)
+	}
+	return list, rows.Err()
+}
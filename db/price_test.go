@@ -0,0 +1,107 @@
+package db
+
+import (
+	"database/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+var priceColumnNames = []string{"id", "book_id", "old_price", "old_currency", "price", "currency", "actor", "request_id", "changed_at"}
+
+func TestDatabasePostgres_GetPriceHistory(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error in creating mock: %s", err)
+	}
+	db := DatabasePostgres{Conn: conn}
+	changed := time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)
+	from := changed.Add(-time.Hour)
+	to := changed.Add(time.Hour)
+	oldPrice := models.MustParseDecimal("10.00")
+	tests := []struct {
+		name         string
+		filter       models.PriceFilter
+		mockBehavior func(mock sqlmock.Sqlmock)
+		returnList   []models.PriceChange
+		returnErr    bool
+	}{
+		{
+			name: "OK",
+			mockBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("select (.+) from price_history where book_id=\\$1 order by changed_at desc, id desc limit \\$2").
+					WithArgs(1, defaultPriceLimit).
+					WillReturnRows(sqlmock.NewRows(priceColumnNames).
+						AddRow(2, 1, "10.0000", "USD", "12.5000", "USD", "tester", "2", changed).
+						AddRow(1, 1, nil, "", "10.0000", "USD", "tester", "1", changed))
+			},
+			returnList: []models.PriceChange{
+				{ID: 2, BookID: 1, OldPrice: &oldPrice, OldCurrency: "USD", Price: models.MustParseDecimal("12.50"),
+					Currency: "USD", Actor: "tester", RequestID: "2", ChangedAt: changed},
+				{ID: 1, BookID: 1, Price: models.MustParseDecimal("10.00"), Currency: "USD", Actor: "tester",
+					RequestID: "1", ChangedAt: changed},
+			},
+		},
+		{
+			name:   "Time range",
+			filter: models.PriceFilter{From: from, To: to, Limit: 5},
+			mockBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("where book_id=\\$1 and changed_at>=\\$2 and changed_at<\\$3 order by (.+) limit \\$4").
+					WithArgs(1, from, to, 5).
+					WillReturnRows(sqlmock.NewRows(priceColumnNames))
+			},
+			returnList: []models.PriceChange{},
+		},
+		{
+			name: "Error",
+			mockBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("select (.+) from price_history").WillReturnError(sql.ErrConnDone)
+			},
+			returnErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.mockBehavior(mock)
+
+			list, err := db.GetPriceHistory(1, test.filter)
+			if test.returnErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.returnList, list)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestDatabasePostgres_GetPriceChangeReport(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error in creating mock: %s", err)
+	}
+	db := DatabasePostgres{Conn: conn}
+	changed := time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)
+	from := changed.Add(-24 * time.Hour)
+	oldPrice := models.MustParseDecimal("10.00")
+
+	mock.ExpectQuery("from price_history p join books b on b.id=p.book_id where (.+) and changed_at>=\\$1 "+
+		"order by abs\\(p.price-p.old_price\\)/p.old_price desc, p.id desc limit \\$2").
+		WithArgs(from, 10).
+		WillReturnRows(sqlmock.NewRows(append(priceColumnNames, "name", "change", "change_percent")).
+			AddRow(2, 1, "10.0000", "USD", "5.0000", "USD", "tester", "2", changed, "Dune", "-5.0000", "-50.00"))
+
+	list, err := db.GetPriceChangeReport(models.PriceFilter{From: from, Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, []models.PriceChangeReport{{
+		PriceChange: models.PriceChange{ID: 2, BookID: 1, OldPrice: &oldPrice, OldCurrency: "USD",
+			Price: models.MustParseDecimal("5.00"), Currency: "USD", Actor: "tester", RequestID: "2", ChangedAt: changed},
+		Name:          "Dune",
+		Change:        models.MustParseDecimal("-5.00"),
+		ChangePercent: models.MustParseDecimal("-50.00"),
+	}}, list)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,95 @@
+package db
+
+import (
+	"database/sql"
+	"github.com/porky256/rest-api/models"
+	"time"
+)
+
+const promotionColumns = "id, name, kind, value, coalesce(currency, ''), coalesce(genre, 0), coalesce(book_id, 0), " +
+	"starts_at, ends_at, stackable, priority"
+
+func scanPromotion(row scanner, promotion *models.Promotion) error {
+	return row.Scan(&promotion.ID, &promotion.Name, &promotion.Kind, &promotion.Value, &promotion.Currency,
+		&promotion.Genre, &promotion.BookID, &promotion.StartsAt, &promotion.EndsAt, &promotion.Stackable, &promotion.Priority)
+}
+
+func (db *DatabasePostgres) GetAllPromotions() ([]models.Promotion, error) {
+	query := "select " + promotionColumns + " from promotions order by starts_at desc, id desc;"
+	return db.queryPromotions(query)
+}
+
+// GetActivePromotions returns the promotions whose date window contains the given time.
+func (db *DatabasePostgres) GetActivePromotions(at time.Time) ([]models.Promotion, error) {
+	query := "select " + promotionColumns + " from promotions where starts_at<=$1 and (ends_at is null or ends_at>$1) order by id;"
+	return db.queryPromotions(query, at)
+}
+
+func (db *DatabasePostgres) queryPromotions(query string, args ...interface{}) ([]models.Promotion, error) {
+	list := []models.Promotion{}
+	rows, err := db.Conn.Query(query, args...)
+	if err != nil {
+		return list, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var promotion models.Promotion
+		err = scanPromotion(rows, &promotion)
+		if err != nil {
+			return list, err
+		}
+		list = append(list, promotion)
+	}
+	return list, rows.Err()
+}
+
+func (db *DatabasePostgres) GetPromotionById(id int) (models.Promotion, error) {
+	var promotion models.Promotion
+	query := "select " + promotionColumns + " from promotions where id=$1;"
+	err := scanPromotion(db.Conn.QueryRow(query, id), &promotion)
+	return promotion, err
+}
+
+func (db *DatabasePostgres) AddPromotion(promotion models.Promotion) (int, error) {
+	var id int
+	query := "insert into promotions (name,kind,value,currency,genre,book_id,starts_at,ends_at,stackable,priority) " +
+		"values ($1, $2, $3, nullif($4, ''), nullif($5, 0), nullif($6, 0), $7, $8, $9, $10) returning id;"
+	err := db.Conn.QueryRow(query, promotion.Name, promotion.Kind, promotion.Value, promotion.Currency, promotion.Genre,
+		promotion.BookID, promotion.StartsAt, promotion.EndsAt, promotion.Stackable, promotion.Priority).Scan(&id)
+	return id, err
+}
+
+func (db *DatabasePostgres) UpdatePromotion(id int, promotion models.Promotion) error {
+	query := "update promotions set name=$1, kind=$2, value=$3, currency=nullif($4, ''), genre=nullif($5, 0), book_id=nullif($6, 0), " +
+		"starts_at=$7, ends_at=$8, stackable=$9, priority=$10 where id=$11;"
+	res, err := db.Conn.Exec(query, promotion.Name, promotion.Kind, promotion.Value, promotion.Currency, promotion.Genre,
+		promotion.BookID, promotion.StartsAt, promotion.EndsAt, promotion.Stackable, promotion.Priority, id)
+	if err != nil {
+		return err
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if aff == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (db *DatabasePostgres) DelPromotion(id int) error {
+	query := "delete from promotions where id=$1;"
+	res, err := db.Conn.Exec(query, id)
+	if err != nil {
+		return err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
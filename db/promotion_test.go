@@ -0,0 +1,124 @@
+package db
+
+import (
+	"database/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+var promotionColumnNames = []string{"id", "name", "kind", "value", "currency", "genre", "book_id",
+	"starts_at", "ends_at", "stackable", "priority"}
+
+func TestDatabasePostgres_GetActivePromotions(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error in creating mock: %s", err)
+	}
+	db := DatabasePostgres{Conn: conn}
+	start := time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+	now := start.Add(time.Hour)
+	tests := []struct {
+		name         string
+		mockBehavior func(mock sqlmock.Sqlmock)
+		returnList   []models.Promotion
+		returnErr    bool
+	}{
+		{
+			name: "OK",
+			mockBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("select (.+) from promotions where starts_at<=\\$1").
+					WithArgs(now).
+					WillReturnRows(sqlmock.NewRows(promotionColumnNames).
+						AddRow(1, "Sale", "percentage", "10", "", 2, 0, start, end, false, 0).
+						AddRow(2, "Coupon", "fixed", "1.5000", "USD", 0, 3, start, nil, true, 1))
+			},
+			returnList: []models.Promotion{
+				{ID: 1, Name: "Sale", Kind: models.PromotionPercentage, Value: models.MustParseDecimal("10"), Genre: 2,
+					StartsAt: start, EndsAt: &end},
+				{ID: 2, Name: "Coupon", Kind: models.PromotionFixed, Value: models.MustParseDecimal("1.5000"), Currency: "USD",
+					BookID: 3, StartsAt: start, Stackable: true, Priority: 1},
+			},
+		},
+		{
+			name: "Error",
+			mockBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("select (.+) from promotions").WillReturnError(sql.ErrConnDone)
+			},
+			returnErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.mockBehavior(mock)
+
+			list, err := db.GetActivePromotions(now)
+			if test.returnErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.returnList, list)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestDatabasePostgres_AddPromotion(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error in creating mock: %s", err)
+	}
+	db := DatabasePostgres{Conn: conn}
+	start := time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)
+	promotion := models.Promotion{Name: "Sale", Kind: models.PromotionPercentage, Value: models.MustParseDecimal("10"),
+		Genre: 2, StartsAt: start}
+
+	mock.ExpectQuery("insert into promotions").
+		WithArgs("Sale", "percentage", promotion.Value, "", 2, 0, start, nil, false, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	id, err := db.AddPromotion(promotion)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, id)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDatabasePostgres_DelPromotion(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error in creating mock: %s", err)
+	}
+	db := DatabasePostgres{Conn: conn}
+	tests := []struct {
+		name         string
+		mockBehavior func(mock sqlmock.Sqlmock)
+		returnErr    error
+	}{
+		{
+			name: "OK",
+			mockBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("delete from promotions").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name: "Not found",
+			mockBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("delete from promotions").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			returnErr: sql.ErrNoRows,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.mockBehavior(mock)
+
+			err := db.DelPromotion(1)
+			assert.Equal(t, test.returnErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"github.com/porky256/rest-api/models"
+)
+
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+// escapedName is the book name with the characters that mean something in
+// HTML escaped, so the highlighting is the only markup in a snippet.
+const escapedName = `replace(replace(replace(replace(name, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;')`
+
+// SearchBooks ranks books by full-text relevance of their name to the query,
+// falling back on trigram similarity so that misspelled queries still match.
+// Snippets are HTML, the name escaped with the matched words in <b> tags.
+func (db *DatabasePostgres) SearchBooks(query string, limit int) ([]models.SearchResult, error) {
+	if limit <= 0 || limit > MaxSearchLimit {
+		limit = DefaultSearchLimit
+	}
+
+	list := []models.SearchResult{}
+	sqlQuery := "select " + bookColumns.String() + `,
+		ts_rank(search, q) + similarity(name, $1) as rank,
+		ts_headline('simple', ` + escapedName + `, q, 'StartSel=<b>, StopSel=</b>, HighlightAll=true') as snippet
+		from books, websearch_to_tsquery('simple', $1) q
+		where deleted_at is null and (search @@ q or name % $1)
+		order by rank desc, id desc limit $2;`
+	rows, err := db.Conn.Query(sqlQuery, query, limit)
+	if err != nil {
+		return list, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var result models.SearchResult
+		err = bookColumns.scan(rows, &result.Book, &result.Rank, &result.Snippet)
+		if err != nil {
+			return list, err
+		}
+		list = append(list, result)
+	}
+	return list, rows.Err()
+}
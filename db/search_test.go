@@ -0,0 +1,99 @@
+package db
+
+import (
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+)
+
+func TestDatabasePostgres_SearchBooks(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error in creating mock: %s", err)
+	}
+	db := DatabasePostgres{Conn: conn}
+	columns := []string{"id", "name", "price", "currency", "genre", "amount", "authors", "created_at", "updated_at", "rank", "snippet"}
+	type MockBehavior func(mock sqlmock.Sqlmock, query string, limit int)
+	tests := []struct {
+		name          string
+		query         string
+		limit         int
+		expectedLimit int
+		returnResults []models.SearchResult
+		mockBehavior  MockBehavior
+		returnErr     bool
+	}{
+		{
+			name:          "OK",
+			query:         "ring",
+			limit:         5,
+			expectedLimit: 5,
+			returnResults: []models.SearchResult{
+				{Book: models.Book{ID: 1, Name: "The Ring", Price: models.MustParseDecimal("1.00"), Currency: "USD", Genre: 3, Amount: 1,
+					Authors: []string{"Tolkien"}, CreatedAt: bookCreated, UpdatedAt: bookCreated}, Rank: 0.5, Snippet: "The <b>Ring</b>"},
+			},
+			mockBehavior: func(mock sqlmock.Sqlmock, query string, limit int) {
+				mock.ExpectQuery("websearch_to_tsquery").
+					WithArgs(query, limit).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(1, "The Ring", 1, "USD", 3, 1, "{Tolkien}", bookCreated, bookCreated, 0.5, "The <b>Ring</b>"))
+			},
+		},
+		{
+			name:          "Escaped snippet",
+			query:         "ring",
+			limit:         5,
+			expectedLimit: 5,
+			returnResults: []models.SearchResult{
+				{Book: models.Book{ID: 2, Name: "<script>Ring</script>", Price: models.MustParseDecimal("1.00"), Currency: "USD", Genre: 3, Amount: 1,
+					Authors: []string{}, CreatedAt: bookCreated, UpdatedAt: bookCreated}, Rank: 0.5, Snippet: "&lt;script&gt;<b>Ring</b>&lt;/script&gt;"},
+			},
+			mockBehavior: func(mock sqlmock.Sqlmock, query string, limit int) {
+				mock.ExpectQuery(regexp.QuoteMeta(`ts_headline('simple', `+escapedName+`, q,`)).
+					WithArgs(query, limit).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(2, "<script>Ring</script>", 1, "USD", 3, 1, "{}", bookCreated, bookCreated, 0.5, "&lt;script&gt;<b>Ring</b>&lt;/script&gt;"))
+			},
+		},
+		{
+			name:          "Default limit",
+			query:         "ring",
+			limit:         1000,
+			expectedLimit: DefaultSearchLimit,
+			returnResults: []models.SearchResult{},
+			mockBehavior: func(mock sqlmock.Sqlmock, query string, limit int) {
+				mock.ExpectQuery("websearch_to_tsquery").
+					WithArgs(query, limit).
+					WillReturnRows(sqlmock.NewRows(columns))
+			},
+		},
+		{
+			name:          "Query error",
+			query:         "ring",
+			expectedLimit: DefaultSearchLimit,
+			returnErr:     true,
+			mockBehavior: func(mock sqlmock.Sqlmock, query string, limit int) {
+				mock.ExpectQuery("websearch_to_tsquery").
+					WithArgs(query, limit).
+					WillReturnError(errors.New("query error"))
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.mockBehavior(mock, test.query, test.expectedLimit)
+
+			results, err := db.SearchBooks(test.query, test.limit)
+			if test.returnErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.returnResults, results)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
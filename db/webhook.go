@@ -0,0 +1,174 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"github.com/lib/pq"
+	"github.com/porky256/rest-api/models"
+	"time"
+)
+
+const (
+	webhookColumns  = "id, url, events, created_at"
+	deliveryColumns = "d.id, d.webhook_id, w.url, w.secret, e.id, e.type, e.book_id, e.data, e.created_at, " +
+		"d.status, d.attempts, d.next_attempt_at, coalesce(d.response_status, 0), coalesce(d.last_error, ''), d.delivered_at"
+)
+
+// writeEvent records a book event in the outbox inside the transaction
+// performing the change and queues a delivery for every webhook subscribed
+// to the event type, so no change is published without being committed.
+func writeEvent(tx *sql.Tx, eventType string, book models.Book) error {
+	data, err := json.Marshal(book)
+	if err != nil {
+		return err
+	}
+	query := "with event as (insert into outbox (type,book_id,data) values ($1, $2, $3) returning id) " +
+		"insert into webhook_deliveries (webhook_id,event_id) select w.id, event.id from webhooks w, event where $1=any(w.events);"
+	_, err = tx.Exec(query, eventType, book.ID, data)
+	return err
+}
+
+func scanWebhook(row scanner, webhook *models.Webhook) error {
+	return row.Scan(&webhook.ID, &webhook.URL, pq.Array(&webhook.Events), &webhook.CreatedAt)
+}
+
+func (db *DatabasePostgres) GetWebhooks() ([]models.Webhook, error) {
+	list := []models.Webhook{}
+	query := "select " + webhookColumns + " from webhooks order by id;"
+	rows, err := db.Conn.Query(query)
+	if err != nil {
+		return list, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var webhook models.Webhook
+		err = scanWebhook(rows, &webhook)
+		if err != nil {
+			return list, err
+		}
+		list = append(list, webhook)
+	}
+	return list, rows.Err()
+}
+
+func (db *DatabasePostgres) GetWebhookById(id int) (models.Webhook, error) {
+	var webhook models.Webhook
+	query := "select " + webhookColumns + " from webhooks where id=$1;"
+	err := scanWebhook(db.Conn.QueryRow(query, id), &webhook)
+	return webhook, err
+}
+
+func (db *DatabasePostgres) AddWebhook(webhook models.Webhook) (int, error) {
+	var id int
+	query := "insert into webhooks (url,secret,events) values ($1, $2, $3) returning id;"
+	err := db.Conn.QueryRow(query, webhook.URL, webhook.Secret, pq.Array(webhook.Events)).Scan(&id)
+	return id, err
+}
+
+// UpdateWebhook changes the URL and events of a webhook, an empty secret keeps the current one.
+func (db *DatabasePostgres) UpdateWebhook(id int, webhook models.Webhook) error {
+	query := "update webhooks set url=$1, secret=coalesce(nullif($2, ''), secret), events=$3 where id=$4;"
+	res, err := db.Conn.Exec(query, webhook.URL, webhook.Secret, pq.Array(webhook.Events), id)
+	if err != nil {
+		return err
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if aff == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (db *DatabasePostgres) DelWebhook(id int) error {
+	query := "delete from webhooks where id=$1;"
+	res, err := db.Conn.Exec(query, id)
+	if err != nil {
+		return err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func scanDelivery(row scanner, delivery *models.Delivery) error {
+	var data []byte
+	err := row.Scan(&delivery.ID, &delivery.WebhookID, &delivery.URL, &delivery.Secret, &delivery.Event.ID,
+		&delivery.Event.Type, &delivery.Event.BookID, &data, &delivery.Event.CreatedAt, &delivery.Status,
+		&delivery.Attempts, &delivery.NextAttemptAt, &delivery.ResponseStatus, &delivery.LastError, &delivery.DeliveredAt)
+	if err != nil {
+		return err
+	}
+	delivery.Event.Data = data
+	return nil
+}
+
+func queryDeliveries(q queryer, query string, args ...interface{}) ([]models.Delivery, error) {
+	list := []models.Delivery{}
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return list, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var delivery models.Delivery
+		err = scanDelivery(rows, &delivery)
+		if err != nil {
+			return list, err
+		}
+		list = append(list, delivery)
+	}
+	return list, rows.Err()
+}
+
+// ClaimDeliveries picks up to limit deliveries that are due and leases them
+// for the given time, during which no other worker will pick them up. A
+// worker that dies mid-delivery leaves them to be retried after the lease.
+func (db *DatabasePostgres) ClaimDeliveries(limit int, lease time.Duration) ([]models.Delivery, error) {
+	query := "with claimed as (update webhook_deliveries set attempts=attempts+1, next_attempt_at=now()+make_interval(secs => $2) " +
+		"where id in (select id from webhook_deliveries where status='pending' and next_attempt_at<=now() " +
+		"order by next_attempt_at, id limit $1 for update skip locked) returning *) " +
+		"select " + deliveryColumns + " from claimed d join webhooks w on w.id=d.webhook_id join outbox e on e.id=d.event_id order by d.id;"
+	return queryDeliveries(db.Conn, query, limit, lease.Seconds())
+}
+
+// RecordDeliveryAttempt stores the outcome of posting a claimed delivery.
+func (db *DatabasePostgres) RecordDeliveryAttempt(id int64, attempt models.DeliveryAttempt) error {
+	query := "update webhook_deliveries set status=$1, response_status=nullif($2, 0), last_error=nullif($3, ''), " +
+		"next_attempt_at=$4, delivered_at=case when $1='delivered' then now() end where id=$5;"
+	_, err := db.Conn.Exec(query, attempt.Status, attempt.ResponseStatus, attempt.Error, attempt.NextAttemptAt, id)
+	return err
+}
+
+// GetDeadDeliveries returns the deliveries that ran out of attempts, newest first.
+func (db *DatabasePostgres) GetDeadDeliveries(limit int) ([]models.Delivery, error) {
+	query := "select " + deliveryColumns + " from webhook_deliveries d join webhooks w on w.id=d.webhook_id " +
+		"join outbox e on e.id=d.event_id where d.status='dead' order by d.id desc limit $1;"
+	return queryDeliveries(db.Conn, query, limit)
+}
+
+// RetryDelivery puts a dead delivery back in the queue with a fresh set of attempts.
+func (db *DatabasePostgres) RetryDelivery(id int64) error {
+	query := "update webhook_deliveries set status='pending', attempts=0, next_attempt_at=now() where id=$1 and status='dead';"
+	res, err := db.Conn.Exec(query, id)
+	if err != nil {
+		return err
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if aff == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
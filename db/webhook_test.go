@@ -0,0 +1,122 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+var deliveryColumnNames = []string{"id", "webhook_id", "url", "secret", "event_id", "type", "book_id", "data", "created_at",
+	"status", "attempts", "next_attempt_at", "response_status", "last_error", "delivered_at"}
+
+func TestDatabasePostgres_AddWebhook(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error in creating mock: %s", err)
+	}
+	db := DatabasePostgres{Conn: conn}
+	webhook := models.Webhook{URL: "https://shop.example.com/hook", Secret: "0123456789abcdef",
+		Events: []string{models.EventBookCreated, models.EventBookDeleted}}
+
+	mock.ExpectQuery("insert into webhooks").
+		WithArgs(webhook.URL, webhook.Secret, pq.Array(webhook.Events)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	id, err := db.AddWebhook(webhook)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, id)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDatabasePostgres_GetWebhookById(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error in creating mock: %s", err)
+	}
+	db := DatabasePostgres{Conn: conn}
+	created := time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("select id, url, events, created_at from webhooks where id=\\$1").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "url", "events", "created_at"}).
+			AddRow(1, "https://shop.example.com/hook", "{book.created,book.deleted}", created))
+
+	webhook, err := db.GetWebhookById(1)
+	assert.NoError(t, err)
+	assert.Equal(t, models.Webhook{ID: 1, URL: "https://shop.example.com/hook",
+		Events: []string{models.EventBookCreated, models.EventBookDeleted}, CreatedAt: created}, webhook)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDatabasePostgres_ClaimDeliveries(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error in creating mock: %s", err)
+	}
+	db := DatabasePostgres{Conn: conn}
+	created := time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)
+	next := created.Add(time.Minute)
+
+	mock.ExpectQuery("with claimed as \\(update webhook_deliveries set attempts=attempts\\+1,(.+) for update skip locked\\)").
+		WithArgs(20, float64(60)).
+		WillReturnRows(sqlmock.NewRows(deliveryColumnNames).
+			AddRow(3, 1, "https://shop.example.com/hook", "0123456789abcdef", 7, models.EventBookCreated, 2,
+				[]byte(`{"id":2}`), created, models.DeliveryPending, 1, next, 0, "", nil))
+
+	list, err := db.ClaimDeliveries(20, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Delivery{{ID: 3, WebhookID: 1, URL: "https://shop.example.com/hook", Secret: "0123456789abcdef",
+		Event:  models.Event{ID: 7, Type: models.EventBookCreated, BookID: 2, Data: json.RawMessage(`{"id":2}`), CreatedAt: created},
+		Status: models.DeliveryPending, Attempts: 1, NextAttemptAt: next}}, list)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDatabasePostgres_RecordDeliveryAttempt(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error in creating mock: %s", err)
+	}
+	db := DatabasePostgres{Conn: conn}
+	next := time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec("update webhook_deliveries set status=\\$1").
+		WithArgs(models.DeliveryPending, 502, "webhook responded with 502 Bad Gateway", next, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = db.RecordDeliveryAttempt(3, models.DeliveryAttempt{Status: models.DeliveryPending, ResponseStatus: 502,
+		Error: "webhook responded with 502 Bad Gateway", NextAttemptAt: next})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDatabasePostgres_RetryDelivery(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error in creating mock: %s", err)
+	}
+	db := DatabasePostgres{Conn: conn}
+	tests := []struct {
+		name      string
+		affected  int64
+		returnErr error
+	}{
+		{name: "OK", affected: 1},
+		{name: "Not dead", affected: 0, returnErr: sql.ErrNoRows},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mock.ExpectExec("update webhook_deliveries set status='pending', attempts=0,(.+) and status='dead'").
+				WithArgs(3).
+				WillReturnResult(sqlmock.NewResult(0, test.affected))
+
+			err := db.RetryDelivery(3)
+			assert.Equal(t, test.returnErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package events
+
+import "github.com/porky256/rest-api/models"
+
+// Bus is the in-process event bus. Published events are kept in the log for
+// SSE clients and dispatched to the WebSocket subscribers of the hub.
+type Bus struct {
+	*Log
+	Hub *Hub
+	// ChangeFeed is set when book events reach the bus from the database
+	// change feed, writers then leave publishing them to it.
+	ChangeFeed bool
+}
+
+func NewBus(capacity int) *Bus {
+	return &Bus{Log: NewLog(capacity), Hub: NewHub()}
+}
+
+// Close ends the SSE subscriptions of the log and disconnects the WebSocket
+// subscribers of the hub, so a server can shut down.
+func (b *Bus) Close() {
+	b.Log.Close()
+	b.Hub.Close()
+}
+
+// BookReader reads stored books, see DeletedBook.
+type BookReader interface {
+	GetBookById(id int) (models.Book, error)
+}
+
+// DeletedBook returns the data of the book.deleted event for a book about to
+// be deleted. Subscribers filtering by genre need to know what is being
+// deleted, so that is the book as stored, or only its ID when it can't be
+// read. Nothing is read when writers don't publish to the bus.
+func (b *Bus) DeletedBook(books BookReader, id int) interface{} {
+	if b != nil && !b.ChangeFeed {
+		if book, err := books.GetBookById(id); err == nil {
+			return book
+		}
+	}
+	return map[string]int{"id": id}
+}
+
+// Reset publishes a reset event to every subscriber, whatever it subscribed
+// to, for when book events may have been lost on their way to the bus.
+func (b *Bus) Reset() models.Event {
+	return b.Publish(models.EventReset, 0, nil)
+}
+
+func (b *Bus) Publish(eventType string, bookID int, data interface{}) models.Event {
+	event := b.Log.Publish(eventType, bookID, data)
+	b.Hub.Dispatch(event)
+	return event
+}
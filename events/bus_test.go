@@ -0,0 +1,79 @@
+package events
+
+import (
+	"errors"
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBusPublish(t *testing.T) {
+	bus := NewBus(10)
+	subscriber := bus.Hub.Register(10)
+	subscriber.Subscribe([]int{1}, nil)
+	_, _, events, cancel := bus.Subscribe("")
+	defer cancel()
+
+	event := bus.Publish(models.EventBookCreated, 1, models.Book{ID: 1})
+
+	assert.Equal(t, event, <-events)
+	assert.Equal(t, event, <-subscriber.Events())
+}
+
+func TestBusClose(t *testing.T) {
+	bus := NewBus(10)
+	subscriber := bus.Hub.Register(10)
+	_, _, events, _ := bus.Subscribe("")
+
+	bus.Close()
+
+	_, open := <-events
+	assert.False(t, open, "the SSE subscription is ended")
+	<-subscriber.Closed()
+}
+
+func TestBusReset(t *testing.T) {
+	bus := NewBus(10)
+	subscribed := bus.Hub.Register(10)
+	subscribed.Subscribe([]int{1}, nil)
+	idle := bus.Hub.Register(10)
+	_, _, events, cancel := bus.Subscribe("")
+	defer cancel()
+
+	event := bus.Reset()
+
+	assert.Equal(t, models.EventReset, event.Type)
+	assert.Equal(t, event, <-events)
+	assert.Equal(t, event, <-subscribed.Events())
+	assert.Equal(t, event, <-idle.Events(), "reset events reach subscribers without subscriptions")
+}
+
+type bookReader map[int]models.Book
+
+func (r bookReader) GetBookById(id int) (models.Book, error) {
+	book, ok := r[id]
+	if !ok {
+		return book, errors.New("no such book")
+	}
+	return book, nil
+}
+
+func TestBusDeletedBook(t *testing.T) {
+	books := bookReader{1: {ID: 1, Genre: 3}}
+	tests := []struct {
+		name     string
+		bus      *Bus
+		id       int
+		expected interface{}
+	}{
+		{name: "stored book", bus: NewBus(10), id: 1, expected: models.Book{ID: 1, Genre: 3}},
+		{name: "unknown book", bus: NewBus(10), id: 2, expected: map[string]int{"id": 2}},
+		{name: "change feed", bus: &Bus{ChangeFeed: true}, id: 1, expected: map[string]int{"id": 1}},
+		{name: "no bus", id: 1, expected: map[string]int{"id": 1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, test.bus.DeletedBook(books, test.id))
+		})
+	}
+}
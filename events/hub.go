@@ -0,0 +1,162 @@
+package events
+
+import (
+	"encoding/json"
+	"github.com/porky256/rest-api/models"
+	"sort"
+	"sync"
+)
+
+// Hub fans events out to subscribers interested in particular books or
+// genres. Dispatching never blocks: a subscriber that doesn't keep up with
+// its buffer is marked as lagging and dropped, it has to resubscribe.
+type Hub struct {
+	mu          sync.RWMutex
+	subscribers map[*Subscriber]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{subscribers: map[*Subscriber]struct{}{}}
+}
+
+// Subscriber receives the events of the books and genres it subscribed to.
+type Subscriber struct {
+	events chan models.Event
+	lagged chan struct{}
+	closed chan struct{}
+
+	mu     sync.Mutex
+	books  map[int]bool
+	genres map[int]bool
+}
+
+// Register adds a subscriber without any subscriptions, buffering up to
+// buffer events.
+func (h *Hub) Register(buffer int) *Subscriber {
+	if buffer <= 0 {
+		buffer = DefaultBufferSize
+	}
+	s := &Subscriber{
+		events: make(chan models.Event, buffer),
+		lagged: make(chan struct{}),
+		closed: make(chan struct{}),
+		books:  map[int]bool{},
+		genres: map[int]bool{},
+	}
+	h.mu.Lock()
+	h.subscribers[s] = struct{}{}
+	h.mu.Unlock()
+	return s
+}
+
+func (h *Hub) Unregister(s *Subscriber) {
+	h.mu.Lock()
+	delete(h.subscribers, s)
+	h.mu.Unlock()
+}
+
+// Close disconnects every subscriber, letting open connections finish so a
+// server can shut down. Later subscribers are not affected.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subscribers {
+		delete(h.subscribers, s)
+		close(s.closed)
+	}
+}
+
+// Dispatch hands the event to every subscriber of its book or of the genre
+// found in its data, reset events to every subscriber.
+func (h *Hub) Dispatch(event models.Event) {
+	var book struct {
+		Genre int `json:"genre"`
+	}
+	_ = json.Unmarshal(event.Data, &book)
+
+	var lagging []*Subscriber
+	h.mu.RLock()
+	for s := range h.subscribers {
+		if event.Type != models.EventReset && !s.matches(event.BookID, book.Genre) {
+			continue
+		}
+		select {
+		case s.events <- event:
+		default:
+			lagging = append(lagging, s)
+		}
+	}
+	h.mu.RUnlock()
+
+	if len(lagging) == 0 {
+		return
+	}
+	h.mu.Lock()
+	for _, s := range lagging {
+		if _, ok := h.subscribers[s]; ok {
+			delete(h.subscribers, s)
+			close(s.lagged)
+		}
+	}
+	h.mu.Unlock()
+}
+
+// Events returns the channel the subscriber's events are delivered on.
+func (s *Subscriber) Events() <-chan models.Event {
+	return s.events
+}
+
+// Lagged is closed when the subscriber has been dropped for falling behind.
+func (s *Subscriber) Lagged() <-chan struct{} {
+	return s.lagged
+}
+
+// Closed is closed when the subscriber has been disconnected by closing the
+// hub.
+func (s *Subscriber) Closed() <-chan struct{} {
+	return s.closed
+}
+
+func (s *Subscriber) Subscribe(books, genres []int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range books {
+		s.books[id] = true
+	}
+	for _, genre := range genres {
+		s.genres[genre] = true
+	}
+}
+
+func (s *Subscriber) Unsubscribe(books, genres []int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range books {
+		delete(s.books, id)
+	}
+	for _, genre := range genres {
+		delete(s.genres, genre)
+	}
+}
+
+// Subscriptions returns the subscribed book IDs and genres in ascending order.
+func (s *Subscriber) Subscriptions() (books, genres []int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sortedKeys(s.books), sortedKeys(s.genres)
+}
+
+func (s *Subscriber) matches(bookID, genre int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.books[bookID] || (genre != 0 && s.genres[genre])
+}
+
+func sortedKeys(set map[int]bool) []int {
+	keys := make([]int, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+	return keys
+}
@@ -0,0 +1,83 @@
+package events
+
+import (
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestHubDispatch(t *testing.T) {
+	log := NewLog(10)
+	hub := NewHub()
+	books := hub.Register(10)
+	genres := hub.Register(10)
+	idle := hub.Register(10)
+	books.Subscribe([]int{1, 2}, nil)
+	genres.Subscribe(nil, []int{3})
+
+	hub.Dispatch(log.Publish(models.EventBookUpdated, 1, models.Book{ID: 1, Genre: 1}))
+	hub.Dispatch(log.Publish(models.EventBookUpdated, 5, models.Book{ID: 5, Genre: 3}))
+	hub.Dispatch(log.Publish(models.EventBookDeleted, 2, map[string]int{"id": 2}))
+	books.Unsubscribe([]int{1}, nil)
+	hub.Dispatch(log.Publish(models.EventBookUpdated, 1, models.Book{ID: 1, Genre: 3}))
+
+	assert.Equal(t, []int{1, 2}, received(books))
+	assert.Equal(t, []int{5, 1}, received(genres))
+	assert.Empty(t, received(idle))
+
+	bookIDs, genreIDs := books.Subscriptions()
+	assert.Equal(t, []int{2}, bookIDs)
+	assert.Equal(t, []int{}, genreIDs)
+}
+
+func TestHubDropsLaggingSubscriber(t *testing.T) {
+	log := NewLog(10)
+	hub := NewHub()
+	slow := hub.Register(1)
+	slow.Subscribe([]int{1}, nil)
+
+	hub.Dispatch(log.Publish(models.EventBookUpdated, 1, models.Book{ID: 1}))
+	select {
+	case <-slow.Lagged():
+		t.Fatal("dropped before the buffer was full")
+	default:
+	}
+
+	hub.Dispatch(log.Publish(models.EventBookUpdated, 1, models.Book{ID: 1}))
+	<-slow.Lagged()
+	hub.Dispatch(log.Publish(models.EventBookUpdated, 1, models.Book{ID: 1}))
+	assert.Equal(t, []int{1}, received(slow))
+	hub.Unregister(slow)
+}
+
+// received drains the events buffered for the subscriber and returns their book IDs.
+func received(s *Subscriber) []int {
+	var ids []int
+	for {
+		select {
+		case event := <-s.Events():
+			ids = append(ids, event.BookID)
+		default:
+			return ids
+		}
+	}
+}
+
+func TestHubClose(t *testing.T) {
+	hub := NewHub()
+	subscriber := hub.Register(10)
+	subscriber.Subscribe([]int{1}, nil)
+
+	hub.Close()
+	<-subscriber.Closed()
+	hub.Dispatch(NewLog(10).Publish(models.EventBookUpdated, 1, models.Book{ID: 1}))
+	assert.Empty(t, received(subscriber), "closed subscribers get no events")
+
+	later := hub.Register(10)
+	select {
+	case <-later.Closed():
+		t.Fatal("closed a subscriber registered after closing")
+	default:
+	}
+	hub.Unregister(later)
+}
@@ -0,0 +1,151 @@
+package events
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/porky256/rest-api/models"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultCapacity   = 1000
+	DefaultBufferSize = 64
+)
+
+// Log is a bounded in-memory log of recent book events which also fans new
+// events out to subscribers. Event IDs are sequence numbers prefixed with an
+// epoch that changes with every process, so a client resuming with an ID
+// from before a restart or from another instance is told to start over
+// instead of silently missing events.
+type Log struct {
+	mu          sync.Mutex
+	epoch       string
+	events      []models.Event
+	next        int
+	full        bool
+	seq         int64
+	subscribers map[chan models.Event]struct{}
+}
+
+func NewLog(capacity int) *Log {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	return &Log{
+		epoch:       newEpoch(),
+		events:      make([]models.Event, capacity),
+		subscribers: map[chan models.Event]struct{}{},
+	}
+}
+
+func newEpoch() string {
+	buf := make([]byte, 4)
+	if _, err := rand.Read(buf); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// Publish appends an event about the book, with data encoded as JSON, and
+// hands it to every subscriber.
+// A subscriber whose buffer is full is dropped rather than allowed to hold
+// up publishers, it can resume from the log after reconnecting.
+func (l *Log) Publish(eventType string, bookID int, data interface{}) models.Event {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		raw = []byte("null")
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.seq++
+	event := models.Event{ID: l.seq, Type: eventType, BookID: bookID, Data: raw, CreatedAt: time.Now().UTC()}
+	l.events[l.next] = event
+	l.next = (l.next + 1) % len(l.events)
+	if l.next == 0 {
+		l.full = true
+	}
+
+	for ch := range l.subscribers {
+		select {
+		case ch <- event:
+		default:
+			delete(l.subscribers, ch)
+			close(ch)
+		}
+	}
+	return event
+}
+
+// EventID returns the ID clients use to resume after the event.
+func (l *Log) EventID(event models.Event) string {
+	return l.epoch + "-" + strconv.FormatInt(event.ID, 10)
+}
+
+// Subscribe returns the events published after lastID together with a
+// channel receiving every later event. An empty lastID only subscribes.
+// ok is false when lastID is unknown or so old that events in between have
+// been dropped from the log, the client has to reload its state then. The
+// channel is closed when cancel is called or the subscriber falls behind.
+func (l *Log) Subscribe(lastID string) (missed []models.Event, ok bool, events <-chan models.Event, cancel func()) {
+	ch := make(chan models.Event, DefaultBufferSize)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ok = true
+	if lastID != "" {
+		missed, ok = l.since(lastID)
+	}
+	l.subscribers[ch] = struct{}{}
+	cancel = func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if _, found := l.subscribers[ch]; found {
+			delete(l.subscribers, ch)
+			close(ch)
+		}
+	}
+	return missed, ok, ch, cancel
+}
+
+// Close ends every subscription, letting open streams finish so a server
+// can shut down. Later subscribers are not affected.
+func (l *Log) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for ch := range l.subscribers {
+		delete(l.subscribers, ch)
+		close(ch)
+	}
+}
+
+func (l *Log) since(lastID string) ([]models.Event, bool) {
+	parts := strings.SplitN(lastID, "-", 2)
+	if len(parts) != 2 {
+		return nil, false
+	}
+	epoch, value := parts[0], parts[1]
+	seq, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || epoch != l.epoch || seq > l.seq {
+		return nil, false
+	}
+
+	oldest := int64(1)
+	if l.full {
+		oldest = l.seq - int64(len(l.events)) + 1
+	}
+	if seq < oldest-1 {
+		return nil, false
+	}
+
+	missed := make([]models.Event, 0, l.seq-seq)
+	for id := seq + 1; id <= l.seq; id++ {
+		index := (l.next - int(l.seq-id) - 1 + len(l.events)) % len(l.events)
+		missed = append(missed, l.events[index])
+	}
+	return missed, true
+}
@@ -0,0 +1,86 @@
+package events
+
+import (
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLogSubscribe(t *testing.T) {
+	log := NewLog(3)
+	log.Publish(models.EventBookCreated, 1, models.Book{ID: 1})
+	for id := 2; id <= 4; id++ {
+		log.Publish(models.EventBookUpdated, id, models.Book{ID: id})
+	}
+
+	tests := []struct {
+		name   string
+		lastID string
+		missed []int
+		ok     bool
+	}{
+		{name: "new client", ok: true},
+		{name: "up to date", lastID: log.epoch + "-4", ok: true},
+		{name: "resume", lastID: log.epoch + "-2", missed: []int{3, 4}, ok: true},
+		{name: "oldest kept", lastID: log.epoch + "-1", missed: []int{2, 3, 4}, ok: true},
+		{name: "dropped from log", lastID: log.epoch + "-0"},
+		{name: "other epoch", lastID: "cafebabe-2"},
+		{name: "from the future", lastID: log.epoch + "-9"},
+		{name: "garbage", lastID: "garbage"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			missed, ok, _, cancel := log.Subscribe(test.lastID)
+			defer cancel()
+
+			var ids []int
+			for _, event := range missed {
+				ids = append(ids, event.BookID)
+			}
+			assert.Equal(t, test.ok, ok)
+			assert.Equal(t, test.missed, ids)
+		})
+	}
+}
+
+func TestLogPublish(t *testing.T) {
+	log := NewLog(10)
+	_, _, events, cancel := log.Subscribe("")
+	defer cancel()
+
+	event := log.Publish(models.EventBookDeleted, 7, map[string]int{"id": 7})
+	assert.Equal(t, log.epoch+"-1", log.EventID(event))
+	received := <-events
+	assert.Equal(t, event, received)
+	assert.Equal(t, `{"id":7}`, string(received.Data))
+
+	cancel()
+	_, open := <-events
+	assert.False(t, open)
+	cancel()
+}
+
+func TestLogClose(t *testing.T) {
+	log := NewLog(10)
+	_, _, events, cancel := log.Subscribe("")
+	defer cancel()
+
+	log.Close()
+	_, open := <-events
+	assert.False(t, open)
+}
+
+func TestLogDropsSlowSubscriber(t *testing.T) {
+	log := NewLog(10)
+	_, _, slow, cancel := log.Subscribe("")
+	defer cancel()
+
+	for i := 0; i <= DefaultBufferSize; i++ {
+		log.Publish(models.EventBookUpdated, 1, models.Book{ID: 1})
+	}
+	received := 0
+	for range slow {
+		received++
+	}
+	assert.Equal(t, DefaultBufferSize, received)
+}
@@ -0,0 +1,17 @@
+// Package booktest holds the book the tests of the API's servers and clients
+// share.
+package booktest
+
+import (
+	"github.com/porky256/rest-api/models"
+	"time"
+)
+
+// Created is when the test book was created and last updated.
+var Created = time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)
+
+// Book returns Dune by Frank Herbert with the given ID.
+func Book(id int) models.Book {
+	return models.Book{ID: id, Name: "Dune", Authors: []string{"Frank Herbert"}, Price: models.MustParseDecimal("9.99"),
+		Currency: "USD", Genre: 3, Amount: 4, CreatedAt: Created, UpdatedAt: Created}
+}
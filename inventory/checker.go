@@ -0,0 +1,100 @@
+package inventory
+
+import (
+	"context"
+	"github.com/porky256/rest-api/models"
+	"log"
+	"time"
+)
+
+const (
+	EventLowStock        = "stock.low"
+	DefaultCheckInterval = time.Minute
+	defaultNotifyTimeout = 10 * time.Second
+)
+
+// Store is the part of db.Database the checker depends on.
+type Store interface {
+	ClaimLowStockAlerts() ([]models.LowStockBook, error)
+	ReleaseLowStockAlert(bookID int) error
+}
+
+// Alert is emitted once when a book's amount drops below its threshold.
+type Alert struct {
+	Event string              `json:"event"`
+	Book  models.LowStockBook `json:"book"`
+	At    time.Time           `json:"at"`
+}
+
+// Checker periodically looks for books that have gone low on stock and
+// passes an alert for each of them to every notifier. The crossing state is
+// kept in the database, so stock changed by any mutation path, by another
+// instance or while the checker was down is picked up on the next check.
+type Checker struct {
+	Store     Store
+	Interval  time.Duration
+	Notifiers []Notifier
+}
+
+func NewChecker(store Store, interval time.Duration, notifiers ...Notifier) *Checker {
+	if interval <= 0 {
+		interval = DefaultCheckInterval
+	}
+	return &Checker{Store: store, Interval: interval, Notifiers: notifiers}
+}
+
+// Run checks stock right away and then every Interval until ctx is done.
+func (checker *Checker) Run(ctx context.Context) {
+	ticker := time.NewTicker(checker.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := checker.Check(ctx); err != nil {
+			log.Println("low stock check: ", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check claims the books that have newly dropped below their threshold and
+// notifies about them, returning the alerts every notifier took. A failing
+// notifier doesn't stop the others, but its alert is released so the next
+// check sends it again, to the notifiers that took it as well.
+func (checker *Checker) Check(ctx context.Context) ([]Alert, error) {
+	books, err := checker.Store.ClaimLowStockAlerts()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	alerts := make([]Alert, 0, len(books))
+	for _, book := range books {
+		alert := Alert{Event: EventLowStock, Book: book, At: now}
+		if !checker.notify(ctx, alert) {
+			if err := checker.Store.ReleaseLowStockAlert(book.ID); err != nil {
+				log.Println("low stock release: ", err)
+			}
+			continue
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, nil
+}
+
+// notify passes the alert to every notifier and reports whether all of them
+// took it.
+func (checker *Checker) notify(ctx context.Context, alert Alert) bool {
+	delivered := true
+	for _, notifier := range checker.Notifiers {
+		notifyCtx, cancel := context.WithTimeout(ctx, defaultNotifyTimeout)
+		if err := notifier.Notify(notifyCtx, alert); err != nil {
+			log.Println("low stock notify: ", err)
+			delivered = false
+		}
+		cancel()
+	}
+	return delivered
+}
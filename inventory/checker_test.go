@@ -0,0 +1,141 @@
+package inventory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type storeFunc func() ([]models.LowStockBook, error)
+
+func (f storeFunc) ClaimLowStockAlerts() ([]models.LowStockBook, error) {
+	return f()
+}
+
+func (f storeFunc) ReleaseLowStockAlert(bookID int) error {
+	return nil
+}
+
+// claimStore hands out the books as claimed until they are released.
+type claimStore struct {
+	books    []models.LowStockBook
+	claimed  map[int]bool
+	released []int
+}
+
+func (store *claimStore) ClaimLowStockAlerts() ([]models.LowStockBook, error) {
+	var list []models.LowStockBook
+	for _, book := range store.books {
+		if !store.claimed[book.ID] {
+			store.claimed[book.ID] = true
+			list = append(list, book)
+		}
+	}
+	return list, nil
+}
+
+func (store *claimStore) ReleaseLowStockAlert(bookID int) error {
+	delete(store.claimed, bookID)
+	store.released = append(store.released, bookID)
+	return nil
+}
+
+type notifierFunc func(ctx context.Context, alert Alert) error
+
+func (f notifierFunc) Notify(ctx context.Context, alert Alert) error {
+	return f(ctx, alert)
+}
+
+func TestCheckerCheck(t *testing.T) {
+	book := models.LowStockBook{Book: models.Book{ID: 1, Name: "Dune", Price: models.MustParseDecimal("10.00"),
+		Currency: "USD", Genre: 3, Amount: 1}, Threshold: 5}
+
+	var received []Alert
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert Alert
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&alert))
+		received = append(received, alert)
+	}))
+	defer receiver.Close()
+
+	store := &claimStore{books: []models.LowStockBook{book}, claimed: map[int]bool{}}
+	checker := NewChecker(store, 0, LogNotifier{}, WebhookNotifier{URL: receiver.URL})
+	assert.Equal(t, DefaultCheckInterval, checker.Interval)
+
+	alerts, err := checker.Check(context.Background())
+	assert.NoError(t, err)
+	if assert.Len(t, alerts, 1) {
+		assert.Equal(t, EventLowStock, alerts[0].Event)
+		assert.Equal(t, book, alerts[0].Book)
+	}
+	if assert.Len(t, received, 1) {
+		assert.Equal(t, book, received[0].Book)
+	}
+	assert.Empty(t, store.released)
+
+	alerts, err = checker.Check(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, alerts, "an alert is sent once")
+	assert.Len(t, received, 1)
+}
+
+func TestCheckerCheckNotifierFailure(t *testing.T) {
+	book := models.LowStockBook{Book: models.Book{ID: 1, Name: "Dune", Price: models.MustParseDecimal("10.00"),
+		Currency: "USD", Genre: 3, Amount: 1}, Threshold: 5}
+
+	var received []Alert
+	fails := true
+	flaky := notifierFunc(func(ctx context.Context, alert Alert) error {
+		if fails {
+			return errors.New("unavailable")
+		}
+		received = append(received, alert)
+		return nil
+	})
+	store := &claimStore{books: []models.LowStockBook{book}, claimed: map[int]bool{}}
+	checker := NewChecker(store, 0, flaky)
+
+	alerts, err := checker.Check(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, alerts)
+	assert.Equal(t, []int{1}, store.released, "the alert is released for the next check")
+
+	fails = false
+	alerts, err = checker.Check(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, alerts, 1)
+	if assert.Len(t, received, 1) {
+		assert.Equal(t, book, received[0].Book)
+	}
+	assert.Equal(t, []int{1}, store.released)
+}
+
+func TestCheckerCheckStoreError(t *testing.T) {
+	store := storeFunc(func() ([]models.LowStockBook, error) {
+		return nil, errors.New("database error")
+	})
+	notifier := notifierFunc(func(ctx context.Context, alert Alert) error {
+		t.Fatal("notified without alerts")
+		return nil
+	})
+
+	alerts, err := NewChecker(store, 0, notifier).Check(context.Background())
+	assert.Error(t, err)
+	assert.Empty(t, alerts)
+}
+
+func TestWebhookNotifierFailure(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	err := WebhookNotifier{URL: receiver.URL}.Notify(context.Background(), Alert{Event: EventLowStock})
+	assert.Error(t, err)
+}
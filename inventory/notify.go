@@ -0,0 +1,56 @@
+package inventory
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// Notifier delivers a low stock alert somewhere.
+type Notifier interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// LogNotifier writes alerts to the standard logger.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(ctx context.Context, alert Alert) error {
+	log.Printf("low stock: book %d %q has %d left, threshold is %d",
+		alert.Book.ID, alert.Book.Name, alert.Book.Amount, alert.Book.Threshold)
+	return nil
+}
+
+// WebhookNotifier posts alerts as JSON to a URL, any non-2xx response counts as a failure.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+func (notifier WebhookNotifier) Notify(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, notifier.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := notifier.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s responded with %s", notifier.URL, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package lifecycle
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"google.golang.org/grpc"
+	"net"
+	"net/http"
+)
+
+// Server serves on a listener bound when it starts, so an address in use
+// fails the start instead of leaving the service running without it.
+type Server struct {
+	Addr string
+	// Serve serves on the listener until Shutdown, http.ErrServerClosed
+	// counts as a clean stop.
+	Serve func(listener net.Listener) error
+	// Shutdown stops accepting and drains the requests in flight.
+	Shutdown func(ctx context.Context) error
+}
+
+func (s *Server) Start(ctx context.Context, fail func(error)) error {
+	var config net.ListenConfig
+	listener, err := config.Listen(ctx, "tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := s.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fail(err)
+		}
+	}()
+	return nil
+}
+
+func (s *Server) Stop(ctx context.Context) error {
+	return s.Shutdown(ctx)
+}
+
+// HTTPServer serves server on its address. Connections still open when the
+// stop deadline passes are closed.
+func HTTPServer(server *http.Server) *Server {
+	return &Server{
+		Addr:  server.Addr,
+		Serve: server.Serve,
+		Shutdown: func(ctx context.Context) error {
+			err := server.Shutdown(ctx)
+			if err != nil {
+				server.Close()
+			}
+			return err
+		},
+	}
+}
+
+// GRPCServer serves server on addr. Calls in flight when the stop deadline
+// passes are cancelled.
+func GRPCServer(server *grpc.Server, addr string) *Server {
+	return &Server{
+		Addr:  addr,
+		Serve: server.Serve,
+		Shutdown: func(ctx context.Context) error {
+			stopped := make(chan struct{})
+			go func() {
+				server.GracefulStop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+				return nil
+			case <-ctx.Done():
+				server.Stop()
+				return ctx.Err()
+			}
+		},
+	}
+}
+
+// Worker runs a background job until it is stopped.
+type Worker struct {
+	run    func(ctx context.Context)
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewWorker makes a worker of run, which returns once its context is done.
+func NewWorker(run func(ctx context.Context)) *Worker {
+	return &Worker{run: run}
+}
+
+// Start runs the job with a context of its own, which outlives the one of
+// the service until the worker is stopped.
+func (w *Worker) Start(_ context.Context, _ func(error)) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel, w.done = cancel, make(chan struct{})
+	go func() {
+		defer close(w.done)
+		w.run(ctx)
+	}()
+	return nil
+}
+
+// Stop cancels the job and waits for it to return.
+func (w *Worker) Stop(ctx context.Context) error {
+	w.cancel()
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Database checks the connection on start and closes it on stop.
+type Database struct {
+	Conn *sql.DB
+}
+
+func (d Database) Start(ctx context.Context, _ func(error)) error {
+	return d.Conn.PingContext(ctx)
+}
+
+func (d Database) Stop(context.Context) error {
+	return d.Conn.Close()
+}
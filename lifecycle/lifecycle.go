@@ -0,0 +1,98 @@
+// Package lifecycle starts the parts of the service in order and stops them
+// in reverse order within a deadline.
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+const DefaultStopTimeout = 5 * time.Second
+
+// Component is a part of the service. Start returns once the component runs,
+// e.g. after its listener is bound, and passes failures after that to fail,
+// which stops the service. Stop returns once the component has stopped or
+// ctx is done.
+type Component interface {
+	Start(ctx context.Context, fail func(error)) error
+	Stop(ctx context.Context) error
+}
+
+type namedComponent struct {
+	name string
+	Component
+}
+
+// Manager starts the components of the service in the order they were added
+// and stops them in reverse order, so the servers drain before the workers
+// and the database they use go away.
+type Manager struct {
+	// StopTimeout is the time all components together have to stop, draining
+	// in-flight requests included.
+	StopTimeout time.Duration
+	components  []namedComponent
+}
+
+func NewManager(stopTimeout time.Duration) *Manager {
+	if stopTimeout <= 0 {
+		stopTimeout = DefaultStopTimeout
+	}
+	return &Manager{StopTimeout: stopTimeout}
+}
+
+// Add adds a component started after and stopped before the ones added
+// earlier.
+func (m *Manager) Add(name string, component Component) {
+	m.components = append(m.components, namedComponent{name: name, Component: component})
+}
+
+// Run starts the components and stops the started ones once ctx is done, a
+// component fails to start or a running one fails. It returns the failure,
+// or else the first error stopping a component, e.g. requests still in
+// flight after StopTimeout.
+func (m *Manager) Run(ctx context.Context) error {
+	failed := make(chan error, len(m.components))
+	var err error
+	started := 0
+	for _, component := range m.components {
+		name := component.name
+		fail := func(err error) {
+			select {
+			case failed <- fmt.Errorf("%s: %w", name, err):
+			default:
+			}
+		}
+		if err = component.Start(ctx, fail); err != nil {
+			err = fmt.Errorf("starting %s: %w", name, err)
+			break
+		}
+		started++
+	}
+
+	if err == nil {
+		select {
+		case <-ctx.Done():
+		case err = <-failed:
+		}
+	}
+	if err != nil {
+		log.Println(err.Error())
+	}
+	log.Println("Shutting down...")
+
+	stopCtx, cancel := context.WithTimeout(context.Background(), m.StopTimeout)
+	defer cancel()
+	for i := started - 1; i >= 0; i-- {
+		component := m.components[i]
+		if stopErr := component.Stop(stopCtx); stopErr != nil {
+			stopErr = fmt.Errorf("stopping %s: %w", component.name, stopErr)
+			log.Println(stopErr.Error())
+			if err == nil {
+				err = stopErr
+			}
+		}
+	}
+	return err
+}
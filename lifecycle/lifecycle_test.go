@@ -0,0 +1,249 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+var errBoom = errors.New("boom")
+
+// recorder records starting and stopping, failing as it is told to.
+type recorder struct {
+	name     string
+	events   *[]string
+	startErr error
+	runErr   error
+	stopErr  error
+}
+
+func (r recorder) Start(ctx context.Context, fail func(error)) error {
+	*r.events = append(*r.events, "start "+r.name)
+	if r.runErr != nil {
+		fail(r.runErr)
+	}
+	return r.startErr
+}
+
+func (r recorder) Stop(ctx context.Context) error {
+	*r.events = append(*r.events, "stop "+r.name)
+	return r.stopErr
+}
+
+func TestManagerRun(t *testing.T) {
+	tests := []struct {
+		name           string
+		components     func(events *[]string) []recorder
+		interrupted    bool
+		expectedEvents []string
+		expectedError  string
+	}{
+		{
+			name: "stops in reverse order",
+			components: func(events *[]string) []recorder {
+				return []recorder{{name: "db", events: events}, {name: "worker", events: events}, {name: "http", events: events}}
+			},
+			interrupted:    true,
+			expectedEvents: []string{"start db", "start worker", "start http", "stop http", "stop worker", "stop db"},
+		},
+		{
+			name: "start fails",
+			components: func(events *[]string) []recorder {
+				return []recorder{{name: "db", events: events}, {name: "http", events: events, startErr: errBoom}, {name: "grpc", events: events}}
+			},
+			expectedEvents: []string{"start db", "start http", "stop db"},
+			expectedError:  "starting http: boom",
+		},
+		{
+			name: "running component fails",
+			components: func(events *[]string) []recorder {
+				return []recorder{{name: "db", events: events}, {name: "http", events: events, runErr: errBoom}}
+			},
+			expectedEvents: []string{"start db", "start http", "stop http", "stop db"},
+			expectedError:  "http: boom",
+		},
+		{
+			name: "stop fails",
+			components: func(events *[]string) []recorder {
+				return []recorder{{name: "db", events: events}, {name: "http", events: events, stopErr: errBoom}}
+			},
+			interrupted:    true,
+			expectedEvents: []string{"start db", "start http", "stop http", "stop db"},
+			expectedError:  "stopping http: boom",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var events []string
+			manager := NewManager(time.Second)
+			for _, component := range test.components(&events) {
+				manager.Add(component.name, component)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			if test.interrupted {
+				cancel()
+			}
+			defer cancel()
+
+			err := manager.Run(ctx)
+			if test.expectedError != "" {
+				assert.EqualError(t, err, test.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, test.expectedEvents, events)
+		})
+	}
+}
+
+func TestServerAddressInUse(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taken.Close()
+
+	stopped := false
+	manager := NewManager(time.Second)
+	manager.Add("worker", NewWorker(func(ctx context.Context) {
+		<-ctx.Done()
+		stopped = true
+	}))
+	manager.Add("http", HTTPServer(&http.Server{Addr: taken.Addr().String()}))
+
+	err = manager.Run(context.Background())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "starting http: ")
+	assert.True(t, stopped, "the worker started before is stopped")
+}
+
+func TestServerDrain(t *testing.T) {
+	tests := []struct {
+		name          string
+		requestTime   time.Duration
+		expectedError error
+	}{
+		{
+			name:        "requests finish in time",
+			requestTime: 50 * time.Millisecond,
+		},
+		{
+			name:          "requests outlast the deadline",
+			requestTime:   time.Second,
+			expectedError: context.DeadlineExceeded,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entered := make(chan struct{})
+			server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(entered)
+				time.Sleep(test.requestTime)
+			})}
+			addr := make(chan string, 1)
+			manager := NewManager(200 * time.Millisecond)
+			manager.Add("http", &Server{
+				Addr: "127.0.0.1:0",
+				Serve: func(listener net.Listener) error {
+					addr <- listener.Addr().String()
+					return server.Serve(listener)
+				},
+				Shutdown: server.Shutdown,
+			})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			done := make(chan error, 1)
+			go func() {
+				done <- manager.Run(ctx)
+			}()
+			responses := make(chan error, 1)
+			go func() {
+				response, err := http.Get("http://" + <-addr)
+				if err == nil {
+					response.Body.Close()
+				}
+				responses <- err
+			}()
+			<-entered
+			cancel()
+
+			err := <-done
+			if test.expectedError != nil {
+				assert.True(t, errors.Is(err, test.expectedError), "unexpected error %v", err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.NoError(t, <-responses, "the request in flight is answered")
+		})
+	}
+}
+
+func TestHTTPServerCloses(t *testing.T) {
+	entered := make(chan struct{})
+	cancelled := make(chan struct{})
+	component := HTTPServer(&http.Server{Addr: "127.0.0.1:0", Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		select {
+		case <-r.Context().Done():
+			close(cancelled)
+		case <-time.After(5 * time.Second):
+		}
+	})})
+	addr := make(chan string, 1)
+	serve := component.Serve
+	component.Serve = func(listener net.Listener) error {
+		addr <- listener.Addr().String()
+		return serve(listener)
+	}
+	manager := NewManager(100 * time.Millisecond)
+	manager.Add("http", component)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- manager.Run(ctx)
+	}()
+	responses := make(chan error, 1)
+	go func() {
+		response, err := http.Get("http://" + <-addr)
+		if err == nil {
+			response.Body.Close()
+		}
+		responses <- err
+	}()
+	<-entered
+	cancel()
+
+	err := <-done
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "unexpected error %v", err)
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("the request outliving the deadline isn't cancelled")
+	}
+	assert.Error(t, <-responses, "the connection is closed")
+}
+
+func TestWorkerStop(t *testing.T) {
+	returned := make(chan struct{})
+	worker := NewWorker(func(ctx context.Context) {
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		close(returned)
+	})
+	assert.NoError(t, worker.Start(context.Background(), func(error) {}))
+	assert.NoError(t, worker.Stop(context.Background()))
+	select {
+	case <-returned:
+	default:
+		t.Fatal("Stop returned before the worker")
+	}
+}
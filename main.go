@@ -3,16 +3,39 @@ package main
 import (
 	"context"
 	"github.com/porky256/rest-api/api"
+	"github.com/porky256/rest-api/cache"
 	"github.com/porky256/rest-api/db"
+	"github.com/porky256/rest-api/inventory"
+	"github.com/porky256/rest-api/lifecycle"
+	"github.com/porky256/rest-api/models"
+	"github.com/porky256/rest-api/rpc"
+	"github.com/porky256/rest-api/webhook"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 )
 
+const (
+	defaultCacheTTL  = 30 * time.Second
+	defaultCacheSize = 1000
+	httpAddr         = ":8080"
+	grpcAddr         = ":9090"
+)
+
 func main() {
+	if err := run(); err != nil {
+		log.Println(err.Error())
+		os.Exit(1)
+	}
+	log.Println("Server shut down")
+}
+
+// run serves until SIGINT or SIGTERM, or until a part of the service fails.
+func run() error {
 	dbUser, dbPassword, dbName :=
 		os.Getenv("POSTGRES_USER"),
 		os.Getenv("POSTGRES_PASSWORD"),
@@ -21,29 +44,97 @@ func main() {
 
 	//	dataBase, err := db.Initialize("postgres", "2341", "books")
 	if err != nil {
-		log.Fatalln(err)
-		return
+		return err
 	}
-	defer dataBase.Conn.Close()
+	service := lifecycle.NewManager(shutdownTimeout())
+	service.Add("database", lifecycle.Database{Conn: dataBase.Conn})
+
+	service.Add("low stock checker", lifecycle.NewWorker(newStockChecker(&dataBase).Run))
+	deliveries := webhook.NewWorker(&dataBase)
+	service.Add("webhook deliveries", lifecycle.NewWorker(deliveries.Run))
 
-	handler := api.InitializeHandler(&dataBase)
-	server := &http.Server{Addr: ":8080", Handler: handler.Router}
-	go func() {
-		if err := server.ListenAndServe(); err != nil {
-			log.Println("listen: ", err)
+	cached := newCachedDatabase(&dataBase)
+	handler := api.InitializeHandler(cached)
+	if feed, err := db.ListenBookEvents(db.ConnectionString(dbUser, dbPassword, dbName)); err != nil {
+		log.Println("change feed unavailable, only local changes are streamed: ", err)
+	} else {
+		handler.Events.ChangeFeed = true
+		feed.Reconnected = func() {
+			log.Println("change feed reconnected, changes in between were missed")
+			cached.Invalidate()
+			handler.Events.Reset()
 		}
-	}()
+		service.Add("change feed", lifecycle.NewWorker(func(ctx context.Context) {
+			feed.Run(ctx, func(eventType string, book models.Book) {
+				cached.Invalidate()
+				handler.Events.Publish(eventType, book.ID, book)
+				deliveries.Wake()
+			})
+		}))
+	}
 
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-	log.Println("Shutting down server...")
+	books := rpc.NewGRPCServer(rpc.NewServer(cached, handler.Events))
+	service.Add("grpc server", lifecycle.GRPCServer(books, grpcAddr))
+	// Closing the event bus on shutdown ends the event streams, which the
+	// gRPC server stopped next waits for as well.
+	server := &http.Server{Addr: httpAddr, Handler: handler.Router}
+	server.RegisterOnShutdown(handler.Events.Close)
+	service.Add("http server", lifecycle.HTTPServer(server))
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	return service.Run(ctx)
+}
 
-	if err = server.Shutdown(ctx); err != nil {
-		log.Fatalln("Server error: ", err)
+// shutdownTimeout is SHUTDOWN_TIMEOUT, the time in-flight requests have to
+// finish on shutdown.
+func shutdownTimeout() time.Duration {
+	value := os.Getenv("SHUTDOWN_TIMEOUT")
+	if value == "" {
+		return lifecycle.DefaultStopTimeout
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		log.Println("invalid SHUTDOWN_TIMEOUT: ", err)
+		return lifecycle.DefaultStopTimeout
+	}
+	return timeout
+}
+
+// newCachedDatabase puts a cache of CACHE_SIZE entries kept for CACHE_TTL in
+// front of the database, a zero TTL turns caching off.
+func newCachedDatabase(database db.Database) *db.CachedDatabase {
+	ttl, size := defaultCacheTTL, defaultCacheSize
+	if value := os.Getenv("CACHE_TTL"); value != "" {
+		var err error
+		if ttl, err = time.ParseDuration(value); err != nil {
+			log.Println("invalid CACHE_TTL: ", err)
+			ttl = defaultCacheTTL
+		}
+	}
+	if value := os.Getenv("CACHE_SIZE"); value != "" {
+		var err error
+		if size, err = strconv.Atoi(value); err != nil {
+			log.Println("invalid CACHE_SIZE: ", err)
+			size = defaultCacheSize
+		}
+	}
+	return db.NewCachedDatabase(database, cache.NewLRU(size), ttl)
+}
+
+// newStockChecker sets up the low stock checker from LOW_STOCK_CHECK_INTERVAL
+// and LOW_STOCK_WEBHOOK_URL, alerts are always logged.
+func newStockChecker(store inventory.Store) *inventory.Checker {
+	var interval time.Duration
+	if value := os.Getenv("LOW_STOCK_CHECK_INTERVAL"); value != "" {
+		var err error
+		if interval, err = time.ParseDuration(value); err != nil {
+			log.Println("invalid LOW_STOCK_CHECK_INTERVAL: ", err)
+		}
+	}
+	notifiers := []inventory.Notifier{inventory.LogNotifier{}}
+	if url := os.Getenv("LOW_STOCK_WEBHOOK_URL"); url != "" {
+		notifiers = append(notifiers, inventory.WebhookNotifier{URL: url})
 	}
-	log.Println("Server shouted down")
+	return inventory.NewChecker(store, interval, notifiers...)
 }
@@ -0,0 +1,14 @@
+create table if not exists audit_log(
+                      id serial not null primary key,
+                      book_id int not null,
+                      action varchar(16) not null,
+                      actor varchar(100) not null,
+                      request_id varchar(100) not null,
+                      before jsonb,
+                      after jsonb,
+                      diff jsonb not null,
+                      created_at timestamptz not null default now()
+);
+
+create index if not exists audit_log_book_id_idx on audit_log(book_id, created_at);
+create index if not exists audit_log_created_at_idx on audit_log(created_at);
@@ -0,0 +1,6 @@
+delete from books where deleted_at is not null;
+
+drop index if exists books_name_active_idx;
+alter table books add constraint books_name_key unique (name);
+
+alter table books drop column if exists deleted_at;
@@ -0,0 +1,4 @@
+alter table books add column if not exists deleted_at timestamptz;
+
+alter table books drop constraint if exists books_name_key;
+create unique index if not exists books_name_active_idx on books(name) where deleted_at is null;
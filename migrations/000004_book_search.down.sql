@@ -0,0 +1,4 @@
+drop index if exists books_name_trgm_idx;
+drop index if exists books_search_idx;
+
+alter table books drop column if exists search;
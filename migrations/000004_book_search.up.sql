@@ -0,0 +1,7 @@
+create extension if not exists pg_trgm;
+
+alter table books add column if not exists search tsvector
+    generated always as (to_tsvector('simple', name)) stored;
+
+create index if not exists books_search_idx on books using gin(search);
+create index if not exists books_name_trgm_idx on books using gin(name gin_trgm_ops);
@@ -0,0 +1,4 @@
+drop table if exists order_items;
+drop table if exists orders;
+
+alter table books drop constraint if exists books_amount_check;
@@ -0,0 +1,21 @@
+alter table books add constraint books_amount_check check (amount >= 0);
+
+create table if not exists orders(
+                      id serial not null primary key,
+                      status varchar(16) not null default 'pending'
+                          check (status in ('pending', 'paid', 'cancelled', 'shipped')),
+                      total real not null,
+                      created_at timestamptz not null default now(),
+                      updated_at timestamptz not null default now()
+);
+
+create table if not exists order_items(
+                      id serial not null primary key,
+                      order_id int not null references orders(id) on delete cascade,
+                      book_id int references books(id) on delete set null,
+                      name varchar(100) not null,
+                      quantity int not null check (quantity > 0),
+                      price real not null
+);
+
+create index if not exists order_items_order_id_idx on order_items(order_id);
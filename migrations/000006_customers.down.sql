@@ -0,0 +1,3 @@
+alter table orders drop column if exists customer_id;
+
+drop table if exists customers;
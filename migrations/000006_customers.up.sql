@@ -0,0 +1,16 @@
+create table if not exists customers(
+                      id serial not null primary key,
+                      name varchar(100) not null,
+                      email varchar(254) not null,
+                      phone varchar(32) not null default '',
+                      street varchar(200) not null default '',
+                      city varchar(100) not null default '',
+                      postal_code varchar(20) not null default '',
+                      country varchar(2) not null default '',
+                      created_at timestamptz not null default now()
+);
+
+create unique index if not exists customers_email_idx on customers(lower(email));
+
+alter table orders add column if not exists customer_id int references customers(id) on delete set null;
+create index if not exists orders_customer_id_idx on orders(customer_id);
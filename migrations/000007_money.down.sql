@@ -0,0 +1,7 @@
+alter table order_items alter column price type real;
+
+alter table orders drop column if exists currency;
+alter table orders alter column total type real;
+
+alter table books drop column if exists currency;
+alter table books alter column price type real;
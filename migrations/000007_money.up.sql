@@ -0,0 +1,7 @@
+alter table books alter column price type numeric(19,4) using round(price::numeric, 2);
+alter table books add column if not exists currency char(3) not null default 'USD';
+
+alter table orders alter column total type numeric(19,4) using round(total::numeric, 2);
+alter table orders add column if not exists currency char(3) not null default 'USD';
+
+alter table order_items alter column price type numeric(19,4) using round(price::numeric, 2);
@@ -0,0 +1 @@
+drop table if exists promotions;
\ No newline at end of file
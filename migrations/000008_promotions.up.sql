@@ -0,0 +1,18 @@
+create table if not exists promotions(
+                      id serial not null primary key,
+                      name varchar(100) not null,
+                      kind varchar(16) not null check (kind in ('percentage', 'fixed')),
+                      value numeric(19,4) not null check (value > 0),
+                      currency char(3),
+                      genre int references genres(id),
+                      book_id int references books(id) on delete cascade,
+                      starts_at timestamptz not null,
+                      ends_at timestamptz,
+                      stackable boolean not null default false,
+                      priority int not null default 0,
+                      check (genre is null or book_id is null),
+                      check (kind <> 'fixed' or currency is not null),
+                      check (ends_at is null or ends_at > starts_at)
+);
+
+create index if not exists promotions_window_idx on promotions(starts_at, ends_at);
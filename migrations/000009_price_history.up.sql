@@ -0,0 +1,17 @@
+create table if not exists price_history(
+                      id serial not null primary key,
+                      book_id int not null references books(id) on delete cascade,
+                      old_price numeric(19,4),
+                      old_currency char(3),
+                      price numeric(19,4) not null,
+                      currency char(3) not null,
+                      actor varchar(100) not null,
+                      request_id varchar(100) not null,
+                      changed_at timestamptz not null default now()
+);
+
+create index if not exists price_history_book_id_idx on price_history(book_id, changed_at);
+create index if not exists price_history_changed_at_idx on price_history(changed_at);
+
+insert into price_history (book_id, price, currency, actor, request_id)
+select id, price, currency, 'migration', '' from books;
@@ -0,0 +1,2 @@
+drop table if exists low_stock_alerts;
+drop table if exists stock_thresholds;
@@ -0,0 +1,12 @@
+create table if not exists stock_thresholds(
+                      id serial not null primary key,
+                      book_id int unique references books(id) on delete cascade,
+                      genre int unique references genres(id),
+                      threshold int not null check (threshold >= 0),
+                      check ((book_id is null) <> (genre is null))
+);
+
+create table if not exists low_stock_alerts(
+                      book_id int not null primary key references books(id) on delete cascade,
+                      alerted_at timestamptz not null default now()
+);
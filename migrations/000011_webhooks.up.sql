@@ -0,0 +1,31 @@
+create table if not exists webhooks(
+                      id serial not null primary key,
+                      url varchar(2048) not null,
+                      secret varchar(256) not null,
+                      events text[] not null,
+                      created_at timestamptz not null default now()
+);
+
+create table if not exists outbox(
+                      id bigserial not null primary key,
+                      type varchar(32) not null,
+                      book_id int not null,
+                      data jsonb not null,
+                      created_at timestamptz not null default now()
+);
+
+create table if not exists webhook_deliveries(
+                      id bigserial not null primary key,
+                      webhook_id int not null references webhooks(id) on delete cascade,
+                      event_id bigint not null references outbox(id) on delete cascade,
+                      status varchar(16) not null default 'pending' check (status in ('pending', 'delivered', 'dead')),
+                      attempts int not null default 0,
+                      next_attempt_at timestamptz not null default now(),
+                      response_status int,
+                      last_error text,
+                      delivered_at timestamptz,
+                      unique (webhook_id, event_id)
+);
+
+create index if not exists webhook_deliveries_pending_idx on webhook_deliveries(next_attempt_at) where status = 'pending';
+create index if not exists webhook_deliveries_dead_idx on webhook_deliveries(id) where status = 'dead';
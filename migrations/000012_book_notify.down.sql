@@ -0,0 +1,2 @@
+drop trigger if exists books_notify on books;
+drop function if exists notify_book_change();
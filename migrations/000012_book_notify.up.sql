@@ -0,0 +1,51 @@
+create or replace function notify_book_change() returns trigger as $$
+declare
+    event_type text;
+    book books;
+begin
+    if tg_op = 'INSERT' then
+        event_type := 'book.created';
+        book := new;
+    elsif tg_op = 'DELETE' then
+        if old.deleted_at is not null then
+            return null;
+        end if;
+        event_type := 'book.deleted';
+        book := old;
+    elsif old.deleted_at is null and new.deleted_at is not null then
+        event_type := 'book.deleted';
+        book := old;
+    elsif old.deleted_at is not null and new.deleted_at is null then
+        event_type := 'book.created';
+        book := new;
+    elsif new.deleted_at is not null then
+        return null;
+    elsif (old.name, old.price, old.currency, old.genre) is not distinct from (new.name, new.price, new.currency, new.genre) then
+        if old.amount = new.amount then
+            return null;
+        end if;
+        event_type := 'book.stock_changed';
+        book := new;
+    else
+        event_type := 'book.updated';
+        book := new;
+    end if;
+
+    perform pg_notify('book_events', json_build_object(
+        'type', event_type,
+        'book', json_build_object(
+            'id', book.id,
+            'name', book.name,
+            'price', book.price,
+            'currency', book.currency,
+            'genre', book.genre,
+            'amount', book.amount
+        )
+    )::text);
+    return null;
+end;
+$$ language plpgsql;
+
+drop trigger if exists books_notify on books;
+create trigger books_notify after insert or update or delete on books
+    for each row execute procedure notify_book_change();
@@ -0,0 +1,4 @@
+drop trigger if exists promotions_catalog_change on promotions;
+drop trigger if exists books_catalog_change on books;
+drop function if exists record_catalog_change();
+drop table if exists catalog_changes;
@@ -0,0 +1,21 @@
+create table if not exists catalog_changes(
+                      name varchar(32) not null primary key,
+                      changed_at timestamptz not null
+);
+
+insert into catalog_changes values ('books', now()), ('promotions', now()) on conflict do nothing;
+
+create or replace function record_catalog_change() returns trigger as $$
+begin
+    update catalog_changes set changed_at=greatest(changed_at, now()) where name=tg_table_name;
+    return null;
+end;
+$$ language plpgsql;
+
+drop trigger if exists books_catalog_change on books;
+create trigger books_catalog_change after insert or update or delete on books
+    for each statement execute procedure record_catalog_change();
+
+drop trigger if exists promotions_catalog_change on promotions;
+create trigger promotions_catalog_change after insert or update or delete on promotions
+    for each statement execute procedure record_catalog_change();
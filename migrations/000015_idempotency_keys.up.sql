@@ -0,0 +1,10 @@
+create table if not exists idempotency_keys(
+                      key varchar(255) not null primary key,
+                      request_hash char(64) not null,
+                      status_code int,
+                      content_type varchar(255),
+                      body bytea,
+                      expires_at timestamptz not null
+);
+
+create index if not exists idempotency_keys_expires_at on idempotency_keys(expires_at);
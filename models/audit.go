@@ -0,0 +1,102 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	AuditActionCreate  = "create"
+	AuditActionUpdate  = "update"
+	AuditActionDelete  = "delete"
+	AuditActionRestore = "restore"
+	AuditActionPurge   = "purge"
+)
+
+// AuditInfo describes who performed a mutation and within which request.
+type AuditInfo struct {
+	Actor     string
+	RequestID string
+}
+
+// AuditChange holds the old and new value of a single changed field.
+type AuditChange struct {
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+type AuditEntry struct {
+	ID        int                    `json:"id"`
+	BookID    int                    `json:"book_id"`
+	Action    string                 `json:"action"`
+	Actor     string                 `json:"actor"`
+	RequestID string                 `json:"request_id"`
+	Before    json.RawMessage        `json:"before"`
+	After     json.RawMessage        `json:"after"`
+	Diff      map[string]AuditChange `json:"diff"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// AuditFilter narrows down the audit log, zero values are ignored.
+type AuditFilter struct {
+	BookID int
+	Actor  string
+	Action string
+	From   time.Time
+	To     time.Time
+	Limit  int
+}
+
+// NewAuditEntry builds an entry for the given action, before and after may be nil
+// for create and delete respectively.
+func NewAuditEntry(bookID int, action string, info AuditInfo, before, after interface{}) (AuditEntry, error) {
+	entry := AuditEntry{
+		BookID:    bookID,
+		Action:    action,
+		Actor:     info.Actor,
+		RequestID: info.RequestID,
+		Diff:      map[string]AuditChange{},
+	}
+	beforeFields, err := toFields(before)
+	if err != nil {
+		return entry, err
+	}
+	afterFields, err := toFields(after)
+	if err != nil {
+		return entry, err
+	}
+	if before != nil {
+		if entry.Before, err = json.Marshal(beforeFields); err != nil {
+			return entry, err
+		}
+	}
+	if after != nil {
+		if entry.After, err = json.Marshal(afterFields); err != nil {
+			return entry, err
+		}
+	}
+	for name, value := range afterFields {
+		if old, ok := beforeFields[name]; !ok || string(old) != string(value) {
+			entry.Diff[name] = AuditChange{Before: old, After: value}
+		}
+	}
+	for name, value := range beforeFields {
+		if _, ok := afterFields[name]; !ok {
+			entry.Diff[name] = AuditChange{Before: value}
+		}
+	}
+	return entry, nil
+}
+
+func toFields(v interface{}) (map[string]json.RawMessage, error) {
+	fields := map[string]json.RawMessage{}
+	if v == nil {
+		return fields, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &fields)
+	return fields, err
+}
@@ -1,9 +1,78 @@
 package models
 
+import "time"
+
 type Book struct {
-	ID     int     `json:"id"`
-	Name   string  `json:"name" binding:"min=1,max=100"`
-	Price  float64 `json:"price" binding:"min=0"`
-	Genre  int     `json:"genre" binding:"min=1,max=3"`
-	Amount int     `json:"amount" binding:"min=0"`
+	ID       int     `json:"id" xml:"id"`
+	Name     string  `json:"name" xml:"name" binding:"min=1,max=100"`
+	Price    Decimal `json:"price" xml:"price"`
+	Currency string  `json:"currency" xml:"currency" binding:"omitempty,iso4217"`
+	Genre    int     `json:"genre" xml:"genre" binding:"min=1,max=3"`
+	Amount   int     `json:"amount" xml:"amount" binding:"min=0"`
+	// Authors and the timestamps are only part of the v2 representation.
+	// Updating a book with nil Authors keeps the stored ones.
+	Authors   []string  `json:"-" xml:"-"`
+	CreatedAt time.Time `json:"-" xml:"-"`
+	UpdatedAt time.Time `json:"-" xml:"-"`
+}
+
+// Money is an amount in a currency.
+type Money struct {
+	Amount   Decimal `json:"amount" xml:"amount"`
+	Currency string  `json:"currency" xml:"currency" binding:"required,iso4217"`
+}
+
+// BookV2 is the book as the v2 API represents it.
+type BookV2 struct {
+	ID                int       `json:"id" xml:"id"`
+	Name              string    `json:"name" xml:"name" binding:"min=1,max=100"`
+	Authors           []string  `json:"authors" xml:"authors>author" binding:"max=20,dive,min=1,max=100"`
+	Price             Money     `json:"price" xml:"price"`
+	EffectivePrice    *Money    `json:"effective_price,omitempty" xml:"effective_price,omitempty"`
+	AppliedPromotions []int     `json:"applied_promotions,omitempty" xml:"applied_promotion,omitempty"`
+	Genre             int       `json:"genre" xml:"genre" binding:"min=1,max=3"`
+	Amount            int       `json:"amount" xml:"amount" binding:"min=0"`
+	CreatedAt         time.Time `json:"created_at" xml:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at" xml:"updated_at"`
+}
+
+// V2 converts the book to its v2 representation.
+func (b Book) V2() BookV2 {
+	authors := b.Authors
+	if authors == nil {
+		authors = []string{}
+	}
+	return BookV2{
+		ID:        b.ID,
+		Name:      b.Name,
+		Authors:   authors,
+		Price:     Money{Amount: b.Price, Currency: b.Currency},
+		Genre:     b.Genre,
+		Amount:    b.Amount,
+		CreatedAt: b.CreatedAt,
+		UpdatedAt: b.UpdatedAt,
+	}
+}
+
+// V2 converts the book to its v2 representation, the effective price is in
+// the currency of the book.
+func (p PricedBook) V2() BookV2 {
+	book := p.Book.V2()
+	book.EffectivePrice = &Money{Amount: p.EffectivePrice, Currency: p.Currency}
+	book.AppliedPromotions = p.AppliedPromotions
+	return book
+}
+
+// Book converts a v2 book back to the model the database stores, the
+// timestamps are left to the database.
+func (b BookV2) Book() Book {
+	return Book{
+		ID:       b.ID,
+		Name:     b.Name,
+		Price:    b.Price.Amount,
+		Currency: b.Price.Currency,
+		Genre:    b.Genre,
+		Amount:   b.Amount,
+		Authors:  b.Authors,
+	}
 }
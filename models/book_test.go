@@ -0,0 +1,25 @@
+package models
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestBookV2(t *testing.T) {
+	now := time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)
+	book := Book{ID: 1, Name: "Dune", Price: MustParseDecimal("9.99"), Currency: "EUR", Genre: 3, Amount: 4,
+		Authors: []string{"Frank Herbert"}, CreatedAt: now, UpdatedAt: now}
+
+	v2 := PricedBook{Book: book, EffectivePrice: MustParseDecimal("8.99"), AppliedPromotions: []int{2}}.V2()
+	assert.Equal(t, BookV2{ID: 1, Name: "Dune", Authors: []string{"Frank Herbert"}, Price: Money{MustParseDecimal("9.99"), "EUR"},
+		EffectivePrice: &Money{MustParseDecimal("8.99"), "EUR"}, AppliedPromotions: []int{2}, Genre: 3, Amount: 4,
+		CreatedAt: now, UpdatedAt: now}, v2)
+
+	stored := book
+	stored.CreatedAt, stored.UpdatedAt = time.Time{}, time.Time{}
+	assert.Equal(t, stored, v2.Book())
+
+	assert.Equal(t, []string{}, Book{}.V2().Authors, "authors are never null")
+	assert.Nil(t, BookV2{}.Book().Authors, "left out authors are kept on update")
+}
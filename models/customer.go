@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+type Address struct {
+	Street     string `json:"street" binding:"max=200"`
+	City       string `json:"city" binding:"max=100"`
+	PostalCode string `json:"postal_code" binding:"max=20"`
+	Country    string `json:"country" binding:"omitempty,iso3166_1_alpha2"`
+}
+
+type Customer struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name" binding:"min=1,max=100"`
+	Email     string    `json:"email" binding:"required,email,max=254"`
+	Phone     string    `json:"phone" binding:"max=32"`
+	Address   Address   `json:"address"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	EventBookCreated = "book.created"
+	EventBookUpdated = "book.updated"
+	EventBookDeleted = "book.deleted"
+	// EventStockChanged is raised when orders take or return stock.
+	EventStockChanged = "book.stock_changed"
+	// EventReset tells subscribers that events may have been missed, they
+	// have to reload what they keep of the catalog.
+	EventReset = "reset"
+)
+
+// BookEvents lists the event types subscribers can ask for.
+var BookEvents = []string{EventBookCreated, EventBookUpdated, EventBookDeleted}
+
+// Event is a catalog change, either recorded in the outbox together with the
+// mutation that caused it or published to stream subscribers. Data holds
+// the book after the change, or before it for deletes.
+type Event struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	BookID    int             `json:"book_id"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
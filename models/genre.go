@@ -1,6 +1,6 @@
 package models
 
 type Genre struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
+	ID   int    `json:"id" xml:"id"`
+	Name string `json:"name" xml:"name"`
 }
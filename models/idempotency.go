@@ -0,0 +1,10 @@
+package models
+
+// IdempotentResponse is the stored response to a request made with an
+// idempotency key. StatusCode is 0 while the request is still being handled.
+type IdempotentResponse struct {
+	RequestHash string
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
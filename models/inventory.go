@@ -0,0 +1,28 @@
+package models
+
+// StockThreshold sets the amount below which a book is low on stock, either
+// for a single book or for every book of a genre. The threshold of a book
+// takes precedence over the one of its genre.
+type StockThreshold struct {
+	ID        int `json:"id"`
+	BookID    int `json:"book_id,omitempty" binding:"min=0"`
+	Genre     int `json:"genre,omitempty" binding:"min=0,max=3"`
+	Threshold int `json:"threshold" binding:"min=0"`
+}
+
+// Valid reports whether the threshold targets exactly one of a book or a genre.
+func (t StockThreshold) Valid() bool {
+	return (t.BookID == 0) != (t.Genre == 0)
+}
+
+// StockChange is a change of the amount of a book in stock, negative when
+// copies are taken out.
+type StockChange struct {
+	Delta int `json:"delta"`
+}
+
+// LowStockBook is a book whose amount is below the threshold that applies to it.
+type LowStockBook struct {
+	Book
+	Threshold int `json:"threshold"`
+}
@@ -0,0 +1,318 @@
+package models
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultCurrency = "USD"
+	maxDecimalScale = 9
+)
+
+// currencyExponents holds the number of decimal places of the supported ISO 4217 currencies.
+var currencyExponents = map[string]int32{
+	"AUD": 2, "BHD": 3, "BRL": 2, "CAD": 2, "CHF": 2, "CNY": 2, "CZK": 2,
+	"DKK": 2, "EUR": 2, "GBP": 2, "HKD": 2, "HUF": 2, "INR": 2, "ISK": 0,
+	"JOD": 3, "JPY": 0, "KRW": 0, "KWD": 3, "MXN": 2, "NOK": 2, "NZD": 2,
+	"OMR": 3, "PLN": 2, "RUB": 2, "SEK": 2, "SGD": 2, "TND": 3, "TRY": 2,
+	"UAH": 2, "USD": 2, "ZAR": 2,
+}
+
+var (
+	decimalPattern    = regexp.MustCompile(`^-?[0-9]{1,18}(\.[0-9]{1,9})?$`)
+	errInvalidDecimal = errors.New("invalid decimal")
+
+	// ErrDecimalOverflow is returned by arithmetic whose result doesn't fit
+	// a Decimal.
+	ErrDecimalOverflow = errors.New("decimal overflow")
+)
+
+// CurrencyExponent returns the number of decimal places of the currency
+// and whether the currency is supported.
+func CurrencyExponent(currency string) (int32, bool) {
+	exponent, ok := currencyExponents[currency]
+	return exponent, ok
+}
+
+// ValidPrice reports whether price is a non-negative amount of the currency
+// with no more decimal places than the currency allows.
+func ValidPrice(price Decimal, currency string) bool {
+	exponent, ok := CurrencyExponent(currency)
+	return ok && price.Sign() >= 0 && price.Places() <= exponent
+}
+
+// Decimal is an exact fixed-point number stored as an integer amount of
+// units of 10^-scale. It is encoded in JSON as a string, and as text in XML,
+// CSV and MessagePack.
+type Decimal struct {
+	units int64
+	scale int32
+}
+
+func NewDecimal(units int64, scale int32) Decimal {
+	return Decimal{units: units, scale: scale}
+}
+
+func ParseDecimal(s string) (Decimal, error) {
+	if !decimalPattern.MatchString(s) {
+		return Decimal{}, errInvalidDecimal
+	}
+	var scale int32
+	if point := strings.IndexByte(s, '.'); point >= 0 {
+		scale = int32(len(s) - point - 1)
+		s = s[:point] + s[point+1:]
+	}
+	units, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return Decimal{}, errInvalidDecimal
+	}
+	return Decimal{units: units, scale: scale}, nil
+}
+
+// MustParseDecimal is like ParseDecimal but panics if s is not a decimal.
+func MustParseDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func (d Decimal) String() string {
+	digits := strconv.FormatInt(abs(d.units), 10)
+	if d.scale > 0 {
+		if pad := int(d.scale) + 1 - len(digits); pad > 0 {
+			digits = strings.Repeat("0", pad) + digits
+		}
+		digits = digits[:len(digits)-int(d.scale)] + "." + digits[len(digits)-int(d.scale):]
+	}
+	if d.units < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+func (d Decimal) Sign() int {
+	switch {
+	case d.units < 0:
+		return -1
+	case d.units > 0:
+		return 1
+	}
+	return 0
+}
+
+// Places returns the number of significant decimal places, ignoring trailing zeros.
+func (d Decimal) Places() int32 {
+	units, scale := d.units, d.scale
+	for scale > 0 && units%10 == 0 {
+		units /= 10
+		scale--
+	}
+	return scale
+}
+
+// Rescale returns d with exactly scale decimal places, rounding half away from zero.
+func (d Decimal) Rescale(scale int32) (Decimal, error) {
+	units := d.units
+	var err error
+	for s := d.scale; s < scale; s++ {
+		if units, err = mulUnits(units, 10); err != nil {
+			return Decimal{}, err
+		}
+	}
+	for s := d.scale; s > scale; s-- {
+		rest := units % 10
+		units /= 10
+		if rest >= 5 {
+			units++
+		} else if rest <= -5 {
+			units--
+		}
+	}
+	return Decimal{units: units, scale: scale}, nil
+}
+
+func (d Decimal) Add(other Decimal) (Decimal, error) {
+	scale := d.scale
+	if other.scale > scale {
+		scale = other.scale
+	}
+	a, err := d.Rescale(scale)
+	if err != nil {
+		return Decimal{}, err
+	}
+	b, err := other.Rescale(scale)
+	if err != nil {
+		return Decimal{}, err
+	}
+	units, err := addUnits(a.units, b.units)
+	if err != nil {
+		return Decimal{}, err
+	}
+	return Decimal{units: units, scale: scale}, nil
+}
+
+func (d Decimal) Sub(other Decimal) (Decimal, error) {
+	if other.units == math.MinInt64 {
+		return Decimal{}, ErrDecimalOverflow
+	}
+	return d.Add(Decimal{units: -other.units, scale: other.scale})
+}
+
+func (d Decimal) Mul(n int64) (Decimal, error) {
+	units, err := mulUnits(d.units, n)
+	if err != nil {
+		return Decimal{}, err
+	}
+	return Decimal{units: units, scale: d.scale}, nil
+}
+
+// MulDecimal returns the exact product of d and other.
+func (d Decimal) MulDecimal(other Decimal) (Decimal, error) {
+	units, err := mulUnits(d.units, other.units)
+	if err != nil {
+		return Decimal{}, err
+	}
+	return Decimal{units: units, scale: d.scale + other.scale}, nil
+}
+
+// Shift returns d multiplied by 10^n.
+func (d Decimal) Shift(n int32) (Decimal, error) {
+	units, scale := d.units, d.scale-n
+	var err error
+	for ; scale < 0; scale++ {
+		if units, err = mulUnits(units, 10); err != nil {
+			return Decimal{}, err
+		}
+	}
+	return Decimal{units: units, scale: scale}, nil
+}
+
+// Cmp returns -1, 0 or 1 if d is less than, equal to or greater than other.
+// It compares exactly, even decimals whose difference doesn't fit a Decimal.
+func (d Decimal) Cmp(other Decimal) int {
+	scale := d.scale
+	if other.scale > scale {
+		scale = other.scale
+	}
+	return d.bigUnits(scale).Cmp(other.bigUnits(scale))
+}
+
+// bigUnits returns the units of d at a scale at least as large as its own.
+func (d Decimal) bigUnits(scale int32) *big.Int {
+	factor := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale-d.scale)), nil)
+	return factor.Mul(factor, big.NewInt(d.units))
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(d.String())), nil
+}
+
+// UnmarshalJSON accepts both a string and a plain JSON number, the number is
+// parsed from its literal text so no precision is lost on the way.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if string(data) == "null" {
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		s, err := strconv.Unquote(string(data))
+		if err != nil {
+			return err
+		}
+		data = []byte(s)
+	}
+	parsed, err := ParseDecimal(string(data))
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+func (d Decimal) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Decimal) UnmarshalText(text []byte) error {
+	parsed, err := ParseDecimal(string(bytes.TrimSpace(text)))
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// MarshalBinary encodes d as text, MessagePack codecs use it in place of
+// MarshalText.
+func (d Decimal) MarshalBinary() ([]byte, error) {
+	return d.MarshalText()
+}
+
+func (d *Decimal) UnmarshalBinary(data []byte) error {
+	return d.UnmarshalText(data)
+}
+
+func (d *Decimal) Scan(src interface{}) error {
+	var err error
+	switch value := src.(type) {
+	case []byte:
+		*d, err = ParseDecimal(string(value))
+	case string:
+		*d, err = ParseDecimal(value)
+	case int64:
+		*d = Decimal{units: value}
+	case float64:
+		s := strconv.FormatFloat(value, 'f', -1, 64)
+		if point := strings.IndexByte(s, '.'); point >= 0 && len(s)-point-1 > maxDecimalScale {
+			s = strconv.FormatFloat(value, 'f', maxDecimalScale, 64)
+		}
+		*d, err = ParseDecimal(s)
+	case nil:
+		*d = Decimal{}
+	default:
+		err = fmt.Errorf("cannot scan %T into Decimal", src)
+	}
+	return err
+}
+
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+// addUnits returns a+b, or ErrDecimalOverflow when it doesn't fit in int64.
+func addUnits(a, b int64) (int64, error) {
+	sum := a + b
+	if (b > 0 && sum < a) || (b < 0 && sum > a) {
+		return 0, ErrDecimalOverflow
+	}
+	return sum, nil
+}
+
+// mulUnits returns a*b, or ErrDecimalOverflow when it doesn't fit in int64.
+func mulUnits(a, b int64) (int64, error) {
+	if a == 0 || b == 0 {
+		return 0, nil
+	}
+	product := a * b
+	if product/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, ErrDecimalOverflow
+	}
+	return product, nil
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
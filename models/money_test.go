@@ -0,0 +1,117 @@
+package models
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		output    string
+		places    int32
+		returnErr bool
+	}{
+		{name: "integer", input: "12", output: "12", places: 0},
+		{name: "fraction", input: "12.50", output: "12.50", places: 1},
+		{name: "small", input: "0.05", output: "0.05", places: 2},
+		{name: "negative", input: "-0.5", output: "-0.5", places: 1},
+		{name: "exponent", input: "1e3", returnErr: true},
+		{name: "empty", input: "", returnErr: true},
+		{name: "dangling point", input: "1.", returnErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d, err := ParseDecimal(test.input)
+			if test.returnErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.output, d.String())
+			assert.Equal(t, test.places, d.Places())
+		})
+	}
+}
+
+func TestDecimalArithmetic(t *testing.T) {
+	price := MustParseDecimal("19.99")
+	large := MustParseDecimal("999999999999999999")
+	tests := []struct {
+		name      string
+		operation func() (Decimal, error)
+		output    string
+		returnErr bool
+	}{
+		{name: "mul", operation: func() (Decimal, error) { return price.Mul(3) }, output: "59.97"},
+		{name: "add", operation: func() (Decimal, error) { return price.Add(MustParseDecimal("0.5")) }, output: "20.49"},
+		{name: "sub", operation: func() (Decimal, error) { return price.Sub(MustParseDecimal("20")) }, output: "-0.01"},
+		{name: "round", operation: func() (Decimal, error) { return price.Rescale(1) }, output: "20.0"},
+		{name: "extend", operation: func() (Decimal, error) { return price.Rescale(4) }, output: "19.9900"},
+		{name: "round negative", operation: func() (Decimal, error) { return MustParseDecimal("-19.5").Rescale(0) }, output: "-20"},
+		{name: "mul decimal", operation: func() (Decimal, error) { return price.MulDecimal(MustParseDecimal("0.5")) }, output: "9.995"},
+		{name: "shift", operation: func() (Decimal, error) { return price.Shift(3) }, output: "19990"},
+		{name: "mul overflow", operation: func() (Decimal, error) { return large.Mul(10) }, returnErr: true},
+		{name: "add overflow", operation: func() (Decimal, error) { return large.Add(MustParseDecimal("0.1")) }, returnErr: true},
+		{name: "sub overflow", operation: func() (Decimal, error) { return NewDecimal(-9000000000000000000, 0).Sub(large) }, returnErr: true},
+		{name: "rescale overflow", operation: func() (Decimal, error) { return large.Rescale(2) }, returnErr: true},
+		{name: "mul decimal overflow", operation: func() (Decimal, error) { return large.MulDecimal(price) }, returnErr: true},
+		{name: "shift overflow", operation: func() (Decimal, error) { return large.Shift(2) }, returnErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d, err := test.operation()
+			if test.returnErr {
+				assert.Equal(t, ErrDecimalOverflow, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.output, d.String())
+		})
+	}
+}
+
+func TestDecimalCmp(t *testing.T) {
+	price := MustParseDecimal("19.99")
+	assert.Equal(t, 1, price.Cmp(MustParseDecimal("19.9")))
+	assert.Equal(t, 0, price.Cmp(MustParseDecimal("19.990")))
+	assert.Equal(t, -1, price.Cmp(MustParseDecimal("999999999999999999")), "compared beyond the range of a Decimal")
+	assert.Equal(t, 1, price.Cmp(MustParseDecimal("-999999999999999999")))
+}
+
+func TestDecimalJSON(t *testing.T) {
+	var book Book
+	assert.NoError(t, json.Unmarshal([]byte(`{"price":0.1}`), &book))
+	assert.Equal(t, "0.1", book.Price.String())
+	assert.NoError(t, json.Unmarshal([]byte(`{"price":"12.30"}`), &book))
+	assert.Equal(t, "12.30", book.Price.String())
+	assert.Error(t, json.Unmarshal([]byte(`{"price":"twelve"}`), &book))
+
+	data, err := json.Marshal(book.Price)
+	assert.NoError(t, err)
+	assert.Equal(t, `"12.30"`, string(data))
+}
+
+func TestDecimalXML(t *testing.T) {
+	var book Book
+	assert.NoError(t, xml.Unmarshal([]byte(`<book><price> 12.30 </price></book>`), &book))
+	assert.Equal(t, "12.30", book.Price.String())
+	assert.Error(t, xml.Unmarshal([]byte(`<book><price>twelve</price></book>`), &book))
+
+	data, err := xml.Marshal(Money{Amount: book.Price, Currency: "USD"})
+	assert.NoError(t, err)
+	assert.Equal(t, `<Money><amount>12.30</amount><currency>USD</currency></Money>`, string(data))
+}
+
+func TestValidPrice(t *testing.T) {
+	assert.True(t, ValidPrice(MustParseDecimal("10.50"), "USD"))
+	assert.True(t, ValidPrice(MustParseDecimal("10.500"), "USD"))
+	assert.False(t, ValidPrice(MustParseDecimal("10.505"), "USD"))
+	assert.True(t, ValidPrice(MustParseDecimal("10.505"), "KWD"))
+	assert.False(t, ValidPrice(MustParseDecimal("100.5"), "JPY"))
+	assert.False(t, ValidPrice(MustParseDecimal("-1"), "USD"))
+	assert.False(t, ValidPrice(MustParseDecimal("1"), "XYZ"))
+}
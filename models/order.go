@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+const (
+	OrderStatusPending   = "pending"
+	OrderStatusPaid      = "paid"
+	OrderStatusCancelled = "cancelled"
+	OrderStatusShipped   = "shipped"
+)
+
+// orderTransitions lists the statuses an order may move to from its current one.
+var orderTransitions = map[string][]string{
+	OrderStatusPending:   {OrderStatusPaid, OrderStatusCancelled},
+	OrderStatusPaid:      {OrderStatusShipped, OrderStatusCancelled},
+	OrderStatusShipped:   {},
+	OrderStatusCancelled: {},
+}
+
+type OrderItem struct {
+	BookID   int     `json:"book_id" binding:"min=1"`
+	Name     string  `json:"name"`
+	Quantity int     `json:"quantity" binding:"min=1"`
+	Price    Decimal `json:"price"`
+}
+
+type Order struct {
+	ID         int         `json:"id"`
+	CustomerID int         `json:"customer_id,omitempty" binding:"min=0"`
+	Status     string      `json:"status"`
+	Items      []OrderItem `json:"items" binding:"required,min=1,dive"`
+	Total      Decimal     `json:"total"`
+	Currency   string      `json:"currency"`
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+}
+
+type OrderStatus struct {
+	Status string `json:"status" binding:"oneof=pending paid cancelled shipped"`
+}
+
+// CanTransition reports whether an order in status from may be moved to status to.
+func CanTransition(from, to string) bool {
+	for _, status := range orderTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// PriceChange is a single change of a book's price. OldPrice is nil for the
+// price a book was created with.
+type PriceChange struct {
+	ID          int       `json:"id" xml:"id"`
+	BookID      int       `json:"book_id" xml:"book_id"`
+	OldPrice    *Decimal  `json:"old_price" xml:"old_price"`
+	OldCurrency string    `json:"old_currency,omitempty" xml:"old_currency,omitempty"`
+	Price       Decimal   `json:"price" xml:"price"`
+	Currency    string    `json:"currency" xml:"currency"`
+	Actor       string    `json:"actor" xml:"actor"`
+	RequestID   string    `json:"request_id" xml:"request_id"`
+	ChangedAt   time.Time `json:"changed_at" xml:"changed_at"`
+}
+
+// PriceChangeReport is a price change together with its size, used to rank
+// the largest changes in a period.
+type PriceChangeReport struct {
+	PriceChange
+	Name          string  `json:"name" xml:"name"`
+	Change        Decimal `json:"change" xml:"change"`
+	ChangePercent Decimal `json:"change_percent" xml:"change_percent"`
+}
+
+// PriceFilter narrows down price changes to a time range, zero values are ignored.
+type PriceFilter struct {
+	From  time.Time
+	To    time.Time
+	Limit int
+}
@@ -0,0 +1,162 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	PromotionPercentage = "percentage"
+	PromotionFixed      = "fixed"
+)
+
+var hundred = NewDecimal(100, 0)
+
+// Promotion is a discount on books of a genre, on a single book, or on the
+// whole catalog when neither is set. Fixed discounts only apply to books
+// priced in the promotion's currency.
+type Promotion struct {
+	ID        int        `json:"id"`
+	Name      string     `json:"name" binding:"min=1,max=100"`
+	Kind      string     `json:"kind" binding:"oneof=percentage fixed"`
+	Value     Decimal    `json:"value"`
+	Currency  string     `json:"currency,omitempty" binding:"omitempty,iso4217"`
+	Genre     int        `json:"genre,omitempty" binding:"min=0,max=3"`
+	BookID    int        `json:"book_id,omitempty" binding:"min=0"`
+	StartsAt  time.Time  `json:"starts_at" binding:"required"`
+	EndsAt    *time.Time `json:"ends_at,omitempty"`
+	Stackable bool       `json:"stackable"`
+	Priority  int        `json:"priority"`
+}
+
+// PricedBook is a book together with its price after active promotions.
+type PricedBook struct {
+	Book
+	EffectivePrice    Decimal `json:"effective_price" xml:"effective_price"`
+	AppliedPromotions []int   `json:"applied_promotions,omitempty" xml:"applied_promotion,omitempty"`
+}
+
+// Valid checks the rules that can't be expressed with binding tags.
+func (p Promotion) Valid() bool {
+	if p.Value.Sign() <= 0 || (p.Genre != 0 && p.BookID != 0) {
+		return false
+	}
+	if p.EndsAt != nil && !p.EndsAt.After(p.StartsAt) {
+		return false
+	}
+	switch p.Kind {
+	case PromotionPercentage:
+		return p.Value.Cmp(hundred) <= 0
+	case PromotionFixed:
+		return ValidPrice(p.Value, p.Currency)
+	}
+	return false
+}
+
+// ActiveAt reports whether t lies within the promotion's date window.
+func (p Promotion) ActiveAt(t time.Time) bool {
+	return !t.Before(p.StartsAt) && (p.EndsAt == nil || t.Before(*p.EndsAt))
+}
+
+// AppliesTo reports whether the promotion targets the book.
+func (p Promotion) AppliesTo(book Book) bool {
+	if p.BookID != 0 && p.BookID != book.ID {
+		return false
+	}
+	if p.Genre != 0 && p.Genre != book.Genre {
+		return false
+	}
+	return p.Kind != PromotionFixed || p.Currency == book.Currency
+}
+
+// apply returns the price after the discount, rounded to the given number of
+// decimal places and never below zero.
+func (p Promotion) apply(price Decimal, places int32) (Decimal, error) {
+	var discount Decimal
+	switch p.Kind {
+	case PromotionPercentage:
+		product, err := price.MulDecimal(p.Value)
+		if err != nil {
+			return Decimal{}, err
+		}
+		if discount, err = product.Shift(-2); err != nil {
+			return Decimal{}, err
+		}
+	case PromotionFixed:
+		discount = p.Value
+	}
+	result, err := price.Sub(discount)
+	if err != nil {
+		return Decimal{}, err
+	}
+	if result.Sign() < 0 {
+		return NewDecimal(0, places), nil
+	}
+	return result.Rescale(places)
+}
+
+// EffectivePrice works out the price of the book after the promotions active
+// at the given time. Non-stackable promotions can't be combined with any other
+// promotion, stackable ones are applied one after another in priority order.
+// The cheapest of the best non-stackable promotion and all stackable ones
+// together wins. It also returns the IDs of the promotions that were applied.
+func EffectivePrice(book Book, promotions []Promotion, at time.Time) (Decimal, []int, error) {
+	places, ok := CurrencyExponent(book.Currency)
+	if !ok {
+		places = book.Price.scale
+	}
+	best, err := book.Price.Rescale(places)
+	if err != nil {
+		return Decimal{}, nil, err
+	}
+	var applied []int
+
+	var stackable []Promotion
+	for _, p := range promotions {
+		if !p.ActiveAt(at) || !p.AppliesTo(book) {
+			continue
+		}
+		if p.Stackable {
+			stackable = append(stackable, p)
+			continue
+		}
+		price, err := p.apply(book.Price, places)
+		if err != nil {
+			return Decimal{}, nil, err
+		}
+		if price.Cmp(best) < 0 {
+			best, applied = price, []int{p.ID}
+		}
+	}
+
+	if len(stackable) > 0 {
+		sortPromotions(stackable)
+		price := book.Price
+		var ids []int
+		for _, p := range stackable {
+			if price, err = p.apply(price, places); err != nil {
+				return Decimal{}, nil, err
+			}
+			ids = append(ids, p.ID)
+		}
+		if price.Cmp(best) < 0 {
+			best, applied = price, ids
+		}
+	}
+	return best, applied, nil
+}
+
+// sortPromotions orders promotions by descending priority, percentages
+// before fixed amounts within the same priority, and then by ID.
+func sortPromotions(promotions []Promotion) {
+	sort.SliceStable(promotions, func(i, j int) bool {
+		a, b := promotions[i], promotions[j]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		if a.Kind != b.Kind {
+			return a.Kind == PromotionPercentage
+		}
+		return a.ID < b.ID
+	})
+}
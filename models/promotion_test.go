@@ -0,0 +1,156 @@
+package models
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestEffectivePrice(t *testing.T) {
+	start := time.Date(2021, 11, 20, 0, 0, 0, 0, time.UTC)
+	end := start.Add(48 * time.Hour)
+	now := start.Add(24 * time.Hour)
+	book := Book{ID: 1, Name: "Dune", Price: MustParseDecimal("20"), Currency: "USD", Genre: 2, Amount: 1}
+	tests := []struct {
+		name       string
+		book       Book
+		promotions []Promotion
+		price      string
+		applied    []int
+		returnErr  bool
+	}{
+		{
+			name:  "no promotions",
+			book:  book,
+			price: "20.00",
+		},
+		{
+			name: "genre percentage",
+			book: book,
+			promotions: []Promotion{
+				{ID: 1, Kind: PromotionPercentage, Value: MustParseDecimal("10"), Genre: 2, StartsAt: start},
+			},
+			price:   "18.00",
+			applied: []int{1},
+		},
+		{
+			name: "other genre and book",
+			book: book,
+			promotions: []Promotion{
+				{ID: 1, Kind: PromotionPercentage, Value: MustParseDecimal("10"), Genre: 1, StartsAt: start},
+				{ID: 2, Kind: PromotionPercentage, Value: MustParseDecimal("10"), BookID: 2, StartsAt: start},
+			},
+			price: "20.00",
+		},
+		{
+			name: "outside window",
+			book: book,
+			promotions: []Promotion{
+				{ID: 1, Kind: PromotionPercentage, Value: MustParseDecimal("10"), StartsAt: end},
+				{ID: 2, Kind: PromotionPercentage, Value: MustParseDecimal("10"), StartsAt: start.Add(-time.Hour), EndsAt: &start},
+			},
+			price: "20.00",
+		},
+		{
+			name: "best non-stackable wins",
+			book: book,
+			promotions: []Promotion{
+				{ID: 1, Kind: PromotionPercentage, Value: MustParseDecimal("10"), StartsAt: start},
+				{ID: 2, Kind: PromotionFixed, Value: MustParseDecimal("5"), Currency: "USD", BookID: 1, StartsAt: start, EndsAt: &end},
+			},
+			price:   "15.00",
+			applied: []int{2},
+		},
+		{
+			name: "stackable applied in priority order",
+			book: book,
+			promotions: []Promotion{
+				{ID: 1, Kind: PromotionFixed, Value: MustParseDecimal("2"), Currency: "USD", StartsAt: start, Stackable: true},
+				{ID: 2, Kind: PromotionPercentage, Value: MustParseDecimal("50"), StartsAt: start, Stackable: true, Priority: 1},
+			},
+			price:   "8.00",
+			applied: []int{2, 1},
+		},
+		{
+			name: "non-stackable beats stack",
+			book: book,
+			promotions: []Promotion{
+				{ID: 1, Kind: PromotionPercentage, Value: MustParseDecimal("10"), StartsAt: start, Stackable: true},
+				{ID: 2, Kind: PromotionPercentage, Value: MustParseDecimal("5"), StartsAt: start, Stackable: true},
+				{ID: 3, Kind: PromotionPercentage, Value: MustParseDecimal("25"), StartsAt: start},
+			},
+			price:   "15.00",
+			applied: []int{3},
+		},
+		{
+			name: "fixed in other currency",
+			book: book,
+			promotions: []Promotion{
+				{ID: 1, Kind: PromotionFixed, Value: MustParseDecimal("5"), Currency: "EUR", StartsAt: start},
+			},
+			price: "20.00",
+		},
+		{
+			name: "never below zero",
+			book: book,
+			promotions: []Promotion{
+				{ID: 1, Kind: PromotionFixed, Value: MustParseDecimal("50"), Currency: "USD", StartsAt: start},
+			},
+			price:   "0.00",
+			applied: []int{1},
+		},
+		{
+			name: "rounded to currency",
+			book: Book{ID: 1, Price: MustParseDecimal("999"), Currency: "JPY"},
+			promotions: []Promotion{
+				{ID: 1, Kind: PromotionPercentage, Value: MustParseDecimal("15"), StartsAt: start},
+			},
+			price:   "849",
+			applied: []int{1},
+		},
+		{
+			name: "overflowing discount",
+			book: Book{ID: 1, Price: MustParseDecimal("999999999999999999"), Currency: "JPY"},
+			promotions: []Promotion{
+				{ID: 1, Kind: PromotionPercentage, Value: MustParseDecimal("15.5"), StartsAt: start},
+			},
+			returnErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			price, applied, err := EffectivePrice(test.book, test.promotions, now)
+			if test.returnErr {
+				assert.Equal(t, ErrDecimalOverflow, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.price, price.String())
+			assert.Equal(t, test.applied, applied)
+		})
+	}
+}
+
+func TestPromotionValid(t *testing.T) {
+	start := time.Date(2021, 11, 20, 0, 0, 0, 0, time.UTC)
+	before := start.Add(-time.Hour)
+	tests := []struct {
+		name      string
+		promotion Promotion
+		valid     bool
+	}{
+		{name: "percentage", promotion: Promotion{Kind: PromotionPercentage, Value: MustParseDecimal("15"), StartsAt: start}, valid: true},
+		{name: "over hundred percent", promotion: Promotion{Kind: PromotionPercentage, Value: MustParseDecimal("101"), StartsAt: start}},
+		{name: "zero value", promotion: Promotion{Kind: PromotionPercentage, Value: MustParseDecimal("0"), StartsAt: start}},
+		{name: "fixed", promotion: Promotion{Kind: PromotionFixed, Value: MustParseDecimal("2.50"), Currency: "EUR", StartsAt: start}, valid: true},
+		{name: "fixed without currency", promotion: Promotion{Kind: PromotionFixed, Value: MustParseDecimal("2.50"), StartsAt: start}},
+		{name: "fixed too precise", promotion: Promotion{Kind: PromotionFixed, Value: MustParseDecimal("2.505"), Currency: "EUR", StartsAt: start}},
+		{name: "genre and book", promotion: Promotion{Kind: PromotionPercentage, Value: MustParseDecimal("5"), Genre: 1, BookID: 1, StartsAt: start}},
+		{name: "ends before start", promotion: Promotion{Kind: PromotionPercentage, Value: MustParseDecimal("5"), StartsAt: start, EndsAt: &before}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.valid, test.promotion.Valid())
+		})
+	}
+}
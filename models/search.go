@@ -0,0 +1,21 @@
+package models
+
+// SearchResult is a book matched by a full-text search together with its
+// relevance and the name as HTML with the matched words highlighted.
+type SearchResult struct {
+	Book
+	Rank    float64 `json:"rank" xml:"rank"`
+	Snippet string  `json:"snippet" xml:"snippet"`
+}
+
+// SearchResultV2 is a search result as the v2 API represents it.
+type SearchResultV2 struct {
+	BookV2
+	Rank    float64 `json:"rank" xml:"rank"`
+	Snippet string  `json:"snippet" xml:"snippet"`
+}
+
+// V2 converts the result to its v2 representation.
+func (r SearchResult) V2() SearchResultV2 {
+	return SearchResultV2{BookV2: r.Book.V2(), Rank: r.Rank, Snippet: r.Snippet}
+}
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+// Webhook is a subscriber URL receiving the given event types. The secret
+// signs every delivery and is only ever shown when the webhook is created.
+type Webhook struct {
+	ID        int       `json:"id"`
+	URL       string    `json:"url" binding:"required,url,max=2048"`
+	Secret    string    `json:"secret,omitempty" binding:"omitempty,min=16,max=256"`
+	Events    []string  `json:"events" binding:"required,min=1,dive,oneof=book.created book.updated book.deleted"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Delivery is a single event to be posted to a single webhook.
+type Delivery struct {
+	ID             int64      `json:"id"`
+	WebhookID      int        `json:"webhook_id"`
+	URL            string     `json:"url"`
+	Secret         string     `json:"-"`
+	Event          Event      `json:"event"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at"`
+	ResponseStatus int        `json:"response_status,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+// DeliveryAttempt is the outcome of posting a delivery. Status is
+// DeliveryPending when it should be retried at NextAttemptAt.
+type DeliveryAttempt struct {
+	Status         string
+	ResponseStatus int
+	Error          string
+	NextAttemptAt  time.Time
+}
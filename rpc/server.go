@@ -0,0 +1,368 @@
+// Package rpc serves the book catalog over gRPC.
+package rpc
+
+//go:generate buf generate --template buf.gen.yaml ../proto
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/porky256/rest-api/db"
+	"github.com/porky256/rest-api/events"
+	"github.com/porky256/rest-api/models"
+	"github.com/porky256/rest-api/rpc/bookspb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 1000
+
+	// actorKey and requestIDKey are the metadata keys of the audit info,
+	// named like the REST headers.
+	actorKey       = "x-actor"
+	requestIDKey   = "x-request-id"
+	anonymousActor = "anonymous"
+)
+
+// Server implements BookService over the same database and event bus as the
+// REST API.
+type Server struct {
+	bookspb.UnimplementedBookServiceServer
+	DataBase db.Database
+	Events   *events.Bus
+}
+
+func NewServer(database db.Database, bus *events.Bus) *Server {
+	return &Server{DataBase: database, Events: bus}
+}
+
+// NewGRPCServer registers the service, and reflection for tools like
+// grpcurl, on a new gRPC server.
+func NewGRPCServer(server *Server) *grpc.Server {
+	s := grpc.NewServer()
+	bookspb.RegisterBookServiceServer(s, server)
+	reflection.Register(s)
+	return s
+}
+
+func (s *Server) ListBooks(ctx context.Context, req *bookspb.ListBooksRequest) (*bookspb.ListBooksResponse, error) {
+	filter := map[string][]string{}
+	if req.Name != "" {
+		filter["name"] = []string{req.Name}
+	}
+	if req.Genre != 0 {
+		if req.Genre < 1 || req.Genre > 3 {
+			return nil, status.Error(codes.InvalidArgument, "invalid genre")
+		}
+		filter["genre"] = []string{strconv.Itoa(int(req.Genre))}
+	}
+	pageSize := int(req.PageSize)
+	switch {
+	case pageSize < 0:
+		return nil, status.Error(codes.InvalidArgument, "invalid page size")
+	case pageSize == 0:
+		pageSize = DefaultPageSize
+	case pageSize > MaxPageSize:
+		pageSize = MaxPageSize
+	}
+	after, err := decodePageToken(req.PageToken)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid page token")
+	}
+
+	// Books come newest first, a page token holds the ID of the last book
+	// of the previous page. One book more than the page is read to tell
+	// whether there is a next page.
+	books, err := s.DataBase.GetBooksPage(filter, after, pageSize+1)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	more := len(books) > pageSize
+	if more {
+		books = books[:pageSize]
+	}
+	page, err := s.priceBooks(books)
+	if err != nil {
+		return nil, internalError(err)
+	}
+
+	resp := &bookspb.ListBooksResponse{Books: page}
+	if more {
+		resp.NextPageToken = encodePageToken(books[len(books)-1].ID)
+	}
+	return resp, nil
+}
+
+func (s *Server) GetBook(ctx context.Context, req *bookspb.GetBookRequest) (*bookspb.Book, error) {
+	book, err := s.DataBase.GetBookById(int(req.Id))
+	if err != nil {
+		return nil, bookError(err)
+	}
+	list, err := s.priceBooks([]models.Book{book})
+	if err != nil {
+		return nil, internalError(err)
+	}
+	return list[0], nil
+}
+
+func (s *Server) CreateBook(ctx context.Context, req *bookspb.CreateBookRequest) (*bookspb.Book, error) {
+	book, err := bookFromProto(req.Book)
+	if err != nil {
+		return nil, err
+	}
+	id, err := s.DataBase.AddBook(book, auditInfo(ctx))
+	if err != nil {
+		return nil, bookError(err)
+	}
+	book.ID = id
+	s.publish(models.EventBookCreated, id, book)
+	return s.storedBook(id)
+}
+
+func (s *Server) UpdateBook(ctx context.Context, req *bookspb.UpdateBookRequest) (*bookspb.Book, error) {
+	book, err := bookFromProto(req.Book)
+	if err != nil {
+		return nil, err
+	}
+	// Updates replace the authors, unlike REST updates leaving them out.
+	if book.Authors == nil {
+		book.Authors = []string{}
+	}
+	if err := s.DataBase.UpdateBook(book.ID, book, auditInfo(ctx)); err != nil {
+		return nil, bookError(err)
+	}
+	s.publish(models.EventBookUpdated, book.ID, book)
+	return s.storedBook(book.ID)
+}
+
+func (s *Server) DeleteBook(ctx context.Context, req *bookspb.DeleteBookRequest) (*emptypb.Empty, error) {
+	id := int(req.Id)
+	deleted := s.Events.DeletedBook(s.DataBase, id)
+	if err := s.DataBase.DelBook(id, auditInfo(ctx)); err != nil {
+		return nil, bookError(err)
+	}
+	s.publish(models.EventBookDeleted, id, deleted)
+	return &emptypb.Empty{}, nil
+}
+
+// WatchBooks sends the events of the bus until the client goes away or the
+// bus is closed for shutdown. Response headers are sent once subscribed.
+// Reset events, without a book, reach every client whatever it watches.
+func (s *Server) WatchBooks(req *bookspb.WatchBooksRequest, stream bookspb.BookService_WatchBooksServer) error {
+	books := map[int]bool{}
+	for _, id := range req.BookIds {
+		books[int(id)] = true
+	}
+	genres := map[int]bool{}
+	for _, genre := range req.Genres {
+		genres[int(genre)] = true
+	}
+
+	_, _, events, cancel := s.Events.Subscribe("")
+	defer cancel()
+	// Headers tell the client that no later event is missed.
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return status.Error(codes.Unavailable, "event stream closed")
+			}
+			var book models.Book
+			if err := json.Unmarshal(event.Data, &book); err != nil {
+				log.Println(err.Error())
+				continue
+			}
+			reset := event.Type == models.EventReset
+			if !reset && (len(books) != 0 || len(genres) != 0) && !books[event.BookID] && !genres[book.Genre] {
+				continue
+			}
+			message := &bookspb.BookEvent{
+				Id:         s.Events.EventID(event),
+				Type:       event.Type,
+				BookId:     int64(event.BookID),
+				CreateTime: timestamppb.New(event.CreatedAt),
+			}
+			if !reset {
+				message.Book = bookToProto(book)
+			}
+			err := stream.Send(message)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// storedBook reads a book back after a change for its authors and
+// timestamps.
+func (s *Server) storedBook(id int) (*bookspb.Book, error) {
+	book, err := s.DataBase.GetBookById(id)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	return bookToProto(book), nil
+}
+
+func (s *Server) priceBooks(books []models.Book) ([]*bookspb.Book, error) {
+	now := time.Now()
+	promotions, err := s.DataBase.GetActivePromotions(now)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*bookspb.Book, 0, len(books))
+	for _, book := range books {
+		price, applied, err := models.EffectivePrice(book, promotions, now)
+		if err != nil {
+			return nil, err
+		}
+		pb := bookToProto(book)
+		pb.EffectivePrice = &bookspb.Money{Amount: price.String(), CurrencyCode: book.Currency}
+		for _, id := range applied {
+			pb.AppliedPromotions = append(pb.AppliedPromotions, int64(id))
+		}
+		list = append(list, pb)
+	}
+	return list, nil
+}
+
+// publish records a book event, unless the database change feed takes care
+// of that.
+func (s *Server) publish(eventType string, bookID int, data interface{}) {
+	if s.publishing() {
+		s.Events.Publish(eventType, bookID, data)
+	}
+}
+
+func (s *Server) publishing() bool {
+	return s.Events != nil && !s.Events.ChangeFeed
+}
+
+func bookToProto(book models.Book) *bookspb.Book {
+	pb := &bookspb.Book{
+		Id:      int64(book.ID),
+		Name:    book.Name,
+		Authors: book.Authors,
+		Genre:   int32(book.Genre),
+		Amount:  int32(book.Amount),
+	}
+	if book.Currency != "" {
+		pb.Price = &bookspb.Money{Amount: book.Price.String(), CurrencyCode: book.Currency}
+	}
+	if !book.CreatedAt.IsZero() {
+		pb.CreateTime = timestamppb.New(book.CreatedAt)
+	}
+	if !book.UpdatedAt.IsZero() {
+		pb.UpdateTime = timestamppb.New(book.UpdatedAt)
+	}
+	return pb
+}
+
+// bookFromProto converts and validates a book sent by a client with the same
+// rules as the REST API.
+func bookFromProto(pb *bookspb.Book) (models.Book, error) {
+	if pb == nil || pb.Price == nil {
+		return models.Book{}, status.Error(codes.InvalidArgument, "invalid input")
+	}
+	price, err := models.ParseDecimal(pb.Price.Amount)
+	if err != nil {
+		return models.Book{}, status.Error(codes.InvalidArgument, "invalid input")
+	}
+	book := models.Book{
+		ID:       int(pb.Id),
+		Name:     pb.Name,
+		Authors:  pb.Authors,
+		Price:    price,
+		Currency: pb.Price.CurrencyCode,
+		Genre:    int(pb.Genre),
+		Amount:   int(pb.Amount),
+	}
+	if book.Currency == "" {
+		book.Currency = models.DefaultCurrency
+	}
+	if err := binding.Validator.ValidateStruct(book.V2()); err != nil || !models.ValidPrice(book.Price, book.Currency) {
+		return models.Book{}, status.Error(codes.InvalidArgument, "invalid input")
+	}
+	return book, nil
+}
+
+// bookError maps database errors to status codes with the messages of the
+// REST API.
+func bookError(err error) error {
+	if strings.Contains(err.Error(), "duplicate key value") { //unique_violation
+		log.Println(err.Error())
+		return status.Error(codes.AlreadyExists, "input book name is not unique")
+	}
+	if err == sql.ErrNoRows {
+		log.Println(err.Error())
+		return status.Error(codes.NotFound, "id not found")
+	}
+	return internalError(err)
+}
+
+func internalError(err error) error {
+	log.Println(err.Error())
+	return status.Error(codes.Internal, "internal server error")
+}
+
+func auditInfo(ctx context.Context) models.AuditInfo {
+	md, _ := metadata.FromIncomingContext(ctx)
+	info := models.AuditInfo{Actor: first(md.Get(actorKey)), RequestID: first(md.Get(requestIDKey))}
+	if info.Actor == "" {
+		info.Actor = anonymousActor
+	}
+	if info.RequestID == "" {
+		info.RequestID = newRequestID()
+	}
+	return info
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func newRequestID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		log.Println(err.Error())
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(buf)
+}
+
+func encodePageToken(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(id)))
+}
+
+func decodePageToken(token string) (int, error) {
+	if token == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(string(raw))
+}
@@ -0,0 +1,290 @@
+package rpc
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/porky256/rest-api/api"
+	"github.com/porky256/rest-api/events"
+	"github.com/porky256/rest-api/internal/booktest"
+	"github.com/porky256/rest-api/models"
+	"github.com/porky256/rest-api/rpc/bookspb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"net"
+	"testing"
+	"time"
+)
+
+// dial serves the server over an in-memory connection.
+func dial(t *testing.T, server *Server) bookspb.BookServiceClient {
+	listener := bufconn.Listen(1 << 20)
+	s := NewGRPCServer(server)
+	go func() {
+		_ = s.Serve(listener)
+	}()
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Error in dialing: %s", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return bookspb.NewBookServiceClient(conn)
+}
+
+func TestServerListBooks(t *testing.T) {
+	type mockBehavior func(r *api.MockDatabase)
+	tests := []struct {
+		name              string
+		request           *bookspb.ListBooksRequest
+		mockBehavior      mockBehavior
+		expectedIDs       []int64
+		expectedNextToken string
+		expectedCode      codes.Code
+	}{
+		{
+			name:    "first page",
+			request: &bookspb.ListBooksRequest{Genre: 3, PageSize: 2},
+			mockBehavior: func(r *api.MockDatabase) {
+				r.EXPECT().GetBooksPage(map[string][]string{"genre": {"3"}}, 0, 3).Return([]models.Book{booktest.Book(3), booktest.Book(2), booktest.Book(1)}, nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{}, nil)
+			},
+			expectedIDs:       []int64{3, 2},
+			expectedNextToken: encodePageToken(2),
+		},
+		{
+			name:    "last page",
+			request: &bookspb.ListBooksRequest{Name: "Dune", PageSize: 2, PageToken: encodePageToken(2)},
+			mockBehavior: func(r *api.MockDatabase) {
+				r.EXPECT().GetBooksPage(map[string][]string{"name": {"Dune"}}, 2, 3).Return([]models.Book{booktest.Book(1)}, nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{}, nil)
+			},
+			expectedIDs: []int64{1},
+		},
+		{
+			name:    "full last page",
+			request: &bookspb.ListBooksRequest{PageSize: 2, PageToken: encodePageToken(3)},
+			mockBehavior: func(r *api.MockDatabase) {
+				r.EXPECT().GetBooksPage(map[string][]string{}, 3, 3).Return([]models.Book{booktest.Book(2), booktest.Book(1)}, nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{}, nil)
+			},
+			expectedIDs: []int64{2, 1},
+		},
+		{
+			name:         "invalid genre",
+			request:      &bookspb.ListBooksRequest{Genre: 4},
+			mockBehavior: func(r *api.MockDatabase) {},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "invalid page token",
+			request:      &bookspb.ListBooksRequest{PageToken: "%"},
+			mockBehavior: func(r *api.MockDatabase) {},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:    "database error",
+			request: &bookspb.ListBooksRequest{},
+			mockBehavior: func(r *api.MockDatabase) {
+				r.EXPECT().GetBooksPage(map[string][]string{}, 0, DefaultPageSize+1).Return(nil, errors.New("connection refused"))
+			},
+			expectedCode: codes.Internal,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			db := api.NewMockDatabase(c)
+			test.mockBehavior(db)
+			client := dial(t, NewServer(db, events.NewBus(10)))
+
+			resp, err := client.ListBooks(context.Background(), test.request)
+			assert.Equal(t, test.expectedCode, status.Code(err))
+			if err != nil {
+				return
+			}
+			var ids []int64
+			for _, book := range resp.Books {
+				ids = append(ids, book.Id)
+				assert.Equal(t, "9.99", book.EffectivePrice.Amount)
+			}
+			assert.Equal(t, test.expectedIDs, ids)
+			assert.Equal(t, test.expectedNextToken, resp.NextPageToken)
+		})
+	}
+}
+
+func TestServerBooks(t *testing.T) {
+	type mockBehavior func(r *api.MockDatabase)
+	input := &bookspb.Book{Id: 1, Name: "Dune", Authors: []string{"Frank Herbert"}, Price: &bookspb.Money{Amount: "9.99"}, Genre: 3, Amount: 4}
+	info := models.AuditInfo{Actor: "tester", RequestID: "1"}
+	tests := []struct {
+		name         string
+		call         func(client bookspb.BookServiceClient, ctx context.Context) (interface{}, error)
+		mockBehavior mockBehavior
+		expectedBook *bookspb.Book
+		expectedCode codes.Code
+	}{
+		{
+			name: "get",
+			call: func(client bookspb.BookServiceClient, ctx context.Context) (interface{}, error) {
+				return client.GetBook(ctx, &bookspb.GetBookRequest{Id: 1})
+			},
+			mockBehavior: func(r *api.MockDatabase) {
+				r.EXPECT().GetBookById(1).Return(booktest.Book(1), nil)
+				r.EXPECT().GetActivePromotions(gomock.Any()).Return([]models.Promotion{}, nil)
+			},
+			expectedBook: &bookspb.Book{Id: 1, Name: "Dune", Authors: []string{"Frank Herbert"},
+				Price: &bookspb.Money{Amount: "9.99", CurrencyCode: "USD"}, EffectivePrice: &bookspb.Money{Amount: "9.99", CurrencyCode: "USD"},
+				Genre: 3, Amount: 4},
+		},
+		{
+			name: "get unknown",
+			call: func(client bookspb.BookServiceClient, ctx context.Context) (interface{}, error) {
+				return client.GetBook(ctx, &bookspb.GetBookRequest{Id: 2})
+			},
+			mockBehavior: func(r *api.MockDatabase) {
+				r.EXPECT().GetBookById(2).Return(models.Book{}, sql.ErrNoRows)
+			},
+			expectedCode: codes.NotFound,
+		},
+		{
+			name: "create",
+			call: func(client bookspb.BookServiceClient, ctx context.Context) (interface{}, error) {
+				return client.CreateBook(ctx, &bookspb.CreateBookRequest{Book: input})
+			},
+			mockBehavior: func(r *api.MockDatabase) {
+				added := booktest.Book(1)
+				added.CreatedAt, added.UpdatedAt = time.Time{}, time.Time{}
+				r.EXPECT().AddBook(added, info).Return(1, nil)
+				r.EXPECT().GetBookById(1).Return(booktest.Book(1), nil)
+			},
+			expectedBook: &bookspb.Book{Id: 1, Name: "Dune", Authors: []string{"Frank Herbert"},
+				Price: &bookspb.Money{Amount: "9.99", CurrencyCode: "USD"}, Genre: 3, Amount: 4},
+		},
+		{
+			name: "create invalid",
+			call: func(client bookspb.BookServiceClient, ctx context.Context) (interface{}, error) {
+				return client.CreateBook(ctx, &bookspb.CreateBookRequest{Book: &bookspb.Book{Name: "Dune", Price: &bookspb.Money{Amount: "9.999"}, Genre: 3}})
+			},
+			mockBehavior: func(r *api.MockDatabase) {},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name: "create duplicate",
+			call: func(client bookspb.BookServiceClient, ctx context.Context) (interface{}, error) {
+				return client.CreateBook(ctx, &bookspb.CreateBookRequest{Book: input})
+			},
+			mockBehavior: func(r *api.MockDatabase) {
+				r.EXPECT().AddBook(gomock.Any(), info).Return(0, errors.New(`pq: duplicate key value violates unique constraint "books_name_key"`))
+			},
+			expectedCode: codes.AlreadyExists,
+		},
+		{
+			name: "update clears authors",
+			call: func(client bookspb.BookServiceClient, ctx context.Context) (interface{}, error) {
+				return client.UpdateBook(ctx, &bookspb.UpdateBookRequest{Book: &bookspb.Book{Id: 1, Name: "Dune", Price: &bookspb.Money{Amount: "9.99"}, Genre: 3, Amount: 4}})
+			},
+			mockBehavior: func(r *api.MockDatabase) {
+				updated := booktest.Book(1)
+				updated.Authors, updated.CreatedAt, updated.UpdatedAt = []string{}, time.Time{}, time.Time{}
+				r.EXPECT().UpdateBook(1, updated, info).Return(nil)
+				r.EXPECT().GetBookById(1).Return(updated, nil)
+			},
+			expectedBook: &bookspb.Book{Id: 1, Name: "Dune", Price: &bookspb.Money{Amount: "9.99", CurrencyCode: "USD"}, Genre: 3, Amount: 4},
+		},
+		{
+			name: "update unknown",
+			call: func(client bookspb.BookServiceClient, ctx context.Context) (interface{}, error) {
+				return client.UpdateBook(ctx, &bookspb.UpdateBookRequest{Book: input})
+			},
+			mockBehavior: func(r *api.MockDatabase) {
+				r.EXPECT().UpdateBook(1, gomock.Any(), info).Return(sql.ErrNoRows)
+			},
+			expectedCode: codes.NotFound,
+		},
+		{
+			name: "delete",
+			call: func(client bookspb.BookServiceClient, ctx context.Context) (interface{}, error) {
+				return client.DeleteBook(ctx, &bookspb.DeleteBookRequest{Id: 1})
+			},
+			mockBehavior: func(r *api.MockDatabase) {
+				r.EXPECT().GetBookById(1).Return(booktest.Book(1), nil)
+				r.EXPECT().DelBook(1, info).Return(nil)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			db := api.NewMockDatabase(c)
+			test.mockBehavior(db)
+			client := dial(t, NewServer(db, events.NewBus(10)))
+			ctx := metadata.AppendToOutgoingContext(context.Background(), actorKey, "tester", requestIDKey, "1")
+
+			resp, err := test.call(client, ctx)
+			assert.Equal(t, test.expectedCode, status.Code(err))
+			if book, ok := resp.(*bookspb.Book); ok && test.expectedBook != nil {
+				book.CreateTime, book.UpdateTime = nil, nil
+				assert.True(t, proto.Equal(test.expectedBook, book), "unexpected book %v", book)
+			}
+		})
+	}
+}
+
+func TestServerWatchBooks(t *testing.T) {
+	bus := events.NewBus(10)
+	client := dial(t, NewServer(nil, bus))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.WatchBooks(ctx, &bookspb.WatchBooksRequest{BookIds: []int64{1}, Genres: []int32{2}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = stream.Header()
+	assert.NoError(t, err)
+
+	bus.Publish(models.EventBookCreated, 3, models.Book{ID: 3, Genre: 3})
+	bus.Publish(models.EventBookUpdated, 1, booktest.Book(1))
+	bus.Publish(models.EventBookDeleted, 4, models.Book{ID: 4, Genre: 2})
+
+	event, err := stream.Recv()
+	if assert.NoError(t, err) {
+		assert.Equal(t, models.EventBookUpdated, event.Type)
+		assert.Equal(t, int64(1), event.BookId)
+		assert.Equal(t, "Dune", event.Book.Name)
+	}
+	event, err = stream.Recv()
+	if assert.NoError(t, err) {
+		assert.Equal(t, models.EventBookDeleted, event.Type)
+		assert.Equal(t, int64(4), event.BookId)
+	}
+
+	bus.Reset()
+	event, err = stream.Recv()
+	if assert.NoError(t, err) {
+		assert.Equal(t, models.EventReset, event.Type)
+		assert.Nil(t, event.Book)
+	}
+
+	bus.Close()
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
@@ -0,0 +1,64 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const SignatureHeader = "X-Webhook-Signature"
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrExpiredSignature = errors.New("expired webhook signature")
+)
+
+// Sign returns the signature header value for a body sent at the given time,
+// in the form t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">. Having
+// the timestamp signed lets receivers reject replayed deliveries.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac(secret, t, body))
+}
+
+// Verify checks a signature header produced by Sign, refusing signatures
+// older than tolerance when it is positive.
+func Verify(secret, header string, body []byte, tolerance time.Duration) error {
+	var t, v1 string
+	for _, part := range strings.Split(header, ",") {
+		pair := strings.SplitN(part, "=", 2)
+		if len(pair) != 2 {
+			continue
+		}
+		switch pair[0] {
+		case "t":
+			t = pair[1]
+		case "v1":
+			v1 = pair[1]
+		}
+	}
+	unix, err := strconv.ParseInt(t, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	signature, err := hex.DecodeString(v1)
+	if err != nil || !hmac.Equal(signature, mac(secret, t, body)) {
+		return ErrInvalidSignature
+	}
+	if tolerance > 0 && time.Since(time.Unix(unix, 0)) > tolerance {
+		return ErrExpiredSignature
+	}
+	return nil
+}
+
+func mac(secret, timestamp string, body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(timestamp))
+	h.Write([]byte("."))
+	h.Write(body)
+	return h.Sum(nil)
+}
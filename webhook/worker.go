@@ -0,0 +1,184 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/porky256/rest-api/models"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	EventHeader    = "X-Webhook-Event"
+	DeliveryHeader = "X-Webhook-Delivery"
+
+	DefaultInterval    = 5 * time.Second
+	DefaultBatchSize   = 20
+	DefaultMaxAttempts = 8
+	DefaultTimeout     = 10 * time.Second
+	maxErrorLength     = 500
+)
+
+// Store is the part of db.Database the worker depends on.
+type Store interface {
+	ClaimDeliveries(limit int, lease time.Duration) ([]models.Delivery, error)
+	RecordDeliveryAttempt(id int64, attempt models.DeliveryAttempt) error
+}
+
+// Worker posts queued deliveries to their webhooks. Failed deliveries are
+// retried with exponential backoff and end up dead after MaxAttempts.
+type Worker struct {
+	Store       Store
+	Client      *http.Client
+	Interval    time.Duration
+	BatchSize   int
+	MaxAttempts int
+	// Backoff returns how long to wait before the next attempt after the given number of failed ones.
+	Backoff func(attempts int) time.Duration
+
+	wake chan struct{}
+}
+
+func NewWorker(store Store) *Worker {
+	return &Worker{
+		Store:       store,
+		Client:      &http.Client{Timeout: DefaultTimeout},
+		Interval:    DefaultInterval,
+		BatchSize:   DefaultBatchSize,
+		MaxAttempts: DefaultMaxAttempts,
+		Backoff:     ExponentialBackoff(10*time.Second, time.Hour),
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Wake makes a running worker look for deliveries right away instead of
+// waiting for the next interval.
+func (worker *Worker) Wake() {
+	select {
+	case worker.wake <- struct{}{}:
+	default:
+	}
+}
+
+// ExponentialBackoff doubles the wait from base with every failed attempt, up to max.
+func ExponentialBackoff(base, max time.Duration) func(attempts int) time.Duration {
+	return func(attempts int) time.Duration {
+		wait := base
+		for i := 1; i < attempts && wait < max; i++ {
+			wait *= 2
+		}
+		if wait > max {
+			wait = max
+		}
+		return wait
+	}
+}
+
+// Run delivers due webhooks every Interval until ctx is done. Whenever a
+// full batch was claimed or the worker is woken up the next one is fetched
+// right away.
+func (worker *Worker) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case <-worker.wake:
+			if !timer.Stop() {
+				<-timer.C
+			}
+		}
+		n, err := worker.DeliverPending(ctx)
+		if err != nil {
+			log.Println("webhook delivery: ", err)
+		}
+		if n == worker.BatchSize {
+			timer.Reset(0)
+		} else {
+			timer.Reset(worker.Interval)
+		}
+	}
+}
+
+// DeliverPending claims one batch of due deliveries, posts them and records
+// the outcomes. It returns the number of deliveries claimed.
+func (worker *Worker) DeliverPending(ctx context.Context) (int, error) {
+	deliveries, err := worker.Store.ClaimDeliveries(worker.BatchSize, worker.lease())
+	if err != nil {
+		return 0, err
+	}
+	for _, delivery := range deliveries {
+		attempt := worker.deliver(ctx, delivery)
+		if err := worker.Store.RecordDeliveryAttempt(delivery.ID, attempt); err != nil {
+			return len(deliveries), err
+		}
+	}
+	return len(deliveries), nil
+}
+
+// lease is how long claimed deliveries are kept from other workers, long
+// enough for a whole batch to time out.
+func (worker *Worker) lease() time.Duration {
+	timeout := DefaultTimeout
+	if worker.Client != nil && worker.Client.Timeout > 0 {
+		timeout = worker.Client.Timeout
+	}
+	return time.Duration(worker.BatchSize+1) * timeout
+}
+
+func (worker *Worker) deliver(ctx context.Context, delivery models.Delivery) models.DeliveryAttempt {
+	status, err := worker.post(ctx, delivery)
+	if err == nil {
+		return models.DeliveryAttempt{Status: models.DeliveryDelivered, ResponseStatus: status, NextAttemptAt: time.Now()}
+	}
+
+	message := err.Error()
+	if len(message) > maxErrorLength {
+		message = message[:maxErrorLength]
+	}
+	attempt := models.DeliveryAttempt{Status: models.DeliveryPending, ResponseStatus: status, Error: message,
+		NextAttemptAt: time.Now().Add(worker.Backoff(delivery.Attempts))}
+	if delivery.Attempts >= worker.MaxAttempts {
+		attempt.Status, attempt.NextAttemptAt = models.DeliveryDead, time.Now()
+	}
+	return attempt
+}
+
+// post sends the event signed with the webhook's secret and returns the
+// response status, any non-2xx response is an error.
+func (worker *Worker) post(ctx context.Context, delivery models.Delivery) (int, error) {
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, delivery.Event.Type)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(SignatureHeader, Sign(delivery.Secret, time.Now(), body))
+
+	client := worker.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
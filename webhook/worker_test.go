@@ -0,0 +1,173 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/porky256/rest-api/models"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// memoryStore hands out its deliveries once and keeps the recorded attempts.
+type memoryStore struct {
+	deliveries []models.Delivery
+	attempts   map[int64]models.DeliveryAttempt
+}
+
+func (s *memoryStore) ClaimDeliveries(limit int, lease time.Duration) ([]models.Delivery, error) {
+	claimed := s.deliveries
+	if len(claimed) > limit {
+		claimed = claimed[:limit]
+	}
+	s.deliveries = s.deliveries[len(claimed):]
+	return claimed, nil
+}
+
+func (s *memoryStore) RecordDeliveryAttempt(id int64, attempt models.DeliveryAttempt) error {
+	s.attempts[id] = attempt
+	return nil
+}
+
+func TestWorkerDeliverPending(t *testing.T) {
+	const secret = "0123456789abcdef"
+	event := models.Event{ID: 7, Type: models.EventBookCreated, BookID: 1, Data: json.RawMessage(`{"id":1}`),
+		CreatedAt: time.Date(2021, 11, 20, 10, 0, 0, 0, time.UTC)}
+
+	var received []models.Event
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		if err := Verify(secret, r.Header.Get(SignatureHeader), body, time.Minute); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(t, models.EventBookCreated, r.Header.Get(EventHeader))
+		var event models.Event
+		assert.NoError(t, json.Unmarshal(body, &event))
+		received = append(received, event)
+	}))
+	defer receiver.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+
+	tests := []struct {
+		name           string
+		delivery       models.Delivery
+		status         string
+		responseStatus int
+		wait           time.Duration
+	}{
+		{
+			name:           "delivered",
+			delivery:       models.Delivery{ID: 1, URL: receiver.URL, Secret: secret, Event: event, Attempts: 1},
+			status:         models.DeliveryDelivered,
+			responseStatus: http.StatusOK,
+		},
+		{
+			name:           "wrong secret is retried",
+			delivery:       models.Delivery{ID: 2, URL: receiver.URL, Secret: "another secret!!", Event: event, Attempts: 1},
+			status:         models.DeliveryPending,
+			responseStatus: http.StatusUnauthorized,
+			wait:           10 * time.Second,
+		},
+		{
+			name:           "backoff grows",
+			delivery:       models.Delivery{ID: 3, URL: failing.URL, Secret: secret, Event: event, Attempts: 3},
+			status:         models.DeliveryPending,
+			responseStatus: http.StatusBadGateway,
+			wait:           40 * time.Second,
+		},
+		{
+			name:     "unreachable",
+			delivery: models.Delivery{ID: 4, URL: "http://127.0.0.1:1", Secret: secret, Event: event, Attempts: 1},
+			status:   models.DeliveryPending,
+			wait:     10 * time.Second,
+		},
+		{
+			name:           "dead after max attempts",
+			delivery:       models.Delivery{ID: 5, URL: failing.URL, Secret: secret, Event: event, Attempts: DefaultMaxAttempts},
+			status:         models.DeliveryDead,
+			responseStatus: http.StatusBadGateway,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			received = nil
+			store := &memoryStore{deliveries: []models.Delivery{test.delivery}, attempts: map[int64]models.DeliveryAttempt{}}
+			worker := NewWorker(store)
+			worker.Client = receiver.Client()
+
+			start := time.Now()
+			n, err := worker.DeliverPending(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, 1, n)
+
+			attempt := store.attempts[test.delivery.ID]
+			assert.Equal(t, test.status, attempt.Status)
+			assert.Equal(t, test.responseStatus, attempt.ResponseStatus)
+			if test.status == models.DeliveryDelivered {
+				assert.Empty(t, attempt.Error)
+				assert.Equal(t, []models.Event{event}, received)
+			} else {
+				assert.NotEmpty(t, attempt.Error)
+				assert.Empty(t, received)
+				assert.WithinDuration(t, start.Add(test.wait), attempt.NextAttemptAt, time.Second)
+			}
+		})
+	}
+}
+
+// claimCounter reports every claim on a channel.
+type claimCounter chan struct{}
+
+func (c claimCounter) ClaimDeliveries(limit int, lease time.Duration) ([]models.Delivery, error) {
+	c <- struct{}{}
+	return nil, nil
+}
+
+func (c claimCounter) RecordDeliveryAttempt(id int64, attempt models.DeliveryAttempt) error {
+	return nil
+}
+
+func TestWorkerWake(t *testing.T) {
+	claims := make(claimCounter)
+	worker := NewWorker(claims)
+	worker.Interval = time.Hour
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go worker.Run(ctx)
+
+	<-claims
+	worker.Wake()
+	select {
+	case <-claims:
+	case <-time.After(time.Second):
+		t.Fatal("worker didn't wake up")
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(time.Second, 10*time.Second)
+	assert.Equal(t, time.Second, backoff(0))
+	assert.Equal(t, time.Second, backoff(1))
+	assert.Equal(t, 2*time.Second, backoff(2))
+	assert.Equal(t, 8*time.Second, backoff(4))
+	assert.Equal(t, 10*time.Second, backoff(5))
+	assert.Equal(t, 10*time.Second, backoff(60))
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	now := time.Now()
+	assert.NoError(t, Verify("secret", Sign("secret", now, body), body, time.Minute))
+	assert.Equal(t, ErrInvalidSignature, Verify("other", Sign("secret", now, body), body, time.Minute))
+	assert.Equal(t, ErrInvalidSignature, Verify("secret", Sign("secret", now, body), []byte(`{"id":2}`), time.Minute))
+	assert.Equal(t, ErrInvalidSignature, Verify("secret", "garbage", body, time.Minute))
+	assert.Equal(t, ErrExpiredSignature, Verify("secret", Sign("secret", now.Add(-time.Hour), body), body, time.Minute))
+}